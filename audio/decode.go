@@ -0,0 +1,114 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrNotPCM is returned by Decode for formats it can't decode (MP3 and
+// anything that isn't WAV or FLAC).
+var ErrNotPCM = errors.New("only WAV and FLAC can be decoded")
+
+// wavBlockFrames is how many frames Decode hands over at a time for WAV.
+const wavBlockFrames = 4096
+
+// Decoder reads PCM samples from a WAV or FLAC file.
+type Decoder struct {
+	st *stream
+}
+
+// NewDecoder parses the file header. MP3 and other formats return ErrNotPCM.
+func NewDecoder(r io.Reader, size int64) (*Decoder, error) {
+	st, err := open(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if st.info.Format != FormatWAV && st.info.Format != FormatFLAC {
+		return nil, fmt.Errorf("%s: %w", Describe(st.info.Format), ErrNotPCM)
+	}
+	return &Decoder{st: st}, nil
+}
+
+// Info returns what the header says about the file.
+func (d *Decoder) Info() Info {
+	return d.st.info
+}
+
+// Decode calls fn with successive blocks of samples, one slice per channel,
+// scaled to [-1, 1). fn must not keep the slices after returning.
+func (d *Decoder) Decode(fn func(block [][]float64) error) error {
+	if d.st.info.Format == FormatFLAC {
+		return decodeFLAC(d.st, fn)
+	}
+	return decodeWAV(d.st, fn)
+}
+
+// decodeWAV converts the data chunk to float samples.
+func decodeWAV(st *stream, fn func(block [][]float64) error) error {
+	info := st.info
+	bytesPerSample := (info.BitDepth + 7) / 8
+	if info.Channels < 1 || bytesPerSample < 1 || bytesPerSample > 8 {
+		return fmt.Errorf("unsupported WAV layout: %d channel(s) of %d-bit samples", info.Channels, info.BitDepth)
+	}
+	if info.Float && bytesPerSample != 4 && bytesPerSample != 8 {
+		return fmt.Errorf("unsupported %d-bit float WAV", info.BitDepth)
+	}
+	frameSize := bytesPerSample * info.Channels
+	scale := math.Ldexp(1, -(bytesPerSample*8 - 1)) // Samples are stored left-justified in whole bytes
+
+	block := make([][]float64, info.Channels)
+	for c := range block {
+		block[c] = make([]float64, wavBlockFrames)
+	}
+	buf := make([]byte, wavBlockFrames*frameSize)
+	remaining := st.dataLen - st.dataLen%int64(frameSize)
+	for remaining > 0 {
+		n := int64(len(buf))
+		if n > remaining {
+			n = remaining
+		}
+		if _, err := io.ReadFull(st.br, buf[:n]); err != nil {
+			return fmt.Errorf("truncated WAV data: %w", err)
+		}
+		remaining -= n
+
+		frames := int(n) / frameSize
+		for i := 0; i < frames; i++ {
+			for c := 0; c < info.Channels; c++ {
+				b := buf[(i*info.Channels+c)*bytesPerSample:]
+				block[c][i] = wavSample(b[:bytesPerSample], info.Float, scale)
+			}
+		}
+		for c := range block {
+			block[c] = block[c][:frames]
+		}
+		if err := fn(block); err != nil {
+			return err
+		}
+		for c := range block {
+			block[c] = block[c][:wavBlockFrames]
+		}
+	}
+	return nil
+}
+
+// wavSample decodes one little-endian sample.
+func wavSample(b []byte, float bool, scale float64) float64 {
+	switch {
+	case float && len(b) == 4:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case float:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case len(b) == 1:
+		return float64(int(b[0])-128) / 128 // 8-bit WAV is unsigned
+	}
+	// Sign-extend from the top byte
+	v := int64(int8(b[len(b)-1]))
+	for i := len(b) - 2; i >= 0; i-- {
+		v = v<<8 | int64(b[i])
+	}
+	return float64(v) * scale
+}
@@ -0,0 +1,467 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// decodeAll decodes a whole file, returning its samples per channel.
+func decodeAll(t *testing.T, data []byte) (Info, [][]float64) {
+	t.Helper()
+	d, err := NewDecoder(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([][]float64, d.Info().Channels)
+	err = d.Decode(func(block [][]float64) error {
+		for c := range block {
+			samples[c] = append(samples[c], block[c]...)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.Info(), samples
+}
+
+// checkSamples fails unless got matches want exactly, channel by channel.
+func checkSamples(t *testing.T, got, want [][]float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d channel(s), want %d", len(got), len(want))
+	}
+	for c := range want {
+		if len(got[c]) != len(want[c]) {
+			t.Fatalf("channel %d: got %d sample(s), want %d", c, len(got[c]), len(want[c]))
+		}
+		for i := range want[c] {
+			if got[c][i] != want[c][i] {
+				t.Fatalf("channel %d sample %d: got %v, want %v", c, i, got[c][i], want[c][i])
+			}
+		}
+	}
+}
+
+// wavFile wraps interleaved little-endian sample data in a minimal WAV.
+func wavFile(channels, rate, bitDepth int, float bool, data []byte) []byte {
+	tag := uint16(wavFormatPCM)
+	if float {
+		tag = wavFormatFloat
+	}
+	bytesPerSample := (bitDepth + 7) / 8
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(4+8+16+8+len(data)))
+	b.WriteString("WAVEfmt ")
+	for _, field := range []any{
+		uint32(16), tag, uint16(channels), uint32(rate),
+		uint32(rate * channels * bytesPerSample), uint16(channels * bytesPerSample), uint16(bitDepth),
+	} {
+		binary.Write(&b, binary.LittleEndian, field)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	return b.Bytes()
+}
+
+func TestDecodeWAV(t *testing.T) {
+	f32 := func(v float32) []byte { return binary.LittleEndian.AppendUint32(nil, math.Float32bits(v)) }
+	cases := []struct {
+		name     string
+		channels int
+		bitDepth int
+		float    bool
+		data     []byte
+		want     [][]float64
+	}{
+		{"16-bit stereo", 2, 16, false,
+			[]byte{0x00, 0x40, 0x00, 0x80, 0x00, 0xC0, 0xFF, 0x7F},
+			[][]float64{{0.5, -0.5}, {-1, 32767.0 / 32768}}},
+		{"24-bit mono", 1, 24, false,
+			[]byte{0x00, 0x00, 0x40, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x80},
+			[][]float64{{0.5, -1.0 / 8388608, -1}}},
+		{"8-bit unsigned", 1, 8, false,
+			[]byte{0x80, 0x00, 0xC0},
+			[][]float64{{0, -1, 0.5}}},
+		{"20-bit in 3 bytes", 1, 20, false,
+			[]byte{0x00, 0x00, 0x20},
+			[][]float64{{0.25}}},
+		{"32-bit float", 1, 32, true,
+			append(f32(0.25), f32(-1)...),
+			[][]float64{{0.25, -1}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			info, got := decodeAll(t, wavFile(c.channels, 48000, c.bitDepth, c.float, c.data))
+			if info.SampleRate != 48000 || info.BitDepth != c.bitDepth || info.Float != c.float {
+				t.Fatalf("got %+v", info)
+			}
+			checkSamples(t, got, c.want)
+		})
+	}
+}
+
+func TestDecodeWAVAcrossBlocks(t *testing.T) {
+	frames := 3*wavBlockFrames + 17
+	var data []byte
+	want := [][]float64{make([]float64, frames)}
+	for i := range want[0] {
+		v := int16(i*31 - 20000)
+		data = binary.LittleEndian.AppendUint16(data, uint16(v))
+		want[0][i] = float64(v) / 32768
+	}
+	data = append(data, 0x01) // A partial frame at the end is ignored
+	_, got := decodeAll(t, wavFile(1, 44100, 16, false, data))
+	checkSamples(t, got, want)
+}
+
+// minimalFLAC is a hand-assembled mono, 16-bit, 44.1 kHz stream holding the
+// samples 100, 103, 101, 101 in one frame: a fixed order 1 predictor with a
+// warm-up sample of 100 and the residual 3, -2, 0 Rice coded with k = 1.
+var minimalFLAC = []byte{
+	'f', 'L', 'a', 'C',
+	0x80, 0x00, 0x00, 0x22, // Last metadata block, STREAMINFO, 34 bytes
+	0x00, 0x04, 0x00, 0x04, // Block size 4..4
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Frame sizes unknown
+	0x0A, 0xC4, 0x40, 0xF0, 0x00, 0x00, 0x00, 0x04, // 44100 Hz, 1 channel, 16 bits, 4 samples
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // No MD5
+	0xFF, 0xF8, // Sync, fixed block size
+	0x60,       // Block size in 8 bits at the end of the header, rate from STREAMINFO
+	0x00,       // Mono, bit depth from STREAMINFO
+	0x00,       // Frame 0
+	0x03,       // Block size 4
+	0xE3,       // CRC-8
+	0x12,       // Subframe: fixed, order 1
+	0x00, 0x64, // Warm-up: 100
+	0x00, 0x44, 0xE0, // Rice, partition order 0, k = 1: 0001 0, 01 1, 1 0
+	0x1E, 0xC5, // CRC-16
+}
+
+func TestDecodeFLACKnownVector(t *testing.T) {
+	info, got := decodeAll(t, minimalFLAC)
+	if info.SampleRate != 44100 || info.Channels != 1 || info.BitDepth != 16 {
+		t.Fatalf("got %+v", info)
+	}
+	checkSamples(t, got, [][]float64{{100.0 / 32768, 103.0 / 32768, 101.0 / 32768, 101.0 / 32768}})
+}
+
+func TestDecodeFLACTruncated(t *testing.T) {
+	data := minimalFLAC[:len(minimalFLAC)-4]
+	d, err := NewDecoder(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Decode(func([][]float64) error { return nil }); err == nil {
+		t.Fatal("decoded a truncated frame without an error")
+	}
+}
+
+// bitWriter packs big-endian bit fields, the mirror of bitReader.
+type bitWriter struct {
+	buf []byte
+	acc byte
+	n   uint
+}
+
+func (w *bitWriter) write(v uint64, k uint) {
+	for i := k; i > 0; i-- {
+		w.acc = w.acc<<1 | byte(v>>(i-1)&1)
+		if w.n++; w.n == 8 {
+			w.buf = append(w.buf, w.acc)
+			w.acc, w.n = 0, 0
+		}
+	}
+}
+
+func (w *bitWriter) writeSigned(v int64, k uint) {
+	w.write(uint64(v)&(1<<k-1), k)
+}
+
+func (w *bitWriter) align() {
+	for w.n != 0 {
+		w.write(0, 1)
+	}
+}
+
+// testSubframe says how the test encoder codes one channel of a frame.
+type testSubframe struct {
+	kind           int     // 0 constant, 1 verbatim, 8-12 fixed, 32+ LPC, as in the format
+	coefs          []int64 // LPC only
+	shift          int     // LPC only
+	wasted         uint    // Low zero bits to strip
+	partitionOrder uint
+	escape         bool // Send the residual unencoded
+}
+
+// encodeSubframe codes samples as described by s, which must suit them.
+func encodeSubframe(w *bitWriter, samples []int64, bps uint, s testSubframe) {
+	w.write(uint64(s.kind), 7) // Zero padding bit, then the type
+	if s.wasted > 0 {
+		w.write(1, 1)
+		w.write(0, s.wasted-1) // Unary wasted-1
+		w.write(1, 1)
+		bps -= s.wasted
+		shifted := make([]int64, len(samples))
+		for i, v := range samples {
+			shifted[i] = v >> s.wasted
+		}
+		samples = shifted
+	} else {
+		w.write(0, 1)
+	}
+
+	order := 0
+	switch {
+	case s.kind == 0:
+		w.writeSigned(samples[0], bps)
+		return
+	case s.kind == 1:
+		for _, v := range samples {
+			w.writeSigned(v, bps)
+		}
+		return
+	case s.kind >= 32:
+		order = s.kind - 31
+	default:
+		order = s.kind - 8
+	}
+	for _, v := range samples[:order] {
+		w.writeSigned(v, bps)
+	}
+
+	residual := make([]int64, len(samples))
+	for i := order; i < len(samples); i++ {
+		x := samples
+		var prediction int64
+		switch {
+		case s.kind >= 32:
+			for j, c := range s.coefs {
+				prediction += c * x[i-1-j]
+			}
+			prediction >>= uint(s.shift)
+		case order == 1:
+			prediction = x[i-1]
+		case order == 2:
+			prediction = 2*x[i-1] - x[i-2]
+		case order == 3:
+			prediction = 3*x[i-1] - 3*x[i-2] + x[i-3]
+		case order == 4:
+			prediction = 4*x[i-1] - 6*x[i-2] + 4*x[i-3] - x[i-4]
+		}
+		residual[i] = x[i] - prediction
+	}
+	if s.kind >= 32 {
+		const precision = 15
+		w.write(precision-1, 4)
+		w.writeSigned(int64(s.shift), 5)
+		for _, c := range s.coefs {
+			w.writeSigned(c, precision)
+		}
+	}
+
+	w.write(0, 2) // Rice, 4-bit parameters
+	w.write(uint64(s.partitionOrder), 4)
+	perPartition := len(samples) >> s.partitionOrder
+	for p := 0; p < 1<<s.partitionOrder; p++ {
+		part := residual[max(p*perPartition, order) : (p+1)*perPartition]
+		if s.escape {
+			w.write(15, 4)
+			w.write(24, 5)
+			for _, r := range part {
+				w.writeSigned(r, 24)
+			}
+			continue
+		}
+		var sum uint64
+		for _, r := range part {
+			sum += zigzag(r)
+		}
+		k := uint(0)
+		for len(part) > 0 && k < 14 && uint64(len(part))<<(k+1) <= sum {
+			k++
+		}
+		w.write(uint64(k), 4)
+		for _, r := range part {
+			u := zigzag(r)
+			w.write(0, uint(u>>k)) // Unary quotient
+			w.write(1, 1)
+			w.write(u&(1<<k-1), k)
+		}
+	}
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// testFrame is one frame for the test encoder.
+type testFrame struct {
+	assignment int
+	coded      [][]int64 // Samples per channel as coded, i.e. already decorrelated
+	subframes  []testSubframe
+}
+
+// encodeFLAC builds a stream holding frames, in order.
+func encodeFLAC(rate, channels, bitDepth int, frames []testFrame) []byte {
+	total := 0
+	for _, f := range frames {
+		total += len(f.coded[0])
+	}
+	w := &bitWriter{}
+	w.buf = append(w.buf, "fLaC"...)
+	w.write(0x80, 8) // Last metadata block, STREAMINFO
+	w.write(34, 24)
+	w.write(0, 16+16+24+24) // Block and frame sizes left unstated
+	w.write(uint64(rate), 20)
+	w.write(uint64(channels-1), 3)
+	w.write(uint64(bitDepth-1), 5)
+	w.write(uint64(total), 36)
+	w.write(0, 64)
+	w.write(0, 64) // No MD5
+
+	for n, f := range frames {
+		start := len(w.buf)
+		w.write(0x3FFE, 14)
+		w.write(0, 2)
+		w.write(7, 4) // Block size in 16 bits at the end of the header
+		w.write(0, 4) // Sample rate from STREAMINFO
+		w.write(uint64(f.assignment), 4)
+		w.write(0, 4) // Bit depth from STREAMINFO
+		w.write(uint64(n), 8)
+		w.write(uint64(len(f.coded[0])-1), 16)
+		w.write(uint64(crc8(w.buf[start:])), 8)
+		for c, samples := range f.coded {
+			bps := uint(bitDepth)
+			if (f.assignment == flacLeftSide && c == 1) || (f.assignment == flacSideRight && c == 0) || (f.assignment == flacMidSide && c == 1) {
+				bps++
+			}
+			encodeSubframe(w, samples, bps, f.subframes[c])
+		}
+		w.align()
+		w.write(uint64(crc16(w.buf[start:])), 16)
+	}
+	return w.buf
+}
+
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// testSignal returns n samples of a tone with some noise, within bitDepth.
+func testSignal(rng *rand.Rand, n, bitDepth int, freq float64) []int64 {
+	amplitude := math.Ldexp(0.6, bitDepth-1)
+	out := make([]int64, n)
+	for i := range out {
+		out[i] = int64(amplitude*math.Sin(2*math.Pi*freq*float64(i)/44100)) + rng.Int63n(64) - 32
+	}
+	return out
+}
+
+// scaled converts integer samples to the decoder's float range.
+func scaled(samples []int64, bitDepth int) []float64 {
+	out := make([]float64, len(samples))
+	for i, v := range samples {
+		out[i] = math.Ldexp(float64(v), -(bitDepth - 1))
+	}
+	return out
+}
+
+func TestDecodeFLACSubframes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const bitDepth = 16
+	fixed := func(order int) testSubframe { return testSubframe{kind: 8 + order, partitionOrder: 2} }
+	subframes := []testSubframe{
+		{kind: 1},
+		fixed(0), fixed(1), fixed(2), fixed(3), fixed(4),
+		{kind: 31 + 2, coefs: []int64{7373, -3277}, shift: 12, partitionOrder: 3}, // LPC order 2
+		{kind: 31 + 3, coefs: []int64{3 << 10, -3 << 10, 1 << 10}, shift: 10},     // LPC order 3
+		{kind: 10, escape: true, partitionOrder: 1},
+		{kind: 10, wasted: 3},
+	}
+
+	var frames []testFrame
+	var want []float64
+	for n, s := range subframes {
+		samples := testSignal(rng, 1024, bitDepth, 440+float64(n)*100)
+		if s.wasted > 0 {
+			for i := range samples {
+				samples[i] &^= 1<<s.wasted - 1
+			}
+		}
+		frames = append(frames, testFrame{coded: [][]int64{samples}, subframes: []testSubframe{s}})
+		want = append(want, scaled(samples, bitDepth)...)
+	}
+	// A constant frame, then a short last one
+	constant := make([]int64, 1024)
+	for i := range constant {
+		constant[i] = -1234
+	}
+	frames = append(frames, testFrame{coded: [][]int64{constant}, subframes: []testSubframe{{kind: 0}}})
+	want = append(want, scaled(constant, bitDepth)...)
+	short := testSignal(rng, 37, bitDepth, 1000)
+	frames = append(frames, testFrame{coded: [][]int64{short}, subframes: []testSubframe{{kind: 10}}})
+	want = append(want, scaled(short, bitDepth)...)
+
+	info, got := decodeAll(t, encodeFLAC(44100, 1, bitDepth, frames))
+	if info.Channels != 1 || info.BitDepth != bitDepth {
+		t.Fatalf("got %+v", info)
+	}
+	checkSamples(t, got, [][]float64{want})
+}
+
+func TestDecodeFLACStereo(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, bitDepth := range []int{16, 24} {
+		left := testSignal(rng, 4096, bitDepth, 440)
+		right := testSignal(rng, 4096, bitDepth, 660)
+		side := make([]int64, len(left))
+		mid := make([]int64, len(left))
+		for i := range left {
+			side[i] = left[i] - right[i]
+			mid[i] = (left[i] + right[i]) >> 1
+		}
+		sub := []testSubframe{{kind: 10, partitionOrder: 4}, {kind: 11, partitionOrder: 4}}
+		frames := []testFrame{
+			{assignment: 1, coded: [][]int64{left[:1024], right[:1024]}, subframes: sub},
+			{assignment: flacLeftSide, coded: [][]int64{left[1024:2048], side[1024:2048]}, subframes: sub},
+			{assignment: flacSideRight, coded: [][]int64{side[2048:3072], right[2048:3072]}, subframes: sub},
+			{assignment: flacMidSide, coded: [][]int64{mid[3072:], side[3072:]}, subframes: sub},
+		}
+		info, got := decodeAll(t, encodeFLAC(48000, 2, bitDepth, frames))
+		if info.Channels != 2 || info.BitDepth != bitDepth || info.SampleRate != 48000 {
+			t.Fatalf("%d-bit: got %+v", bitDepth, info)
+		}
+		checkSamples(t, got, [][]float64{scaled(left, bitDepth), scaled(right, bitDepth)})
+	}
+}
@@ -0,0 +1,469 @@
+package audio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// bitReader reads big-endian bit fields, as FLAC frames are packed.
+type bitReader struct {
+	r     *bufio.Reader
+	cache uint64 // The low n bits are unread
+	n     uint
+}
+
+// fill makes at least k bits (k <= 56) available.
+func (b *bitReader) fill(k uint) error {
+	for b.n < k {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		b.cache = b.cache<<8 | uint64(c)
+		b.n += 8
+	}
+	return nil
+}
+
+// read returns the next k bits (k <= 48) as an unsigned value.
+func (b *bitReader) read(k uint) (uint64, error) {
+	if k == 0 {
+		return 0, nil
+	}
+	if err := b.fill(k); err != nil {
+		return 0, err
+	}
+	b.n -= k
+	return (b.cache >> b.n) & (1<<k - 1), nil
+}
+
+// readSigned returns the next k bits as a two's complement value.
+func (b *bitReader) readSigned(k uint) (int64, error) {
+	v, err := b.read(k)
+	if err != nil || k == 0 {
+		return 0, err
+	}
+	shift := 64 - k
+	return int64(v<<shift) >> shift, nil
+}
+
+// readUnary counts zero bits up to the next one bit, consuming both.
+func (b *bitReader) readUnary() (uint64, error) {
+	var count uint64
+	for {
+		if b.n == 0 {
+			if err := b.fill(8); err != nil {
+				return 0, err
+			}
+		}
+		v := b.cache << (64 - b.n) // Left-align the unread bits
+		if v == 0 {
+			count += uint64(b.n)
+			b.n = 0
+			continue
+		}
+		zeros := uint(bits.LeadingZeros64(v))
+		count += uint64(zeros)
+		b.n -= zeros + 1
+		return count, nil
+	}
+}
+
+// align skips to the next byte boundary.
+func (b *bitReader) align() {
+	b.n -= b.n % 8
+}
+
+// atEOF reports whether every bit has been consumed and the input is exhausted.
+func (b *bitReader) atEOF() bool {
+	if b.n > 0 {
+		return false
+	}
+	_, err := b.r.Peek(1)
+	return err != nil
+}
+
+// FLAC frame header lookup tables.
+var (
+	flacSampleRates = [12]int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+	flacSampleSizes = [8]int{0, 8, 12, 0, 16, 20, 24, 32}
+)
+
+// FLAC channel assignments beyond "independent channels".
+const (
+	flacLeftSide  = 8
+	flacSideRight = 9
+	flacMidSide   = 10
+)
+
+// decodeFLAC decodes every frame after the metadata. CRCs are not checked;
+// a damaged frame normally shows up as a sync or length error instead.
+func decodeFLAC(st *stream, fn func(block [][]float64) error) error {
+	br := &bitReader{r: st.br}
+	scale := math.Ldexp(1, -(st.info.BitDepth - 1))
+	var samples [][]int64
+	var block [][]float64
+
+	for frame := 0; !br.atEOF(); frame++ {
+		hdr, err := readFLACFrameHeader(br, st.info)
+		if err != nil {
+			return fmt.Errorf("FLAC frame %d: %w", frame, err)
+		}
+		channels := hdr.channels()
+		if channels != st.info.Channels {
+			return fmt.Errorf("FLAC frame %d has %d channel(s), stream has %d", frame, channels, st.info.Channels)
+		}
+		if len(samples) != channels {
+			samples = make([][]int64, channels)
+			block = make([][]float64, channels)
+		}
+		for c := 0; c < channels; c++ {
+			if cap(samples[c]) < hdr.blockSize {
+				samples[c] = make([]int64, hdr.blockSize)
+				block[c] = make([]float64, hdr.blockSize)
+			}
+			samples[c] = samples[c][:hdr.blockSize]
+			block[c] = block[c][:hdr.blockSize]
+
+			// The side channel needs one extra bit
+			bps := hdr.bitDepth
+			if (hdr.assignment == flacLeftSide && c == 1) || (hdr.assignment == flacSideRight && c == 0) || (hdr.assignment == flacMidSide && c == 1) {
+				bps++
+			}
+			if err := readFLACSubframe(br, samples[c], uint(bps)); err != nil {
+				return fmt.Errorf("FLAC frame %d channel %d: %w", frame, c, err)
+			}
+		}
+		decorrelate(hdr.assignment, samples)
+
+		br.align()
+		if _, err := br.read(16); err != nil { // Frame CRC-16
+			return fmt.Errorf("FLAC frame %d: truncated: %w", frame, err)
+		}
+
+		for c := range samples {
+			for i, v := range samples[c] {
+				block[c][i] = float64(v) * scale
+			}
+		}
+		if err := fn(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flacFrameHeader is the part of a frame header the decoder needs.
+type flacFrameHeader struct {
+	blockSize  int
+	bitDepth   int
+	assignment int
+}
+
+func (h flacFrameHeader) channels() int {
+	if h.assignment < flacLeftSide {
+		return h.assignment + 1
+	}
+	return 2
+}
+
+// readFLACFrameHeader parses a frame header, falling back to STREAMINFO for
+// fields the frame leaves out.
+func readFLACFrameHeader(br *bitReader, info Info) (flacFrameHeader, error) {
+	sync, err := br.read(15)
+	if err != nil {
+		return flacFrameHeader{}, err
+	}
+	if sync != 0x7FFC { // 14-bit sync code plus a reserved zero bit
+		return flacFrameHeader{}, errors.New("lost frame sync")
+	}
+	if _, err := br.read(1); err != nil { // Blocking strategy
+		return flacFrameHeader{}, err
+	}
+	fields, err := br.read(16)
+	if err != nil {
+		return flacFrameHeader{}, err
+	}
+	sizeCode := int(fields >> 12)
+	rateCode := int(fields>>8) & 0xF
+	h := flacFrameHeader{assignment: int(fields>>4) & 0xF}
+	depthCode := int(fields>>1) & 0x7
+
+	if h.assignment > flacMidSide {
+		return h, fmt.Errorf("reserved channel assignment %d", h.assignment)
+	}
+	switch {
+	case depthCode == 0:
+		h.bitDepth = info.BitDepth
+	case flacSampleSizes[depthCode] == 0:
+		return h, fmt.Errorf("reserved sample size code %d", depthCode)
+	default:
+		h.bitDepth = flacSampleSizes[depthCode]
+	}
+
+	// Frame or sample number, UTF-8 style: the leading ones say how many bytes follow
+	first, err := br.read(8)
+	if err != nil {
+		return h, err
+	}
+	extra := bits.LeadingZeros8(^uint8(first))
+	if extra == 1 || extra > 7 {
+		return h, errors.New("invalid coded frame number")
+	}
+	for i := 1; i < extra; i++ {
+		if _, err := br.read(8); err != nil {
+			return h, err
+		}
+	}
+
+	switch {
+	case sizeCode == 0:
+		return h, errors.New("reserved block size code")
+	case sizeCode == 1:
+		h.blockSize = 192
+	case sizeCode <= 5:
+		h.blockSize = 576 << (sizeCode - 2)
+	case sizeCode == 6:
+		v, err := br.read(8)
+		if err != nil {
+			return h, err
+		}
+		h.blockSize = int(v) + 1
+	case sizeCode == 7:
+		v, err := br.read(16)
+		if err != nil {
+			return h, err
+		}
+		h.blockSize = int(v) + 1
+	default:
+		h.blockSize = 256 << (sizeCode - 8)
+	}
+
+	// Only skip the sample rate; STREAMINFO is authoritative
+	switch rateCode {
+	case 12:
+		_, err = br.read(8)
+	case 13, 14:
+		_, err = br.read(16)
+	case 15:
+		err = errors.New("invalid sample rate code")
+	}
+	if err != nil {
+		return h, err
+	}
+	_, err = br.read(8) // CRC-8
+	return h, err
+}
+
+// readFLACSubframe decodes one channel of a frame into out.
+func readFLACSubframe(br *bitReader, out []int64, bps uint) error {
+	hdr, err := br.read(8)
+	if err != nil {
+		return err
+	}
+	if hdr&0x80 != 0 {
+		return errors.New("invalid subframe padding bit")
+	}
+	kind := int(hdr>>1) & 0x3F
+
+	// Wasted bits: low-order zero bits removed from every sample
+	wasted := uint(0)
+	if hdr&1 != 0 {
+		k, err := br.readUnary()
+		if err != nil {
+			return err
+		}
+		wasted = uint(k) + 1
+		if wasted >= bps {
+			return errors.New("invalid wasted bits count")
+		}
+		bps -= wasted
+	}
+
+	switch {
+	case kind == 0: // Constant
+		v, err := br.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		for i := range out {
+			out[i] = v
+		}
+	case kind == 1: // Verbatim
+		for i := range out {
+			if out[i], err = br.readSigned(bps); err != nil {
+				return err
+			}
+		}
+	case kind >= 8 && kind <= 12: // Fixed predictor
+		order := kind - 8
+		if err := readWarmup(br, out, order, bps); err != nil {
+			return err
+		}
+		if err := readResidual(br, out, order); err != nil {
+			return err
+		}
+		restoreFixed(out, order)
+	case kind >= 32: // LPC
+		order := kind - 31
+		if err := readWarmup(br, out, order, bps); err != nil {
+			return err
+		}
+		precision, err := br.read(4)
+		if err != nil {
+			return err
+		}
+		if precision == 15 {
+			return errors.New("invalid LPC coefficient precision")
+		}
+		shift, err := br.readSigned(5)
+		if err != nil {
+			return err
+		}
+		if shift < 0 {
+			return errors.New("negative LPC shift")
+		}
+		coefs := make([]int64, order)
+		for i := range coefs {
+			if coefs[i], err = br.readSigned(uint(precision) + 1); err != nil {
+				return err
+			}
+		}
+		if err := readResidual(br, out, order); err != nil {
+			return err
+		}
+		for i := order; i < len(out); i++ {
+			var sum int64
+			for j, c := range coefs {
+				sum += c * out[i-1-j]
+			}
+			out[i] += sum >> uint(shift)
+		}
+	default:
+		return fmt.Errorf("reserved subframe type %d", kind)
+	}
+
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+// readWarmup reads the unpredicted first samples of a subframe.
+func readWarmup(br *bitReader, out []int64, order int, bps uint) error {
+	if order > len(out) {
+		return errors.New("predictor order larger than block")
+	}
+	for i := 0; i < order; i++ {
+		v, err := br.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		out[i] = v
+	}
+	return nil
+}
+
+// readResidual reads the Rice-coded residual into out[order:].
+func readResidual(br *bitReader, out []int64, order int) error {
+	method, err := br.read(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return errors.New("reserved residual coding method")
+	}
+	paramBits, escape := uint(4), uint64(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+	partitionOrder, err := br.read(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	perPartition := len(out) >> partitionOrder
+	if perPartition<<partitionOrder != len(out) || perPartition < order {
+		return errors.New("invalid residual partition order")
+	}
+
+	i := order
+	for p := 0; p < partitions; p++ {
+		end := (p + 1) * perPartition
+		param, err := br.read(paramBits)
+		if err != nil {
+			return err
+		}
+		if param == escape {
+			// Unencoded partition: fixed-width signed samples
+			width, err := br.read(5)
+			if err != nil {
+				return err
+			}
+			for ; i < end; i++ {
+				if out[i], err = br.readSigned(uint(width)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for ; i < end; i++ {
+			high, err := br.readUnary()
+			if err != nil {
+				return err
+			}
+			low, err := br.read(uint(param))
+			if err != nil {
+				return err
+			}
+			u := high<<param | low
+			out[i] = int64(u>>1) ^ -int64(u&1) // Zigzag decode
+		}
+	}
+	return nil
+}
+
+// restoreFixed undoes one of FLAC's fixed polynomial predictors in place.
+func restoreFixed(out []int64, order int) {
+	for i := order; i < len(out); i++ {
+		switch order {
+		case 1:
+			out[i] += out[i-1]
+		case 2:
+			out[i] += 2*out[i-1] - out[i-2]
+		case 3:
+			out[i] += 3*out[i-1] - 3*out[i-2] + out[i-3]
+		case 4:
+			out[i] += 4*out[i-1] - 6*out[i-2] + 4*out[i-3] - out[i-4]
+		}
+	}
+}
+
+// decorrelate turns stereo side-channel coding back into left and right.
+func decorrelate(assignment int, ch [][]int64) {
+	switch assignment {
+	case flacLeftSide:
+		for i := range ch[0] {
+			ch[1][i] = ch[0][i] - ch[1][i]
+		}
+	case flacSideRight:
+		for i := range ch[0] {
+			ch[0][i] += ch[1][i]
+		}
+	case flacMidSide:
+		for i := range ch[0] {
+			mid, side := ch[0][i]<<1|(ch[1][i]&1), ch[1][i]
+			ch[0][i] = (mid + side) >> 1
+			ch[1][i] = (mid - side) >> 1
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Containers recognised by Sniff. Only WAV, FLAC and MP3 can be probed in
+// detail; the others are recognised so mislabelled files get a useful message.
+const (
+	FormatWAV  = "wav"
+	FormatFLAC = "flac"
+	FormatMP3  = "mp3"
+	FormatM4A  = "m4a"
+	FormatOgg  = "ogg"
+	FormatAIFF = "aiff"
+)
+
+// Info is what the header says about an audio file.
+type Info struct {
+	Format     string
+	SampleRate int // Hz
+	BitDepth   int // Bits per sample; 0 for lossy formats
+	Channels   int
+	Float      bool // IEEE float samples (WAV only)
+
+	BPM       float64 // Tempo from embedded metadata; 0 if the file doesn't say
+	BPMSource string  // Where BPM came from, e.g. SourceACID
+}
+
+// ErrUnknownFormat is returned when the content isn't any recognised container.
+var ErrUnknownFormat = errors.New("not a recognised audio format")
+
+// sniffLen is how much of the file Sniff needs to see.
+const sniffLen = 12
+
+// Sniff identifies the container from the first bytes of a file, or returns
+// "" if it isn't recognised. An MP3 without an ID3 tag is only recognised by
+// its frame sync, so Probe double-checks the frame header.
+func Sniff(head []byte) string {
+	switch {
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return FormatWAV
+	case len(head) >= 4 && string(head[0:4]) == "fLaC":
+		return FormatFLAC
+	case len(head) >= 3 && string(head[0:3]) == "ID3":
+		return FormatMP3 // Could also be a tagged FLAC; Probe looks past the tag
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return FormatMP3
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return FormatM4A
+	case len(head) >= 4 && string(head[0:4]) == "OggS":
+		return FormatOgg
+	case len(head) >= 12 && string(head[0:4]) == "FORM" && (string(head[8:12]) == "AIFF" || string(head[8:12]) == "AIFC"):
+		return FormatAIFF
+	}
+	return ""
+}
+
+// Probe parses the header of a WAV, FLAC or MP3 file. size is the length of
+// the whole file and is used to detect truncation. WAV metadata chunks after
+// the audio data are read too, so the whole file may be consumed.
+func Probe(r io.Reader, size int64) (Info, error) {
+	st, err := open(r, size)
+	if err != nil {
+		return Info{}, err
+	}
+	if st.info.Format == FormatWAV {
+		// Many DAWs write acid/iXML chunks after the audio. This is best-effort:
+		// the header has already been checked for truncation.
+		if _, err := st.br.Discard(int(st.dataLen + st.dataLen%2)); err == nil {
+			readWAVTrailer(st.br, &st.info)
+		}
+	}
+	return st.info, nil
+}
+
+// stream is an audio file whose header has been parsed, positioned at the
+// start of its audio data.
+type stream struct {
+	br      *bufio.Reader
+	info    Info
+	dataLen int64 // WAV only: length of the data chunk
+}
+
+// open sniffs the container and parses its header.
+func open(r io.Reader, size int64) (*stream, error) {
+	if size == 0 {
+		return nil, errors.New("file is empty")
+	}
+	st := &stream{br: bufio.NewReaderSize(r, 64*1024)}
+	offset := int64(0)
+
+	head, _ := st.br.Peek(sniffLen)
+	format := Sniff(head)
+	var id3 map[string]string
+	if format == FormatMP3 && string(head[0:3]) == "ID3" {
+		// Read past the ID3v2 tag and sniff again: some tools tag FLAC files too
+		tagLen, frames, err := readID3(st.br)
+		if err != nil {
+			return nil, err
+		}
+		id3 = frames
+		offset += tagLen
+		head, _ = st.br.Peek(sniffLen)
+		if format = Sniff(head); format == "" {
+			return nil, fmt.Errorf("no audio after %d-byte ID3 tag: %w", tagLen, ErrUnknownFormat)
+		}
+	}
+
+	var err error
+	switch format {
+	case FormatWAV:
+		st.info, st.dataLen, err = probeWAV(st.br, size-offset)
+	case FormatFLAC:
+		st.info, err = probeFLAC(st.br, size-offset)
+	case FormatMP3:
+		st.info, err = probeMP3(st.br)
+	case "":
+		err = ErrUnknownFormat
+	default:
+		st.info = Info{Format: format}
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.info.setBPM(parseBPM(id3["TBPM"]), SourceID3)
+	return st, nil
+}
+
+// WAV format tags we can decode.
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// probeWAV walks the RIFF chunks up to "data", reading the "fmt " chunk on
+// the way. It returns the length of the data chunk, which starts at the
+// reader's position.
+func probeWAV(br *bufio.Reader, size int64) (Info, int64, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(br, riff[:]); err != nil {
+		return Info{}, 0, fmt.Errorf("truncated WAV header: %w", err)
+	}
+	if declared := int64(binary.LittleEndian.Uint32(riff[4:8])) + 8; declared > size {
+		return Info{}, 0, fmt.Errorf("truncated WAV: RIFF header declares %d bytes but file has %d", declared, size)
+	}
+
+	info := Info{Format: FormatWAV}
+	haveFmt := false
+	offset := int64(12)
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(br, chunk[:]); err != nil {
+			return Info{}, 0, fmt.Errorf("truncated WAV: no data chunk found: %w", err)
+		}
+		id := string(chunk[0:4])
+		length := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		offset += 8
+
+		switch id {
+		case "fmt ":
+			if length < 16 || length > 1024 {
+				return Info{}, 0, fmt.Errorf("invalid WAV: fmt chunk is %d bytes", length)
+			}
+			fmtChunk := make([]byte, length+length%2)
+			if _, err := io.ReadFull(br, fmtChunk); err != nil {
+				return Info{}, 0, fmt.Errorf("truncated WAV fmt chunk: %w", err)
+			}
+			tag := binary.LittleEndian.Uint16(fmtChunk[0:2])
+			if tag == wavFormatExtensible && length >= 26 {
+				tag = binary.LittleEndian.Uint16(fmtChunk[24:26]) // First bytes of the SubFormat GUID
+			}
+			switch tag {
+			case wavFormatPCM:
+			case wavFormatFloat:
+				info.Float = true
+			default:
+				return Info{}, 0, fmt.Errorf("unsupported WAV encoding 0x%04X (only PCM and IEEE float)", tag)
+			}
+			info.Channels = int(binary.LittleEndian.Uint16(fmtChunk[2:4]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:8]))
+			info.BitDepth = int(binary.LittleEndian.Uint16(fmtChunk[14:16]))
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return Info{}, 0, errors.New("invalid WAV: data chunk before fmt chunk")
+			}
+			if end := offset + length; end > size {
+				return Info{}, 0, fmt.Errorf("truncated WAV: data chunk needs %d bytes but file has %d", end, size)
+			}
+			return info, length, nil
+		default:
+			read, err := readWAVMeta(br, id, length, &info)
+			if err != nil {
+				return Info{}, 0, err
+			}
+			if !read {
+				// Chunks are padded to an even length
+				if _, err := br.Discard(int(length + length%2)); err != nil {
+					return Info{}, 0, fmt.Errorf("truncated WAV %q chunk: %w", id, err)
+				}
+			}
+		}
+		offset += length + length%2
+	}
+}
+
+// readWAVTrailer reads the metadata chunks that follow the data chunk.
+func readWAVTrailer(br *bufio.Reader, info *Info) {
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(br, chunk[:]); err != nil {
+			return
+		}
+		id := string(chunk[0:4])
+		length := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		read, err := readWAVMeta(br, id, length, info)
+		if err != nil {
+			return
+		}
+		if !read {
+			if _, err := br.Discard(int(length + length%2)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// flacVorbisComment is the metadata block type holding a FLAC file's tags.
+const flacVorbisComment = 4
+
+// probeFLAC reads STREAMINFO, skips the other metadata blocks and checks
+// that audio frames follow.
+func probeFLAC(br *bufio.Reader, size int64) (Info, error) {
+	if _, err := br.Discard(4); err != nil { // "fLaC"
+		return Info{}, err
+	}
+	info := Info{Format: FormatFLAC}
+	offset := int64(4)
+	for first := true; ; first = false {
+		var hdr [4]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return Info{}, fmt.Errorf("truncated FLAC metadata: %w", err)
+		}
+		last := hdr[0]&0x80 != 0
+		blockType := hdr[0] & 0x7F
+		length := int(hdr[1])<<16 | int(hdr[2])<<8 | int(hdr[3])
+		offset += 4 + int64(length)
+
+		if first {
+			if blockType != 0 || length < 34 {
+				return Info{}, errors.New("invalid FLAC: first metadata block is not STREAMINFO")
+			}
+			var si [34]byte
+			if _, err := io.ReadFull(br, si[:]); err != nil {
+				return Info{}, fmt.Errorf("truncated FLAC STREAMINFO: %w", err)
+			}
+			// Bytes 10-13: 20 bits sample rate, 3 bits channels-1, 5 bits bits-per-sample-1
+			packed := binary.BigEndian.Uint32(si[10:14])
+			info.SampleRate = int(packed >> 12)
+			info.Channels = int((packed>>9)&0x7) + 1
+			info.BitDepth = int((packed>>4)&0x1F) + 1
+			length -= 34
+		}
+		if blockType == flacVorbisComment && length <= maxMetaChunk {
+			block := make([]byte, length)
+			if _, err := io.ReadFull(br, block); err != nil {
+				return Info{}, fmt.Errorf("truncated FLAC metadata: %w", err)
+			}
+			info.setBPM(parseBPM(parseVorbisComments(block)["BPM"]), SourceVorbis)
+			length = 0
+		}
+		if _, err := br.Discard(length); err != nil {
+			return Info{}, fmt.Errorf("truncated FLAC metadata: %w", err)
+		}
+		if last {
+			break
+		}
+	}
+
+	if offset >= size {
+		return Info{}, errors.New("truncated FLAC: no audio frames after metadata")
+	}
+	sync, err := br.Peek(2)
+	if err != nil || sync[0] != 0xFF || sync[1]&0xFE != 0xF8 {
+		return Info{}, errors.New("invalid FLAC: metadata is not followed by an audio frame")
+	}
+	return info, nil
+}
+
+// MPEG audio header tables, indexed by version then sample-rate index.
+var mpegSampleRates = map[byte][3]int{
+	3: {44100, 48000, 32000}, // MPEG-1
+	2: {22050, 24000, 16000}, // MPEG-2
+	0: {11025, 12000, 8000},  // MPEG-2.5
+}
+
+// probeMP3 parses the first MPEG audio frame header.
+func probeMP3(br *bufio.Reader) (Info, error) {
+	hdr, err := br.Peek(4)
+	if err != nil {
+		return Info{}, fmt.Errorf("truncated MP3: %w", err)
+	}
+	if hdr[0] != 0xFF || hdr[1]&0xE0 != 0xE0 {
+		return Info{}, errors.New("invalid MP3: no MPEG frame sync at start of audio")
+	}
+	version := (hdr[1] >> 3) & 0x3
+	layer := (hdr[1] >> 1) & 0x3
+	bitrateIndex := hdr[2] >> 4
+	rateIndex := (hdr[2] >> 2) & 0x3
+	rates, ok := mpegSampleRates[version]
+	switch {
+	case !ok:
+		return Info{}, errors.New("invalid MP3: reserved MPEG version in frame header")
+	case layer != 1: // 01 is Layer III
+		return Info{}, fmt.Errorf("invalid MP3: frame is MPEG Layer %d, not Layer III", 4-layer)
+	case bitrateIndex == 0 || bitrateIndex == 15:
+		return Info{}, errors.New("invalid MP3: bad bitrate in frame header")
+	case rateIndex == 3:
+		return Info{}, errors.New("invalid MP3: reserved sample rate in frame header")
+	}
+	channels := 2
+	if hdr[3]>>6 == 3 {
+		channels = 1
+	}
+	return Info{Format: FormatMP3, SampleRate: rates[rateIndex], Channels: channels}, nil
+}
+
+// Describe names a container for messages, e.g. "MPEG-4 audio (m4a)".
+func Describe(format string) string {
+	switch format {
+	case FormatWAV:
+		return "WAV audio"
+	case FormatFLAC:
+		return "FLAC audio"
+	case FormatMP3:
+		return "MP3 audio"
+	case FormatM4A:
+		return "MPEG-4 audio (m4a)"
+	case FormatOgg:
+		return "Ogg audio"
+	case FormatAIFF:
+		return "AIFF audio"
+	}
+	return "unknown data"
+}
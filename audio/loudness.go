@@ -0,0 +1,304 @@
+package audio
+
+import (
+	"io"
+	"math"
+)
+
+// Loudness is the result of analysing a file's samples.
+type Loudness struct {
+	IntegratedLUFS float64 // ITU-R BS.1770-4 gated loudness; -Inf for silence
+	TruePeakDBTP   float64 // Highest oversampled peak in dB relative to full scale; -Inf for silence
+	ClippedSamples int64   // Samples at full scale or beyond
+}
+
+// Analyze decodes a WAV or FLAC file and measures its loudness.
+func Analyze(r io.Reader, size int64) (Info, Loudness, error) {
+	dec, err := NewDecoder(r, size)
+	if err != nil {
+		return Info{}, Loudness{}, err
+	}
+	meter := NewMeter(dec.Info())
+	if err := dec.Decode(func(block [][]float64) error {
+		meter.Add(block)
+		return nil
+	}); err != nil {
+		return dec.Info(), Loudness{}, err
+	}
+	return dec.Info(), meter.Result(), nil
+}
+
+// BS.1770 gating constants.
+const (
+	absoluteGateLUFS = -70.0
+	relativeGateLU   = -10.0
+	subBlocksPerGate = 4 // 400 ms gating blocks made of 100 ms steps (75% overlap)
+)
+
+// Meter measures integrated loudness, true peak and clipping as samples
+// are added.
+type Meter struct {
+	weights []float64 // Per-channel weighting (surrounds count 1.41, LFE 0)
+	filters []kWeighting
+	peaks   []truePeak
+
+	hop       int       // Samples per 100 ms step
+	hopFill   int       // Samples in the current step so far
+	energy    []float64 // Per-channel sum of squares in the current step
+	subBlocks [][]float64
+
+	clipAt  float64 // Magnitude counted as clipped
+	clipped int64
+}
+
+// NewMeter sets up a meter for the stream described by info.
+func NewMeter(info Info) *Meter {
+	m := &Meter{
+		hop:    int(math.Round(float64(info.SampleRate) / 10)),
+		energy: make([]float64, info.Channels),
+		clipAt: 1,
+	}
+	if m.hop < 1 {
+		m.hop = 1
+	}
+	// Integer formats can't reach +1.0; their largest positive value is full scale
+	if !info.Float && info.BitDepth > 1 {
+		m.clipAt = 1 - math.Ldexp(1, -(info.BitDepth-1))
+	}
+	for c := 0; c < info.Channels; c++ {
+		m.weights = append(m.weights, channelWeight(c, info.Channels))
+		m.filters = append(m.filters, newKWeighting(float64(info.SampleRate)))
+		m.peaks = append(m.peaks, newTruePeak(info.SampleRate))
+	}
+	return m
+}
+
+// channelWeight follows BS.1770 for up to 5.1 in the usual WAV/FLAC order
+// (L, R, C, LFE, Ls, Rs).
+func channelWeight(c, channels int) float64 {
+	if channels < 5 {
+		return 1
+	}
+	switch c {
+	case 3:
+		return 0 // LFE
+	case 4, 5:
+		return 1.41
+	}
+	return 1
+}
+
+// Add feeds one block of samples (one slice per channel) to the meter.
+func (m *Meter) Add(block [][]float64) {
+	if len(block) == 0 {
+		return
+	}
+	for i := range block[0] {
+		for c := range block {
+			x := block[c][i]
+			if x >= m.clipAt || x <= -1 {
+				m.clipped++
+			}
+			m.peaks[c].add(x)
+			y := m.filters[c].process(x)
+			m.energy[c] += y * y
+		}
+		m.hopFill++
+		if m.hopFill == m.hop {
+			m.subBlocks = append(m.subBlocks, m.energy)
+			m.energy = make([]float64, len(m.energy))
+			m.hopFill = 0
+		}
+	}
+}
+
+// Result computes the measurements for everything added so far.
+func (m *Meter) Result() Loudness {
+	res := Loudness{IntegratedLUFS: math.Inf(-1), TruePeakDBTP: math.Inf(-1), ClippedSamples: m.clipped}
+
+	peak := 0.0
+	for _, p := range m.peaks {
+		peak = math.Max(peak, p.max)
+	}
+	if peak > 0 {
+		res.TruePeakDBTP = 20 * math.Log10(peak)
+	}
+
+	// Mean square per channel for each 400 ms gating block
+	blockLen := float64(m.hop * subBlocksPerGate)
+	var blocks [][]float64
+	for j := 0; j+subBlocksPerGate <= len(m.subBlocks); j++ {
+		z := make([]float64, len(m.weights))
+		for _, sub := range m.subBlocks[j : j+subBlocksPerGate] {
+			for c, e := range sub {
+				z[c] += e / blockLen
+			}
+		}
+		blocks = append(blocks, z)
+	}
+
+	// Absolute gate, then a relative gate 10 LU below the absolutely gated loudness
+	gated := m.gate(blocks, absoluteGateLUFS)
+	if len(gated) == 0 {
+		return res
+	}
+	relative := m.loudness(mean(gated)) + relativeGateLU
+	gated = m.gate(gated, relative)
+	if len(gated) == 0 {
+		return res
+	}
+	res.IntegratedLUFS = m.loudness(mean(gated))
+	return res
+}
+
+// loudness converts weighted per-channel mean squares to LUFS.
+func (m *Meter) loudness(z []float64) float64 {
+	sum := 0.0
+	for c, v := range z {
+		sum += m.weights[c] * v
+	}
+	if sum <= 0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + 10*math.Log10(sum)
+}
+
+// gate keeps the blocks louder than threshold.
+func (m *Meter) gate(blocks [][]float64, threshold float64) [][]float64 {
+	var kept [][]float64
+	for _, z := range blocks {
+		if m.loudness(z) > threshold {
+			kept = append(kept, z)
+		}
+	}
+	return kept
+}
+
+// mean averages per-channel values across blocks.
+func mean(blocks [][]float64) []float64 {
+	avg := make([]float64, len(blocks[0]))
+	for _, z := range blocks {
+		for c, v := range z {
+			avg[c] += v
+		}
+	}
+	for c := range avg {
+		avg[c] /= float64(len(blocks))
+	}
+	return avg
+}
+
+// biquad is a second-order IIR section (transposed direct form II).
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting is the BS.1770 pre-filter: a high-shelf followed by a high-pass.
+type kWeighting struct {
+	shelf, highPass biquad
+}
+
+// newKWeighting derives the filter coefficients for any sample rate. At
+// 48 kHz they match the values tabulated in BS.1770.
+func newKWeighting(rate float64) kWeighting {
+	var k kWeighting
+
+	// Stage 1: high shelf (+4 dB above ~1.5 kHz, modelling the head)
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	K := math.Tan(math.Pi * f0 / rate)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + K/q + K*K
+	k.shelf = biquad{
+		b0: (vh + vb*K/q + K*K) / a0,
+		b1: 2 * (K*K - vh) / a0,
+		b2: (vh - vb*K/q + K*K) / a0,
+		a1: 2 * (K*K - 1) / a0,
+		a2: (1 - K/q + K*K) / a0,
+	}
+
+	// Stage 2: RLB high-pass at ~38 Hz
+	f0, q = 38.13547087602444, 0.5003270373238773
+	K = math.Tan(math.Pi * f0 / rate)
+	a0 = 1 + K/q + K*K
+	k.highPass = biquad{
+		b0: 1, b1: -2, b2: 1,
+		a1: 2 * (K*K - 1) / a0,
+		a2: (1 - K/q + K*K) / a0,
+	}
+	return k
+}
+
+func (k *kWeighting) process(x float64) float64 {
+	return k.highPass.process(k.shelf.process(x))
+}
+
+// truePeakTaps is the length of each polyphase branch of the interpolator.
+const truePeakTaps = 12
+
+// truePeak tracks the highest inter-sample peak of one channel by
+// oversampling with a windowed-sinc interpolator (BS.1770-4 Annex 2).
+type truePeak struct {
+	phases  [][]float64 // phases[p][m] weights history[m] for output phase p
+	history []float64   // Most recent input first
+	max     float64
+}
+
+// newTruePeak oversamples 4x below 96 kHz and 2x below 192 kHz, which keeps
+// the interpolated rate at 192 kHz or more.
+func newTruePeak(rate int) truePeak {
+	factor := 1
+	switch {
+	case rate < 96000:
+		factor = 4
+	case rate < 192000:
+		factor = 2
+	}
+	tp := truePeak{history: make([]float64, truePeakTaps)}
+	n := factor * truePeakTaps
+	center := float64(n-1) / 2
+	for p := 0; p < factor; p++ {
+		phase := make([]float64, truePeakTaps)
+		sum := 0.0
+		for m := range phase {
+			i := p + factor*m
+			t := (float64(i) - center) / float64(factor)
+			window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i+1)/float64(n+1))
+			phase[m] = sinc(t) * window
+			sum += phase[m]
+		}
+		for m := range phase {
+			phase[m] /= sum // Unity gain at DC for every phase
+		}
+		tp.phases = append(tp.phases, phase)
+	}
+	return tp
+}
+
+func (tp *truePeak) add(x float64) {
+	copy(tp.history[1:], tp.history[:len(tp.history)-1])
+	tp.history[0] = x
+	tp.max = math.Max(tp.max, math.Abs(x))
+	for _, phase := range tp.phases {
+		y := 0.0
+		for m, h := range phase {
+			y += h * tp.history[m]
+		}
+		tp.max = math.Max(tp.max, math.Abs(y))
+	}
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
@@ -0,0 +1,246 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Where an embedded tempo was found, for messages.
+const (
+	SourceACID   = "WAV acid chunk"
+	SourceIXML   = "WAV iXML chunk"
+	SourceBEXT   = "WAV bext description"
+	SourceID3    = "ID3 TBPM frame"
+	SourceVorbis = "FLAC BPM comment"
+)
+
+// maxMetaChunk caps how much of a metadata chunk we'll buffer; anything
+// bigger is almost certainly embedded artwork and is skipped.
+const maxMetaChunk = 1 << 20
+
+// sourceRank orders the tempo sources from most to least trustworthy: the
+// acid chunk is what DAWs read back, free text in bext is a last resort.
+var sourceRank = map[string]int{SourceACID: 1, SourceIXML: 2, SourceID3: 3, SourceVorbis: 3, SourceBEXT: 4}
+
+// setBPM records a tempo unless a more trustworthy source already did.
+func (info *Info) setBPM(bpm float64, source string) {
+	if bpm <= 0 || bpm > 999 || (info.BPM > 0 && sourceRank[info.BPMSource] <= sourceRank[source]) {
+		return
+	}
+	info.BPM = bpm
+	info.BPMSource = source
+}
+
+// parseBPM reads a tempo such as "140" or "139.98", ignoring anything after
+// the number (some taggers write "140 BPM").
+func parseBPM(s string) float64 {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+		end++
+	}
+	bpm, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0
+	}
+	return bpm
+}
+
+// readID3 reads an ID3v2 tag and returns its total length and text frames,
+// keyed by frame ID (e.g. "TBPM"). Frames other than text frames are skipped.
+func readID3(br *bufio.Reader) (int64, map[string]string, error) {
+	var hdr [10]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return 0, nil, fmt.Errorf("truncated ID3 tag: %w", err)
+	}
+	// Tag size is a 28-bit "syncsafe" integer (7 bits per byte)
+	n := int64(syncsafe(hdr[6:10]))
+	total := 10 + n
+	if hdr[5]&0x10 != 0 {
+		total += 10 // Footer present
+	}
+	if n > maxMetaChunk {
+		// Artwork-heavy tag: skip it rather than buffering it all
+		if _, err := br.Discard(int(total - 10)); err != nil {
+			return 0, nil, fmt.Errorf("truncated ID3 tag: %w", err)
+		}
+		return total, nil, nil
+	}
+	body := make([]byte, total-10)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return 0, nil, fmt.Errorf("truncated ID3 tag: %w", err)
+	}
+	return total, parseID3Frames(hdr[3], hdr[5], body[:n]), nil
+}
+
+// parseID3Frames extracts the text frames from the body of an ID3v2 tag.
+// Tag-level unsynchronisation is rare in practice and isn't undone.
+func parseID3Frames(version, flags byte, body []byte) map[string]string {
+	frames := map[string]string{}
+	if flags&0x40 != 0 && len(body) >= 4 {
+		// Skip the extended header; v2.4 counts its own size field, v2.3 doesn't
+		size := int(binary.BigEndian.Uint32(body[0:4])) + 4
+		if version >= 4 {
+			size = int(syncsafe(body[0:4]))
+		}
+		if size > len(body) {
+			return frames
+		}
+		body = body[size:]
+	}
+
+	idLen, hdrLen := 4, 10
+	if version == 2 {
+		idLen, hdrLen = 3, 6 // v2.2 uses three-letter IDs, e.g. "TBP"
+	}
+	for len(body) >= hdrLen && body[0] != 0 {
+		id := string(body[:idLen])
+		var size int
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		default:
+			size = int(syncsafe(body[4:8]))
+		}
+		if size < 0 || hdrLen+size > len(body) {
+			break
+		}
+		if id[0] == 'T' && size > 0 {
+			if id == "TBP" {
+				id = "TBPM"
+			}
+			frames[id] = decodeID3Text(body[hdrLen : hdrLen+size])
+		}
+		body = body[hdrLen+size:]
+	}
+	return frames
+}
+
+// decodeID3Text decodes a text frame: an encoding byte, then the text.
+// Multiple values are separated by NULs; only the first is kept.
+func decodeID3Text(b []byte) string {
+	enc, b := b[0], b[1:]
+	var s string
+	switch enc {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		bigEndian := enc == 2
+		if len(b) >= 2 && (b[0] == 0xFE && b[1] == 0xFF || b[0] == 0xFF && b[1] == 0xFE) {
+			bigEndian = b[0] == 0xFE
+			b = b[2:]
+		}
+		units := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			if bigEndian {
+				units = append(units, binary.BigEndian.Uint16(b[i:]))
+			} else {
+				units = append(units, binary.LittleEndian.Uint16(b[i:]))
+			}
+		}
+		s = string(utf16.Decode(units))
+	case 3: // UTF-8
+		s = string(b)
+	default: // ISO-8859-1
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		s = string(runes)
+	}
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+// parseVorbisComments extracts the fields of a FLAC VORBIS_COMMENT block,
+// keyed by upper-case field name. Repeated fields keep their first value.
+func parseVorbisComments(b []byte) map[string]string {
+	fields := map[string]string{}
+	if len(b) < 4 {
+		return fields
+	}
+	vendorLen := int(binary.LittleEndian.Uint32(b[0:4]))
+	if 4+vendorLen+4 > len(b) {
+		return fields
+	}
+	b = b[4+vendorLen:]
+	count := int(binary.LittleEndian.Uint32(b[0:4]))
+	b = b[4:]
+	for i := 0; i < count && len(b) >= 4; i++ {
+		n := int(binary.LittleEndian.Uint32(b[0:4]))
+		if n < 0 || 4+n > len(b) {
+			break
+		}
+		if name, value, ok := strings.Cut(string(b[4:4+n]), "="); ok {
+			name = strings.ToUpper(name)
+			if _, seen := fields[name]; !seen {
+				fields[name] = value
+			}
+		}
+		b = b[4+n:]
+	}
+	return fields
+}
+
+// Free-text tempo patterns: iXML elements and "120 BPM" / "BPM: 120" in a
+// bext description.
+var (
+	ixmlTempoRegex = regexp.MustCompile(`(?i)<(?:BPM|TEMPO)>\s*([0-9]+(?:\.[0-9]+)?)\s*</`)
+	textTempoRegex = regexp.MustCompile(`(?i)(?:\bbpm\s*[:=]?\s*([0-9]+(?:\.[0-9]+)?))|(?:([0-9]+(?:\.[0-9]+)?)\s*bpm\b)`)
+)
+
+// readWAVMeta looks for a tempo in a RIFF metadata chunk. It reports whether
+// the chunk was one it reads (and so has been consumed).
+func readWAVMeta(br *bufio.Reader, id string, length int64, info *Info) (bool, error) {
+	switch id {
+	case "acid", "bext", "iXML", "id3 ", "ID3 ":
+	default:
+		return false, nil
+	}
+	if length > maxMetaChunk {
+		return false, nil
+	}
+	chunk := make([]byte, length+length%2)
+	if _, err := io.ReadFull(br, chunk); err != nil {
+		return true, fmt.Errorf("truncated WAV %q chunk: %w", id, err)
+	}
+	chunk = chunk[:length]
+
+	switch id {
+	case "acid":
+		// flags, root note, two reserved fields, beat count, meter; then tempo as float32
+		if len(chunk) >= 24 {
+			info.setBPM(float64(math.Float32frombits(binary.LittleEndian.Uint32(chunk[20:24]))), SourceACID)
+		}
+	case "iXML":
+		if m := ixmlTempoRegex.FindSubmatch(chunk); m != nil {
+			info.setBPM(parseBPM(string(m[1])), SourceIXML)
+		}
+	case "bext":
+		// The first 256 bytes are the free-text Description
+		desc := chunk[:min(len(chunk), 256)]
+		if m := textTempoRegex.FindSubmatch(desc); m != nil {
+			info.setBPM(parseBPM(string(m[1])+string(m[2])), SourceBEXT)
+		}
+	case "id3 ", "ID3 ":
+		if len(chunk) >= 10 && string(chunk[0:3]) == "ID3" {
+			n := min(int(syncsafe(chunk[6:10])), len(chunk)-10)
+			frames := parseID3Frames(chunk[3], chunk[5], chunk[10:10+n])
+			info.setBPM(parseBPM(frames["TBPM"]), SourceID3)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,329 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tags are the fields the tag command manages. Empty fields are left out of
+// the file, and any existing value for them is removed.
+type Tags struct {
+	Title    string
+	Artists  []string
+	BPM      int
+	Producer string // Credited as "prod. by": an ID3 TIPL producer entry or a Vorbis PRODUCER comment
+}
+
+// vorbisVendor identifies us in VORBIS_COMMENT blocks we create from scratch.
+const vorbisVendor = "git-monitor-app"
+
+// ID3 frames owned by Tags. IPLS is the ID3v2.3 predecessor of TIPL and is
+// dropped when a tag is upgraded.
+var ownedID3Frames = map[string]bool{"TIT2": true, "TPE1": true, "TBPM": true, "TIPL": true, "IPLS": true}
+
+// Vorbis comment fields owned by Tags.
+var ownedVorbisFields = map[string]bool{"TITLE": true, "ARTIST": true, "BPM": true, "PRODUCER": true}
+
+// WriteTags copies an MP3 or FLAC file from r to w with its tags set to tags.
+// Tags the file has for other fields are kept. MP3s get an ID3v2.4 tag (older
+// tags are upgraded); FLACs get their VORBIS_COMMENT block rewritten. The
+// audio itself is copied untouched.
+func WriteTags(r io.Reader, w io.Writer, tags Tags) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(sniffLen)
+	switch Sniff(head) {
+	case FormatMP3:
+		return writeMP3Tags(br, w, tags)
+	case FormatFLAC:
+		return writeFLACTags(br, w, tags)
+	case "":
+		return ErrUnknownFormat
+	default:
+		return fmt.Errorf("tagging %s isn't supported", Describe(Sniff(head)))
+	}
+}
+
+// id3Frame is a raw ID3v2.4 frame: ID and decoded (not unsynchronised) data.
+type id3Frame struct {
+	id   string
+	data []byte
+}
+
+// writeMP3Tags replaces the leading ID3v2 tag, if any, with an ID3v2.4 tag
+// holding the frames we keep plus ours.
+func writeMP3Tags(br *bufio.Reader, w io.Writer, tags Tags) error {
+	var kept []id3Frame
+	if head, _ := br.Peek(3); string(head) == "ID3" {
+		frames, err := readID3Raw(br)
+		if err != nil {
+			return err
+		}
+		for _, f := range frames {
+			if !ownedID3Frames[f.id] {
+				kept = append(kept, f)
+			}
+		}
+		if head, _ := br.Peek(sniffLen); Sniff(head) == FormatFLAC {
+			return errors.New("file is FLAC with an ID3 tag in front; remove the ID3 tag first")
+		}
+	}
+	if _, err := probeMP3(br); err != nil {
+		return err
+	}
+
+	frames := kept
+	if tags.Title != "" {
+		frames = append(frames, id3Frame{"TIT2", id3Text(tags.Title)})
+	}
+	if len(tags.Artists) > 0 {
+		frames = append(frames, id3Frame{"TPE1", id3Text(tags.Artists...)}) // v2.4 allows several values
+	}
+	if tags.BPM > 0 {
+		frames = append(frames, id3Frame{"TBPM", id3Text(strconv.Itoa(tags.BPM))})
+	}
+	if tags.Producer != "" {
+		frames = append(frames, id3Frame{"TIPL", id3Text("producer", tags.Producer)}) // Role/name pairs
+	}
+
+	var body bytes.Buffer
+	for _, f := range frames {
+		body.WriteString(f.id)
+		body.Write(syncsafeBytes(len(f.data)))
+		body.Write([]byte{0, 0}) // No flags: frames are stored plainly
+		body.Write(f.data)
+	}
+	if body.Len() > 0 {
+		header := append([]byte{'I', 'D', '3', 4, 0, 0}, syncsafeBytes(body.Len())...)
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		if _, err := body.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	_, err := io.Copy(w, br)
+	return err
+}
+
+// readID3Raw reads an ID3v2.2-2.4 tag and returns its frames in ID3v2.4
+// form. Frames we can't carry over safely (compressed or encrypted ones, and
+// everything in a v2.2 tag) are dropped.
+func readID3Raw(br *bufio.Reader) ([]id3Frame, error) {
+	var hdr [10]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, fmt.Errorf("truncated ID3 tag: %w", err)
+	}
+	version, flags := hdr[3], hdr[5]
+	n := int(syncsafe(hdr[6:10]))
+	if flags&0x10 != 0 {
+		n += 10 // Footer
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return nil, fmt.Errorf("truncated ID3 tag: %w", err)
+	}
+	if version < 3 || version > 4 {
+		return nil, nil
+	}
+	if version == 3 && flags&0x80 != 0 {
+		body = resync(body) // v2.3 unsynchronises the whole tag
+	}
+	if flags&0x40 != 0 && len(body) >= 4 {
+		size := int(binary.BigEndian.Uint32(body[0:4])) + 4
+		if version == 4 {
+			size = int(syncsafe(body[0:4]))
+		}
+		if size > len(body) {
+			return nil, errors.New("invalid ID3 tag: extended header is larger than the tag")
+		}
+		body = body[size:]
+	}
+
+	var frames []id3Frame
+	for len(body) >= 10 && body[0] != 0 {
+		id := string(body[0:4])
+		size := int(binary.BigEndian.Uint32(body[4:8]))
+		if version == 4 {
+			size = int(syncsafe(body[4:8]))
+		}
+		if size < 0 || 10+size > len(body) {
+			return nil, fmt.Errorf("invalid ID3 tag: frame %q overruns the tag", id)
+		}
+		format := body[9]
+		data := body[10 : 10+size]
+		body = body[10+size:]
+
+		if version == 3 {
+			if format&0xE0 != 0 { // Compression, encryption, grouping
+				continue
+			}
+		} else {
+			if format&0x4C != 0 { // Grouping, compression, encryption
+				continue
+			}
+			if format&0x02 != 0 || flags&0x80 != 0 {
+				data = resync(data)
+			}
+			if format&0x01 != 0 { // Data length indicator
+				if len(data) < 4 {
+					continue
+				}
+				data = data[4:]
+			}
+		}
+		frames = append(frames, id3Frame{id, data})
+	}
+	return frames, nil
+}
+
+// resync undoes ID3 unsynchronisation: every 0xFF 0x00 becomes 0xFF.
+func resync(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xFF, 0x00}, []byte{0xFF})
+}
+
+// id3Text builds a UTF-8 text frame; several values are NUL-separated.
+func id3Text(values ...string) []byte {
+	return append([]byte{3}, strings.Join(values, "\x00")...)
+}
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// writeFLACTags rewrites the metadata blocks with our fields in the
+// VORBIS_COMMENT block, adding one after STREAMINFO if there isn't one.
+func writeFLACTags(br *bufio.Reader, w io.Writer, tags Tags) error {
+	if _, err := br.Discard(4); err != nil { // "fLaC"
+		return err
+	}
+	type block struct {
+		kind byte
+		data []byte
+	}
+	var blocks []block
+	commentAt := -1
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return fmt.Errorf("truncated FLAC metadata: %w", err)
+		}
+		kind := hdr[0] & 0x7F
+		data := make([]byte, int(hdr[1])<<16|int(hdr[2])<<8|int(hdr[3]))
+		if _, err := io.ReadFull(br, data); err != nil {
+			return fmt.Errorf("truncated FLAC metadata: %w", err)
+		}
+		if len(blocks) == 0 && kind != 0 {
+			return errors.New("invalid FLAC: first metadata block is not STREAMINFO")
+		}
+		if kind == flacVorbisComment && commentAt < 0 {
+			commentAt = len(blocks)
+		}
+		blocks = append(blocks, block{kind, data})
+		if hdr[0]&0x80 != 0 {
+			break
+		}
+	}
+	if sync, err := br.Peek(2); err != nil || sync[0] != 0xFF || sync[1]&0xFE != 0xF8 {
+		return errors.New("invalid FLAC: metadata is not followed by an audio frame")
+	}
+
+	vendor, comments := vorbisVendor, []string(nil)
+	if commentAt >= 0 {
+		var err error
+		vendor, comments, err = splitVorbisComments(blocks[commentAt].data)
+		if err != nil {
+			return err
+		}
+	} else {
+		commentAt = 1
+		blocks = append(blocks[:1], append([]block{{kind: flacVorbisComment}}, blocks[1:]...)...)
+	}
+
+	var kept []string
+	for _, c := range comments {
+		name, _, _ := strings.Cut(c, "=")
+		if !ownedVorbisFields[strings.ToUpper(name)] {
+			kept = append(kept, c)
+		}
+	}
+	if tags.Title != "" {
+		kept = append(kept, "TITLE="+tags.Title)
+	}
+	for _, artist := range tags.Artists {
+		kept = append(kept, "ARTIST="+artist)
+	}
+	if tags.BPM > 0 {
+		kept = append(kept, "BPM="+strconv.Itoa(tags.BPM))
+	}
+	if tags.Producer != "" {
+		kept = append(kept, "PRODUCER="+tags.Producer)
+	}
+	blocks[commentAt].data = joinVorbisComments(vendor, kept)
+
+	out := bufio.NewWriter(w)
+	out.WriteString("fLaC")
+	for i, b := range blocks {
+		if len(b.data) >= 1<<24 {
+			return fmt.Errorf("FLAC metadata block %d is too large", i)
+		}
+		kind := b.kind
+		if i == len(blocks)-1 {
+			kind |= 0x80
+		}
+		out.Write([]byte{kind, byte(len(b.data) >> 16), byte(len(b.data) >> 8), byte(len(b.data))})
+		out.Write(b.data)
+	}
+	if _, err := io.Copy(out, br); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// splitVorbisComments returns the vendor string and raw "NAME=value" comments
+// of a VORBIS_COMMENT block, in order.
+func splitVorbisComments(b []byte) (string, []string, error) {
+	bad := errors.New("invalid FLAC: malformed VORBIS_COMMENT block")
+	if len(b) < 4 {
+		return "", nil, bad
+	}
+	vendorLen := int(binary.LittleEndian.Uint32(b[0:4]))
+	if 4+vendorLen+4 > len(b) {
+		return "", nil, bad
+	}
+	vendor := string(b[4 : 4+vendorLen])
+	b = b[4+vendorLen:]
+	count := int(binary.LittleEndian.Uint32(b[0:4]))
+	b = b[4:]
+	var comments []string
+	for i := 0; i < count; i++ {
+		if len(b) < 4 {
+			return "", nil, bad
+		}
+		n := int(binary.LittleEndian.Uint32(b[0:4]))
+		if n < 0 || 4+n > len(b) {
+			return "", nil, bad
+		}
+		comments = append(comments, string(b[4:4+n]))
+		b = b[4+n:]
+	}
+	return vendor, comments, nil
+}
+
+// joinVorbisComments encodes a VORBIS_COMMENT block.
+func joinVorbisComments(vendor string, comments []string) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(len(vendor)))
+	b.WriteString(vendor)
+	binary.Write(&b, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&b, binary.LittleEndian, uint32(len(c)))
+		b.WriteString(c)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+// ErrNotFound is returned by Backend.Get and Backend.Stat when the key doesn't exist.
+var ErrNotFound = errors.New("backup object not found")
+
+// ObjectInfo describes a stored backup object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Backend is a storage target for backup objects. Keys are slash-separated
+// paths (e.g. "git-backups/commit-<hash>.tar.gz"), independent of the target.
+type Backend interface {
+	// Put stores everything read from body under key, replacing any existing object.
+	Put(ctx context.Context, key string, body io.Reader) error
+	// Get opens the object stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns every object whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key string) error
+	// Stat returns metadata for the object stored under key.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Location renders key as a human-readable location for log messages.
+	Location(key string) string
+}
+
+// NewBackend creates the backend selected by cfg.Type, encrypting objects
+// client-side if a key or passphrase is configured.
+func NewBackend(ctx context.Context, cfg *config.BackupConfig) (Backend, error) {
+	var backend Backend
+	var err error
+	switch cfg.Type {
+	case config.BackupTypeS3, "":
+		backend, err = NewS3Backend(ctx, cfg)
+	case config.BackupTypeLocal:
+		backend, err = NewLocalBackend(cfg.LocalPath)
+	default:
+		return nil, fmt.Errorf("backup config error: unknown backup type %q (expected %q or %q)", cfg.Type, config.BackupTypeS3, config.BackupTypeLocal)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := loadEncryptionKey(ctx, backend, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("backup config error: %w", err)
+	}
+	if key != nil {
+		backend = &encryptedBackend{Backend: backend, key: key, allowPlain: cfg.AllowUnencrypted}
+	}
+	return backend, nil
+}
+
+// ObjectKey joins the configured prefix and an object name into a backend key.
+func ObjectKey(cfg *config.BackupConfig, name string) string {
+	cleanPrefix := strings.Trim(cfg.Prefix, "/")
+	if cleanPrefix == "" {
+		return name
+	}
+	return fmt.Sprintf("%s/%s", cleanPrefix, name)
+}
+
+// CleanupUploads aborts incomplete multipart uploads under prefix that will
+// never be resumed, returning a line per upload. Only S3 has them.
+func CleanupUploads(ctx context.Context, backend Backend, prefix string, dryRun bool) ([]string, error) {
+	if encrypted, ok := backend.(*encryptedBackend); ok {
+		backend = encrypted.Backend
+	}
+	if s3Backend, ok := backend.(*S3Backend); ok {
+		return s3Backend.CleanupUploads(ctx, prefix, dryRun)
+	}
+	return nil, nil
+}
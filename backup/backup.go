@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"fmt"
@@ -11,11 +12,6 @@ import (
 
 	// Use the actual module path defined in your go.mod file
 	"git-monitor-app/config" // Adjust if your module name is different
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awsConfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 // --- Gzip Pipe Helper ---
@@ -57,80 +53,84 @@ func GzipPipe(src io.Reader) (io.ReadCloser, error) {
 
 // --- Backup Functionality ---
 
-// RunBackup performs the backup of a specific commit to S3/Wasabi.
-func RunBackup(repoPath, commitHash string, cfg *config.BackupConfig) error {
+// RunBackup performs the backup of a specific commit to backend (S3/Wasabi
+// or a local directory, built by NewBackend from cfg), as a tarball, a bundle
+// or deduplicated blobs depending on the configured mode.
+func RunBackup(backend Backend, repoPath, commitHash string, cfg *config.BackupConfig) error {
 	log.Printf("Backup: Starting backup process for commit %s", commitHash)
 
-	// Basic validation of essential config
-	if cfg.Bucket == "" {
-		return fmt.Errorf("backup config error: S3 bucket name is required")
-	}
-
-	// --- Configure AWS SDK ---
-	log.Println("Backup: Configuring S3 client...")
-	sdkConfigOptions := []func(*awsConfig.LoadOptions) error{}
-
-	// 1. Custom Endpoint Resolver (Essential for Wasabi/S3 Compatible)
-	if cfg.EndpointURL != "" {
-		customResolver := aws.EndpointResolverWithOptionsFunc( // Use newer resolver type
-			func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-				if service == s3.ServiceID {
-					ep := aws.Endpoint{
-						URL:           cfg.EndpointURL,
-						SigningRegion: cfg.Region, // Use region from config for signing
-					}
-					return ep, nil
-				}
-				return aws.Endpoint{}, &aws.EndpointNotFoundError{}
-			})
-		sdkConfigOptions = append(sdkConfigOptions, awsConfig.WithEndpointResolverWithOptions(customResolver))
-	}
-
-	// 2. Region
-	if cfg.Region != "" {
-		sdkConfigOptions = append(sdkConfigOptions, awsConfig.WithRegion(cfg.Region))
+	// --- Upload ---
+	var bundle *BundleEntry
+	var location string
+	var err error
+	switch cfg.Mode {
+	case config.BackupModeBundle:
+		bundle, err = uploadBundle(context.TODO(), backend, cfg, repoPath, commitHash)
+		if err != nil {
+			return err
+		}
+		if bundle == nil {
+			return nil // Already backed up as part of an earlier bundle
+		}
+		location = backend.Location(ObjectKey(cfg, bundle.Name))
+	case config.BackupModeDedup:
+		if location, err = uploadTree(context.TODO(), backend, cfg, repoPath, commitHash); err != nil {
+			return err
+		}
+	case config.BackupModeArchive, "":
+		if location, err = uploadArchive(backend, cfg, repoPath, commitHash); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("backup config error: unknown mode %q", cfg.Mode)
 	}
-
-	// 3. Credentials
-	if cfg.AccessKeyID != "" && cfg.SecretKey != "" {
-		log.Println("Backup: Using static credentials from config file.")
-		staticCreds := credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretKey, "")
-		sdkConfigOptions = append(sdkConfigOptions, awsConfig.WithCredentialsProvider(staticCreds))
+	log.Printf("Backup: Upload SUCCEEDED for %s", location)
+
+	// --- Metadata ---
+	// Describes the projects in the backup; the backup itself is already
+	// safe, so a failure here is only logged.
+	if meta, err := BuildMetadata(repoPath, commitHash); err != nil {
+		log.Printf("Backup Warning: Failed to collect metadata for commit %s: %v", commitHash, err)
+	} else if err := PutMetadata(context.TODO(), backend, cfg, meta); err != nil {
+		log.Printf("Backup Warning: %v", err)
 	} else {
-		log.Println("Backup: Using default AWS credential chain.")
+		log.Printf("Backup: Stored metadata for %d project(s)", len(meta.Projects))
 	}
 
-	// Load the final configuration
-	sdkConfig, err := awsConfig.LoadDefaultConfig(context.TODO(), sdkConfigOptions...)
-	if err != nil {
-		return fmt.Errorf("failed to load AWS SDK config: %w", err)
-	}
-	if cfg.Region != "" && sdkConfig.Region != cfg.Region {
-		sdkConfig.Region = cfg.Region
-		log.Printf("Backup: Explicitly setting region in loaded SDK config: %s", cfg.Region)
-	}
-
-	// Create S3 client
-	s3Client := s3.NewFromConfig(sdkConfig)
-
-	// --- Construct S3 Key ---
-	backupFilename := fmt.Sprintf("commit-%s.tar.gz", commitHash)
-	s3Key := backupFilename
-	if cfg.Prefix != "" {
-		cleanPrefix := strings.Trim(cfg.Prefix, "/")
-		if cleanPrefix != "" {
-			s3Key = fmt.Sprintf("%s/%s", cleanPrefix, backupFilename)
+	// --- Optional Post-Upload Verification ---
+	if cfg.Verify && bundle != nil {
+		if err := VerifyBundle(context.TODO(), backend, cfg, repoPath, *bundle); err != nil {
+			return fmt.Errorf("post-upload verification failed for %s: %w", location, err)
+		}
+		log.Printf("Backup: Verified bundle %s", location)
+	} else if cfg.Verify {
+		report, err := VerifyCommit(context.TODO(), backend, cfg, repoPath, commitHash)
+		if err != nil {
+			return fmt.Errorf("post-upload verification failed for %s: %w", location, err)
 		}
+		if !report.OK() {
+			return fmt.Errorf("post-upload verification found %d problem(s) in %s: %s", len(report.Problems()), location, strings.Join(report.Problems(), "; "))
+		}
+		log.Printf("Backup: Verified %d file(s) in %s", report.FilesChecked, location)
 	}
-	s3Path := fmt.Sprintf("s3://%s/%s", cfg.Bucket, s3Key)
-	log.Printf("Backup: Target S3 Key: %s\n", s3Path)
+	return nil // Success
+}
+
+// uploadArchive streams `git archive` of the commit through gzip to the
+// backend and returns the location it was stored at.
+func uploadArchive(backend Backend, cfg *config.BackupConfig, repoPath, commitHash string) (string, error) {
+	// --- Construct Object Key ---
+	backupFilename := archiveName(commitHash)
+	objectKey := ObjectKey(cfg, backupFilename)
+	location := backend.Location(objectKey)
+	log.Printf("Backup: Target location: %s\n", location)
 
 	// --- Create Archive Stream ---
 	log.Println("Backup: Creating git archive stream...")
 	cmdArchive := exec.Command("git", "-C", repoPath, "archive", "--format=tar", commitHash)
 	stdoutPipe, err := cmdArchive.StdoutPipe() // Get pipe BEFORE starting command
 	if err != nil {
-		return fmt.Errorf("failed to get stdout pipe for git archive: %w", err)
+		return "", fmt.Errorf("failed to get stdout pipe for git archive: %w", err)
 	}
 	var stderr strings.Builder
 	cmdArchive.Stderr = &stderr
@@ -139,7 +139,7 @@ func RunBackup(repoPath, commitHash string, cfg *config.BackupConfig) error {
 	if err := cmdArchive.Start(); err != nil {
 		// Ensure stdoutPipe is closed if command start fails? Or let caller handle?
 		// Closing stdoutPipe isn't directly possible, maybe kill process?
-		return fmt.Errorf("failed to start git archive: %w", err)
+		return "", fmt.Errorf("failed to start git archive: %w", err)
 	}
 
 	// --- Setup Gzip Pipe ---
@@ -147,44 +147,56 @@ func RunBackup(repoPath, commitHash string, cfg *config.BackupConfig) error {
 	if err != nil {
 		_ = cmdArchive.Process.Kill() // Attempt to clean up started process
 		_ = cmdArchive.Wait()         // Wait to release resources
-		return fmt.Errorf("failed to setup gzip pipe: %w", err)
+		return "", fmt.Errorf("failed to setup gzip pipe: %w", err)
 	}
 	// Defer Close on the reader end of the gzip pipe (*io.PipeReader).
-	// This is crucial. When the S3 upload finishes (or errors), this Close()
+	// This is crucial. When the upload finishes (or errors), this Close()
 	// will signal the goroutine inside GzipPipe (via io.Copy returning ErrClosedPipe)
 	// allowing it to clean up.
 	defer gzipReader.Close()
 
-	// --- Upload to S3 ---
-	log.Println("Backup: Starting S3 upload...")
-	_, uploadErr := s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(cfg.Bucket),
-		Key:    aws.String(s3Key),
-		Body:   gzipReader, // Read directly from the gzip reader pipe
-	})
+	// --- Upload ---
+	log.Println("Backup: Starting upload...")
+	uploadErr := backend.Put(context.TODO(), objectKey, gzipReader) // Read directly from the gzip reader pipe
+	if uploadErr != nil {
+		// Nothing reads the rest of the stream now; stop git archive rather
+		// than leave it blocked on a full pipe forever
+		gzipReader.Close()
+		_ = cmdArchive.Process.Kill()
+	}
 
 	// Wait for the 'git archive' command to finish *after* upload attempt
-	// Reading from gzipReader inside PutObject drives the flow. The command
+	// Reading from gzipReader inside Put drives the flow. The command
 	// will complete once its stdout is fully consumed or the pipe breaks.
 	archiveErr := cmdArchive.Wait()
 
-	// Check for errors, prioritizing S3 upload error
+	// Check for errors, prioritizing upload error
 	if uploadErr != nil {
-		// S3 upload failed
+		// Upload failed
 		if archiveErr != nil {
 			log.Printf("Backup: git archive command also failed (stderr: %s): %v", stderr.String(), archiveErr)
 		}
 		// The error might be context canceled if the pipe closed due to archiveErr, or the S3 error itself
-		return fmt.Errorf("failed to upload to S3 (%s): %w", s3Path, uploadErr)
+		return "", fmt.Errorf("failed to upload to %s: %w", location, uploadErr)
 	}
 
-	// Check git archive error if S3 upload seemed okay
+	// Check git archive error if upload seemed okay
 	if archiveErr != nil {
-		// This means S3 upload finished, but the source command reported an error.
-		// This could indicate incomplete data, though unlikely if S3 returned success.
-		return fmt.Errorf("git archive command failed after upload (stderr: %s): %w", stderr.String(), archiveErr)
+		// This means the upload finished, but the source command reported an error.
+		// This could indicate incomplete data, though unlikely if the upload succeeded.
+		return "", fmt.Errorf("git archive command failed after upload (stderr: %s): %w", stderr.String(), archiveErr)
 	}
+	return location, nil
+}
 
-	log.Printf("Backup: Upload SUCCEEDED for %s", s3Path)
-	return nil // Success
+// PutReport stores a validation report for a commit next to its backup, under
+// "reports/" so it is never mistaken for a commit archive. ext includes the
+// leading dot, e.g. ".jsonl" or ".sarif.json".
+func PutReport(backend Backend, cfg *config.BackupConfig, commitHash, ext string, content []byte) error {
+	objectKey := ObjectKey(cfg, "reports/commit-"+commitHash+ext)
+	if err := backend.Put(context.TODO(), objectKey, bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("failed to upload report to %s: %w", backend.Location(objectKey), err)
+	}
+	log.Printf("Backup: Uploaded report %s", backend.Location(objectKey))
+	return nil
 }
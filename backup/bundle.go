@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"git-monitor-app/config"  // Use correct module path
+	"git-monitor-app/gitutil" // Use correct module path
+)
+
+// defaultBundleFullEvery is how many incremental bundles follow a full one
+// before the next full bundle starts a new chain.
+const defaultBundleFullEvery = 30
+
+// bundleManifestName is the object that records every uploaded bundle.
+const bundleManifestName = "bundles/manifest.json"
+
+// BundleManifest tracks the bundles uploaded in bundle mode. They form
+// chains: a full bundle, then incremental bundles that each need every
+// bundle before them in the chain. A full bundle also carries the tips of
+// the previous chain, so the newest chain alone restores all history that
+// still exists in the repository.
+type BundleManifest struct {
+	Repo    string        `json:"repo"`
+	Bundles []BundleEntry `json:"bundles"` // Oldest first
+}
+
+// BundleEntry is one uploaded bundle.
+type BundleEntry struct {
+	Name      string    `json:"name"`               // Object name under the prefix
+	Commit    string    `json:"commit"`             // The commit that was backed up
+	Full      bool      `json:"full"`               // Starts a new chain
+	Tips      []string  `json:"tips"`               // Commits the bundle has refs for: Commit and the previous chain's heads, less any reachable from another
+	Excludes  []string  `json:"excludes,omitempty"` // Earlier tips whose history the bundle leaves out
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Chains splits the bundles into chains, oldest first.
+func (m *BundleManifest) Chains() [][]BundleEntry {
+	var chains [][]BundleEntry
+	for _, b := range m.Bundles {
+		if b.Full || len(chains) == 0 {
+			chains = append(chains, nil)
+		}
+		chains[len(chains)-1] = append(chains[len(chains)-1], b)
+	}
+	return chains
+}
+
+// lastChain returns the chain new incremental bundles extend.
+func (m *BundleManifest) lastChain() []BundleEntry {
+	chains := m.Chains()
+	if len(chains) == 0 {
+		return nil
+	}
+	return chains[len(chains)-1]
+}
+
+// bundleName returns the object name used for a commit's bundle.
+func bundleName(commitHash string, full bool) string {
+	if full {
+		return fmt.Sprintf("bundles/commit-%s-full.bundle", commitHash)
+	}
+	return fmt.Sprintf("bundles/commit-%s.bundle", commitHash)
+}
+
+// GetBundleManifest fetches the bundle manifest. A destination without one
+// yet gets an empty manifest.
+func GetBundleManifest(ctx context.Context, backend Backend, cfg *config.BackupConfig) (*BundleManifest, error) {
+	body, err := backend.Get(ctx, ObjectKey(cfg, bundleManifestName))
+	if errors.Is(err, ErrNotFound) {
+		return &BundleManifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download bundle manifest: %w", err)
+	}
+	defer body.Close()
+	var manifest BundleManifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// putBundleManifest stores the manifest. It's written after the bundle it
+// describes, so a failed upload never leaves it pointing at a missing bundle.
+func putBundleManifest(ctx context.Context, backend Backend, cfg *config.BackupConfig, manifest *BundleManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+	objectKey := ObjectKey(cfg, bundleManifestName)
+	if err := backend.Put(ctx, objectKey, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to upload bundle manifest to %s: %w", backend.Location(objectKey), err)
+	}
+	return nil
+}
+
+// uploadBundle backs up a commit's history as a bundle: incremental on top of
+// the current chain when possible, full when the chain is due to be renewed
+// or can no longer be extended. It returns nil if the commit was already in
+// the chain.
+func uploadBundle(ctx context.Context, backend Backend, cfg *config.BackupConfig, repoPath, commitHash string) (*BundleEntry, error) {
+	manifest, err := GetBundleManifest(ctx, backend, cfg)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Repo = repoPath
+	chain := manifest.lastChain()
+
+	// Tips of the current chain, all of which must still exist here for an
+	// incremental bundle to build on them
+	var tips []string
+	extendable := len(chain) > 0
+	for _, b := range chain {
+		for _, tip := range b.Tips {
+			if tip == commitHash {
+				log.Printf("Backup: Commit %s is already in bundle %s", commitHash, b.Name)
+				return nil, nil
+			}
+			if gitutil.CommitExists(repoPath, tip) {
+				tips = append(tips, tip)
+			} else {
+				extendable = false // History was rewritten or pruned
+			}
+		}
+	}
+	fullEvery := cfg.BundleFullEvery
+	if fullEvery <= 0 {
+		fullEvery = defaultBundleFullEvery
+	}
+	full := !extendable || len(chain) > fullEvery
+
+	// Only the heads matter: anything reachable from another tip is already
+	// carried over (or excluded) with it
+	entry := BundleEntry{Commit: commitHash, Full: full}
+	if full {
+		entry.Tips, err = gitutil.IndependentCommits(repoPath, append([]string{commitHash}, tips...)) // Carry the old chain's history over
+	} else {
+		entry.Tips = []string{commitHash}
+		entry.Excludes, err = gitutil.IndependentCommits(repoPath, tips)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry.Name = bundleName(commitHash, full)
+
+	tmp, err := os.CreateTemp("", "git-monitor-*.bundle")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary bundle file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	kind := "incremental"
+	if full {
+		kind = "full"
+	}
+	log.Printf("Backup: Creating %s bundle for commit %s...", kind, commitHash)
+	err = gitutil.CreateBundle(repoPath, tmp.Name(), entry.Tips, entry.Excludes)
+	if errors.Is(err, gitutil.ErrEmptyBundle) {
+		// An ancestor of something already bundled; nothing new to store
+		log.Printf("Backup: Commit %s is already contained in the current bundle chain", commitHash)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if entry.Size, err = io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	objectKey := ObjectKey(cfg, entry.Name)
+	log.Printf("Backup: Uploading %d-byte bundle to %s", entry.Size, backend.Location(objectKey))
+	if err := backend.Put(ctx, objectKey, f); err != nil {
+		return nil, fmt.Errorf("failed to upload to %s: %w", backend.Location(objectKey), err)
+	}
+
+	entry.CreatedAt = time.Now().UTC()
+	manifest.Bundles = append(manifest.Bundles, entry)
+	if err := putBundleManifest(ctx, backend, cfg, manifest); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// downloadBundle fetches a bundle into a temporary file and checks it
+// against the manifest's checksum. The caller removes the file.
+func downloadBundle(ctx context.Context, backend Backend, cfg *config.BackupConfig, entry BundleEntry) (string, error) {
+	objectKey := ObjectKey(cfg, entry.Name)
+	body, err := backend.Get(ctx, objectKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", backend.Location(objectKey), err)
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "git-monitor-*.bundle")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary bundle file: %w", err)
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && hex.EncodeToString(h.Sum(nil)) != entry.SHA256 {
+		err = fmt.Errorf("SHA-256 of %s doesn't match the manifest", backend.Location(objectKey))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// VerifyBundle re-downloads one bundle, checks it against the manifest's
+// checksum and has git check it against the repository.
+func VerifyBundle(ctx context.Context, backend Backend, cfg *config.BackupConfig, repoPath string, entry BundleEntry) error {
+	file, err := downloadBundle(ctx, backend, cfg, entry)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	return gitutil.VerifyBundle(repoPath, file)
+}
+
+// RestoreBundle rebuilds a repository in targetDir from the bundle chains and
+// checks out commit (a full hash or unique prefix) on a "restored" branch.
+// Chains are fetched newest first, stopping as soon as the commit turns up.
+func RestoreBundle(ctx context.Context, backend Backend, cfg *config.BackupConfig, commit, targetDir string, force bool) error {
+	manifest, err := GetBundleManifest(ctx, backend, cfg)
+	if err != nil {
+		return err
+	}
+	if len(manifest.Bundles) == 0 {
+		return fmt.Errorf("no backup found for commit %s: %w", commit, ErrNotFound)
+	}
+
+	if err := prepareTarget(targetDir, force); err != nil {
+		return err
+	}
+	if err := gitutil.InitRepo(targetDir); err != nil {
+		return err
+	}
+
+	chains := manifest.Chains()
+	for i := len(chains) - 1; i >= 0; i-- {
+		for _, b := range chains[i] {
+			log.Printf("Restore: Fetching %s", backend.Location(ObjectKey(cfg, b.Name)))
+			file, err := downloadBundle(ctx, backend, cfg, b)
+			if err != nil {
+				return err
+			}
+			err = gitutil.FetchBundle(targetDir, file)
+			os.Remove(file)
+			if err != nil {
+				return fmt.Errorf("failed to restore %s: %w", b.Name, err)
+			}
+			if gitutil.CommitExists(targetDir, commit) {
+				if err := gitutil.CheckoutBranch(targetDir, "restored", commit); err != nil {
+					return err
+				}
+				log.Printf("Restore: Checked out commit %s with its history into %s", commit, targetDir)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no bundle contains commit %s: %w", commit, ErrNotFound)
+}
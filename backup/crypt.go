@@ -0,0 +1,427 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+// Encrypted objects start with encMagic and a random salt, followed by the
+// content as a sequence of AES-256-GCM sealed chunks. Each chunk's nonce is
+// its index plus a flag marking the final chunk, so chunks can't be
+// reordered, dropped or truncated without decryption failing.
+const (
+	encMagic     = "GMENC\x00\x01\n"
+	encSaltSize  = 16
+	encChunkSize = 64 * 1024
+	encTagSize   = 16
+)
+
+// Passphrases are stretched with PBKDF2-HMAC-SHA256 under a random salt
+// stored unencrypted in encryptionInfoName at the root of each destination,
+// so the same passphrase gives a different key everywhere. Every object still
+// gets its own key from its own random salt.
+const (
+	passphraseIterations = 600000
+	encryptionInfoName   = "encryption.json"
+	keyCheckMessage      = "git-monitor-app key check"
+)
+
+// ErrDecrypt is returned when an encrypted object can't be authenticated:
+// the key is wrong, or the object was corrupted or truncated.
+var ErrDecrypt = errors.New("decryption failed (wrong key, or corrupt or truncated object)")
+
+// encryptionInfo records how a destination's key is derived from the passphrase.
+type encryptionInfo struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Check      string `json:"check"` // HMAC of keyCheckMessage under the key, to catch a wrong passphrase early
+}
+
+// passphraseKeys caches derived keys for the life of the process: the KDF is
+// deliberately slow and a backend is created for every backup.
+var passphraseKeys = struct {
+	sync.Mutex
+	infos map[string]*encryptionInfo // Destination location -> how its key is derived
+	keys  map[[32]byte][]byte        // Hash of KDF inputs -> derived key
+}{infos: map[string]*encryptionInfo{}, keys: map[[32]byte][]byte{}}
+
+// loadEncryptionKey returns the master key configured for cfg, or nil when
+// encryption is off. backend is the unencrypted destination.
+func loadEncryptionKey(ctx context.Context, backend Backend, cfg *config.BackupConfig) ([]byte, error) {
+	switch {
+	case cfg.EncryptionKeyFile != "":
+		data, err := os.ReadFile(cfg.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key: %w", err)
+		}
+		text := strings.TrimSpace(string(data))
+		if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+			return key, nil
+		}
+		if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+			return key, nil
+		}
+		if len(data) == 32 {
+			return data, nil // Raw key bytes
+		}
+		return nil, fmt.Errorf("encryption key file %s must hold 32 bytes, hex or base64 encoded", cfg.EncryptionKeyFile)
+	case cfg.EncryptionPassphraseEnv != "":
+		passphrase := os.Getenv(cfg.EncryptionPassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("encryption passphrase variable %s is not set", cfg.EncryptionPassphraseEnv)
+		}
+		return passphraseKey(ctx, backend, cfg, passphrase)
+	}
+	return nil, nil
+}
+
+// passphraseKey derives the destination's key from passphrase, recording a
+// new random salt the first time the destination is used.
+func passphraseKey(ctx context.Context, backend Backend, cfg *config.BackupConfig, passphrase string) ([]byte, error) {
+	passphraseKeys.Lock()
+	defer passphraseKeys.Unlock()
+
+	infoKey := ObjectKey(cfg, encryptionInfoName)
+	location := backend.Location(infoKey)
+	info, created := passphraseKeys.infos[location], false
+	if info == nil {
+		var err error
+		if info, created, err = getEncryptionInfo(ctx, backend, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	id := sha256.New()
+	fmt.Fprintf(id, "%d\x00%x\x00%s", info.Iterations, info.Salt, passphrase)
+	var cacheKey [32]byte
+	copy(cacheKey[:], id.Sum(nil))
+	key := passphraseKeys.keys[cacheKey]
+	if key == nil {
+		key = pbkdf2SHA256([]byte(passphrase), info.Salt, info.Iterations, 32)
+		passphraseKeys.keys[cacheKey] = key
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(keyCheckMessage))
+	check := hex.EncodeToString(mac.Sum(nil))
+	if created {
+		info.Check = check
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := backend.Put(ctx, infoKey, bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to store encryption salt at %s: %w", location, err)
+		}
+	} else if !hmac.Equal([]byte(check), []byte(info.Check)) {
+		return nil, fmt.Errorf("the passphrase in %s is not the one %s was encrypted with", cfg.EncryptionPassphraseEnv, backend.Location(ObjectKey(cfg, "")))
+	}
+	passphraseKeys.infos[location] = info
+	return key, nil
+}
+
+// getEncryptionInfo reads the destination's encryptionInfo, or starts a new
+// one (created is true) if it has none yet.
+func getEncryptionInfo(ctx context.Context, backend Backend, cfg *config.BackupConfig) (info *encryptionInfo, created bool, err error) {
+	infoKey := ObjectKey(cfg, encryptionInfoName)
+	body, err := backend.Get(ctx, infoKey)
+	if err == nil {
+		defer body.Close()
+		info = &encryptionInfo{}
+		if err := json.NewDecoder(body).Decode(info); err != nil {
+			return nil, false, fmt.Errorf("failed to read %s: %w", backend.Location(infoKey), err)
+		}
+		if info.KDF != "pbkdf2-sha256" || info.Iterations <= 0 || len(info.Salt) == 0 {
+			return nil, false, fmt.Errorf("%s: unsupported key derivation %q", backend.Location(infoKey), info.KDF)
+		}
+		return info, false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, false, fmt.Errorf("failed to read %s: %w", backend.Location(infoKey), err)
+	}
+
+	info = &encryptionInfo{KDF: "pbkdf2-sha256", Iterations: passphraseIterations, Salt: make([]byte, encSaltSize)}
+	if _, err := rand.Read(info.Salt); err != nil {
+		return nil, false, err
+	}
+	return info, true, nil
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// objectCipher derives the per-object key from the master key and salt.
+func objectCipher(masterKey, salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte("git-monitor-app object key"))
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce builds the nonce for chunk index i.
+func chunkNonce(i uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], i)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptWriter encrypts everything written to it onto w. Close must be
+// called to write the final chunk; it doesn't close w.
+type encryptWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	out   []byte
+	index uint64
+}
+
+// NewEncryptWriter starts an encrypted object on w under masterKey.
+func NewEncryptWriter(w io.Writer, masterKey []byte) (io.WriteCloser, error) {
+	salt, err := newObjectSalt()
+	if err != nil {
+		return nil, err
+	}
+	return newEncryptWriter(w, masterKey, salt)
+}
+
+func newObjectSalt() ([]byte, error) {
+	salt := make([]byte, encSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// newEncryptWriter starts an encrypted object under a given salt. The same
+// salt must never encrypt different content.
+func newEncryptWriter(w io.Writer, masterKey, salt []byte) (io.WriteCloser, error) {
+	aead, err := objectCipher(masterKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(encMagic), salt...)); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+	// Hold back a full chunk until we know whether it's the last one
+	for len(e.buf) > encChunkSize {
+		if err := e.seal(e.buf[:encChunkSize], false); err != nil {
+			return 0, err
+		}
+		e.buf = append(e.buf[:0], e.buf[encChunkSize:]...)
+	}
+	return len(p), nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(e.buf, true)
+}
+
+func (e *encryptWriter) seal(chunk []byte, last bool) error {
+	e.out = e.aead.Seal(e.out[:0], chunkNonce(e.index, last), chunk, nil)
+	e.index++
+	_, err := e.w.Write(e.out)
+	return err
+}
+
+// decryptReader reads the plaintext of an encrypted object.
+type decryptReader struct {
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	in    []byte
+	plain []byte
+	index uint64
+	done  bool
+}
+
+// NewDecryptReader reads an encrypted object from r under masterKey.
+func NewDecryptReader(r io.Reader, masterKey []byte) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, encChunkSize+encTagSize+1)
+	header := make([]byte, len(encMagic)+encSaltSize)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(encMagic)]) != encMagic {
+		return nil, errors.New("not an encrypted backup object")
+	}
+	aead, err := objectCipher(masterKey, header[len(encMagic):])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: br, aead: aead, in: make([]byte, encChunkSize+encTagSize)}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next opens the following chunk. A short chunk, or a full one at the end of
+// the stream, must be the last.
+func (d *decryptReader) next() error {
+	n, err := io.ReadFull(d.r, d.in)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, err := d.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if n < encTagSize {
+		return ErrDecrypt
+	}
+	plain, err := d.aead.Open(d.in[:0], chunkNonce(d.index, last), d.in[:n], nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+	d.index++
+	d.plain = plain
+	d.done = last
+	return nil
+}
+
+// encryptedBackend encrypts objects on the way into another backend and
+// decrypts them on the way out. Keys, listings and sizes are those of the
+// underlying backend, so object names (commit and blob hashes) stay visible.
+type encryptedBackend struct {
+	Backend
+	key        []byte
+	allowPlain bool // Pass through objects without the encryption header
+}
+
+// saltedBackend is implemented by backends that resume interrupted uploads
+// (S3). An object sent again must be encrypted under the salt it was first
+// encrypted under, or none of the parts already sent would match.
+type saltedBackend interface {
+	UploadSalt(key string) []byte
+	PutSalted(ctx context.Context, key string, salt []byte, body io.Reader) error
+}
+
+func (b *encryptedBackend) Put(ctx context.Context, key string, body io.Reader) error {
+	resumable, _ := b.Backend.(saltedBackend)
+	var salt []byte
+	if resumable != nil {
+		salt = resumable.UploadSalt(key)
+	}
+	if salt == nil {
+		var err error
+		if salt, err = newObjectSalt(); err != nil {
+			return err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ew, err := newEncryptWriter(pw, b.key, salt)
+		if err == nil {
+			if _, err = io.Copy(ew, body); err == nil {
+				err = ew.Close()
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	var err error
+	if resumable != nil {
+		err = resumable.PutSalted(ctx, key, salt, pr)
+	} else {
+		err = b.Backend.Put(ctx, key, pr)
+	}
+	pr.Close()
+	<-done // Don't return while body is still being read
+	return err
+}
+
+func (b *encryptedBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, err := b.Backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(body)
+	if head, _ := br.Peek(len(encMagic)); !bytes.Equal(head, []byte(encMagic)) {
+		// Nothing authenticates a plaintext object: anyone with write access
+		// to the destination could have put it there
+		if !b.allowPlain {
+			body.Close()
+			return nil, fmt.Errorf("%s is not encrypted (set allow_unencrypted for backups made before encryption was turned on): %w", b.Location(key), ErrDecrypt)
+		}
+		log.Printf("Backup Warning: %s is not encrypted", b.Location(key))
+		return readCloser{br, body}, nil
+	}
+	plain, err := NewDecryptReader(br, b.key)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("%s: %w", b.Location(key), err)
+	}
+	return readCloser{plain, body}, nil
+}
+
+// readCloser pairs a reader with the Close of the stream beneath it.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
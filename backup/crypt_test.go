@@ -0,0 +1,248 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 32)
+
+// encrypt returns plain encrypted under key.
+func encrypt(t *testing.T, key, plain []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewEncryptWriter(&out, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// decrypt returns what decrypting sealed under key gives, or the error.
+func decrypt(sealed, key []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// testContent returns n bytes that differ from chunk to chunk.
+func testContent(n int) []byte {
+	content := make([]byte, n)
+	for i := range content {
+		content[i] = byte(i * 7 / 13)
+	}
+	return content
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, encChunkSize - 1, encChunkSize, encChunkSize + 1, 3*encChunkSize + 5} {
+		plain := testContent(size)
+		sealed := encrypt(t, testKey, plain)
+		got, err := decrypt(sealed, testKey)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: decrypted content differs", size)
+		}
+	}
+}
+
+func TestEncryptUsesNewSaltEachTime(t *testing.T) {
+	plain := []byte("same content")
+	if bytes.Equal(encrypt(t, testKey, plain), encrypt(t, testKey, plain)) {
+		t.Fatal("encrypting the same content twice gave the same object")
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	plain := testContent(3*encChunkSize + 5)
+	sealed := encrypt(t, testKey, plain)
+	header := len(encMagic) + encSaltSize
+	chunk := encChunkSize + encTagSize
+
+	swapped := append([]byte(nil), sealed...)
+	copy(swapped[header:], sealed[header+chunk:header+2*chunk])
+	copy(swapped[header+chunk:], sealed[header:header+chunk])
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[header+chunk+10] ^= 1
+
+	wrongKey := bytes.Repeat([]byte{0x24}, 32)
+
+	cases := []struct {
+		name   string
+		sealed []byte
+		key    []byte
+	}{
+		{"truncated at a chunk boundary", sealed[:header+2*chunk], testKey},
+		{"truncated mid-chunk", sealed[:header+chunk+100], testKey},
+		{"truncated to the header", sealed[:header], testKey},
+		{"chunks reordered", swapped, testKey},
+		{"bit flipped", flipped, testKey},
+		{"wrong key", sealed, wrongKey},
+	}
+	for _, c := range cases {
+		if _, err := decrypt(c.sealed, c.key); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: got %v, want ErrDecrypt", c.name, err)
+		}
+	}
+}
+
+func TestEncryptedBackendRejectsPlaintext(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Put(ctx, "plain", strings.NewReader("not encrypted")); err != nil {
+		t.Fatal(err)
+	}
+
+	strict := &encryptedBackend{Backend: local, key: testKey}
+	if err := strict.Put(ctx, "sealed", strings.NewReader("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, strict, "sealed"); got != "secret" {
+		t.Fatalf("got %q back, want %q", got, "secret")
+	}
+	if raw := readObject(t, local, "sealed"); strings.Contains(raw, "secret") {
+		t.Fatal("object was stored in plaintext")
+	}
+	if _, err := strict.Get(ctx, "plain"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("got %v for a plaintext object, want ErrDecrypt", err)
+	}
+
+	lenient := &encryptedBackend{Backend: local, key: testKey, allowPlain: true}
+	if got := readObject(t, lenient, "plain"); got != "not encrypted" {
+		t.Fatalf("got %q with allow_unencrypted, want the plaintext", got)
+	}
+}
+
+// readObject returns the whole of an object.
+func readObject(t *testing.T, backend Backend, key string) string {
+	t.Helper()
+	body, err := backend.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestLoadEncryptionKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string][]byte{
+		"hex":    []byte(hex.EncodeToString(testKey) + "\n"),
+		"base64": []byte(base64.StdEncoding.EncodeToString(testKey)),
+		"raw":    testKey,
+	}
+	for name, content := range cases {
+		cfg := &config.BackupConfig{EncryptionKeyFile: filepath.Join(dir, name)}
+		if err := os.WriteFile(cfg.EncryptionKeyFile, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		key, err := loadEncryptionKey(context.Background(), nil, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(key, testKey) {
+			t.Fatalf("%s: got key %x", name, key)
+		}
+	}
+
+	cfg := &config.BackupConfig{EncryptionKeyFile: filepath.Join(dir, "short")}
+	if err := os.WriteFile(cfg.EncryptionKeyFile, []byte("abcd"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadEncryptionKey(context.Background(), nil, cfg); err == nil {
+		t.Fatal("accepted a key that is too short")
+	}
+}
+
+func TestLoadEncryptionKeyPassphrase(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.BackupConfig{EncryptionPassphraseEnv: "GM_TEST_PASSPHRASE"}
+	t.Setenv(cfg.EncryptionPassphraseEnv, "correct horse")
+
+	// Two new destinations get their own salts, and so different keys
+	first, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := loadEncryptionKey(ctx, first, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := loadEncryptionKey(ctx, second, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(key, other) {
+		t.Fatal("two destinations got the same key from one passphrase")
+	}
+	// The salt is stored, so the key comes out the same again
+	again, err := loadEncryptionKey(ctx, first, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Fatal("loading the key again gave a different key")
+	}
+
+	t.Setenv(cfg.EncryptionPassphraseEnv, "wrong horse")
+	if _, err := loadEncryptionKey(ctx, first, cfg); err == nil {
+		t.Fatal("accepted the wrong passphrase")
+	}
+
+	// Plaintext backups made before encryption was turned on don't stop a
+	// destination getting its own salt
+	upgraded, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := upgraded.Put(ctx, "commit-old.tar.gz", strings.NewReader("x")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(cfg.EncryptionPassphraseEnv, "correct horse")
+	upgradedKey, err := loadEncryptionKey(ctx, upgraded, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(upgradedKey, key) {
+		t.Fatal("a destination with existing objects got the same key as another")
+	}
+	info, created, err := getEncryptionInfo(ctx, upgraded, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || len(info.Salt) != encSaltSize {
+		t.Fatalf("got salt %x (new: %v), want a stored random salt", info.Salt, created)
+	}
+}
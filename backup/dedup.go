@@ -0,0 +1,439 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git-monitor-app/config"  // Use correct module path
+	"git-monitor-app/gitutil" // Use correct module path
+)
+
+// dedupIndexMaxAge is how long the cached index of remote blobs is trusted
+// before it's rebuilt from a listing, in case blobs were removed remotely.
+const dedupIndexMaxAge = 7 * 24 * time.Hour
+
+// maxSymlinkTarget caps how much of a symlink blob we'll read when restoring.
+const maxSymlinkTarget = 4096
+
+// TreeManifest lists the files of a commit in dedup mode. The content of
+// each file is stored once, under blobs/<git blob hash>, and shared by every
+// commit that contains it. It is stored as trees/commit-<hash>.json.
+type TreeManifest struct {
+	Repo      string     `json:"repo"`
+	Commit    string     `json:"commit"`
+	CreatedAt time.Time  `json:"created_at"`
+	Files     []TreeFile `json:"files"`
+}
+
+// TreeFile is one file of a TreeManifest.
+type TreeFile struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"` // Git file mode: "100644", "100755" or "120000" (symlink)
+	Blob string `json:"blob"` // Git blob hash, which is also the object name under blobs/
+}
+
+// treeName returns the object name used for a commit's tree manifest.
+func treeName(commitHash string) string {
+	return fmt.Sprintf("trees/commit-%s.json", commitHash)
+}
+
+// blobName returns the object name used for a blob's content.
+func blobName(blobHash string) string {
+	return "blobs/" + blobHash
+}
+
+// uploadTree backs up a commit in dedup mode: blobs the destination doesn't
+// have yet are uploaded, then the commit's tree manifest. It returns the
+// location of the manifest.
+func uploadTree(ctx context.Context, backend Backend, cfg *config.BackupConfig, repoPath, commitHash string) (string, error) {
+	entries, err := gitutil.ListTree(repoPath, commitHash)
+	if err != nil {
+		return "", err
+	}
+	manifest := TreeManifest{Repo: repoPath, Commit: commitHash, Files: []TreeFile{}}
+	var blobs []string
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e.Type != "blob" {
+			continue // Submodules aren't stored, same as in archives
+		}
+		manifest.Files = append(manifest.Files, TreeFile{Path: e.Path, Mode: e.Mode, Blob: e.Hash})
+		if !seen[e.Hash] {
+			seen[e.Hash] = true
+			blobs = append(blobs, e.Hash)
+		}
+	}
+
+	index, err := openDedupIndex(ctx, backend, cfg)
+	if err != nil {
+		return "", err
+	}
+	defer index.Close()
+	var missing []string
+	for _, hash := range blobs {
+		if !index.Has(hash) {
+			missing = append(missing, hash)
+		}
+	}
+	log.Printf("Backup: Commit %s has %d unique file(s), %d not yet stored", commitHash, len(blobs), len(missing))
+
+	// Blobs first, so a manifest never points at content that isn't there
+	var uploaded int64
+	err = gitutil.ReadBlobs(repoPath, missing, func(hash string, size int64, content io.Reader) error {
+		objectKey := ObjectKey(cfg, blobName(hash))
+		if err := backend.Put(ctx, objectKey, content); err != nil {
+			return fmt.Errorf("failed to upload to %s: %w", backend.Location(objectKey), err)
+		}
+		uploaded += size
+		return index.Add(hash)
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(missing) > 0 {
+		log.Printf("Backup: Uploaded %d blob(s), %d bytes", len(missing), uploaded)
+	}
+
+	manifest.CreatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode tree manifest: %w", err)
+	}
+	objectKey := ObjectKey(cfg, treeName(commitHash))
+	if err := backend.Put(ctx, objectKey, bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("failed to upload tree manifest to %s: %w", backend.Location(objectKey), err)
+	}
+
+	// A prune that listed the manifests before this one was stored may be
+	// deleting, or have deleted, blobs the index said were there; once it's
+	// done, put back any of ours it took
+	if len(missing) < len(blobs) {
+		waited, err := waitForPrune(ctx, backend, cfg)
+		if err != nil {
+			return "", err
+		}
+		pruned, err := lastPruned(ctx, backend, cfg)
+		if err != nil {
+			return "", err
+		}
+		if waited || !pruned.Equal(index.pruned) {
+			if err := reuploadPrunedBlobs(ctx, backend, cfg, repoPath, blobs); err != nil {
+				return "", err
+			}
+		}
+	}
+	return backend.Location(objectKey), nil
+}
+
+// reuploadPrunedBlobs uploads again whichever of blobs the destination no
+// longer has.
+func reuploadPrunedBlobs(ctx context.Context, backend Backend, cfg *config.BackupConfig, repoPath string, blobs []string) error {
+	prefix := ObjectKey(cfg, "blobs/")
+	objects, err := backend.List(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list stored blobs: %w", err)
+	}
+	stored := map[string]bool{}
+	for _, obj := range objects {
+		stored[strings.TrimPrefix(obj.Key, prefix)] = true
+	}
+	var gone []string
+	for _, hash := range blobs {
+		if !stored[hash] {
+			gone = append(gone, hash)
+		}
+	}
+	if len(gone) == 0 {
+		return nil
+	}
+	log.Printf("Backup Warning: %d blob(s) were pruned while this backup was running; uploading them again", len(gone))
+	return gitutil.ReadBlobs(repoPath, gone, func(hash string, size int64, content io.Reader) error {
+		objectKey := ObjectKey(cfg, blobName(hash))
+		if err := backend.Put(ctx, objectKey, content); err != nil {
+			return fmt.Errorf("failed to upload to %s: %w", backend.Location(objectKey), err)
+		}
+		return nil
+	})
+}
+
+// dedupIndex is the local cache of blob hashes known to be stored remotely,
+// one per line, so a backup doesn't have to list or probe the destination for
+// every blob. New uploads are appended as they finish.
+type dedupIndex struct {
+	known  map[string]bool
+	built  time.Time // From the "# built" first line; zero for an index without one
+	pruned time.Time // When the destination's blobs were last pruned, as of opening the index
+	file   *os.File
+}
+
+// openDedupIndex loads the cached index for the destination, rebuilding it
+// from a listing of blobs/ when there is none, it's too old, or blobs were
+// pruned since it was built.
+func openDedupIndex(ctx context.Context, backend Backend, cfg *config.BackupConfig) (*dedupIndex, error) {
+	prefix := ObjectKey(cfg, "blobs/")
+	if err := os.MkdirAll(cfg.DedupCacheDir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create dedup cache directory %s: %w", cfg.DedupCacheDir, err)
+	}
+	cachePath := dedupIndexPath(backend, cfg)
+
+	pruned, err := lastPruned(ctx, backend, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &dedupIndex{known: map[string]bool{}, pruned: pruned}
+	if _, err := os.Stat(cachePath); err == nil {
+		if err := index.load(cachePath); err != nil {
+			return nil, err
+		}
+	}
+	if time.Since(index.built) >= dedupIndexMaxAge || !index.built.After(pruned) {
+		log.Printf("Backup: Building blob index from %s", backend.Location(prefix))
+		index = &dedupIndex{known: map[string]bool{}, built: time.Now().UTC(), pruned: pruned}
+		objects, err := backend.List(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stored blobs: %w", err)
+		}
+		var lines strings.Builder
+		lines.WriteString("# built " + index.built.Format(time.RFC3339Nano) + "\n")
+		for _, obj := range objects {
+			hash := strings.TrimPrefix(obj.Key, prefix)
+			if strings.Contains(hash, "/") || strings.HasPrefix(hash, ".") {
+				continue // Not a blob (e.g. a local backend's in-progress upload)
+			}
+			index.known[hash] = true
+			lines.WriteString(hash + "\n")
+		}
+		if err := os.WriteFile(cachePath, []byte(lines.String()), 0640); err != nil {
+			return nil, fmt.Errorf("failed to write dedup index %s: %w", cachePath, err)
+		}
+	}
+
+	f, err := os.OpenFile(cachePath, os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dedup index %s: %w", cachePath, err)
+	}
+	index.file = f
+	return index, nil
+}
+
+// dedupIndexPath returns the cache file for the destination; there's one per
+// destination, named after where its blobs live.
+func dedupIndexPath(backend Backend, cfg *config.BackupConfig) string {
+	sum := sha256.Sum256([]byte(backend.Location(ObjectKey(cfg, "blobs/"))))
+	return filepath.Join(cfg.DedupCacheDir, hex.EncodeToString(sum[:8])+".idx")
+}
+
+// dropDedupIndex removes the cached index after blobs were deleted, so the
+// next backup rebuilds it instead of skipping blobs that are gone.
+func dropDedupIndex(backend Backend, cfg *config.BackupConfig) error {
+	if err := os.Remove(dedupIndexPath(backend, cfg)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove dedup index: %w", err)
+	}
+	return nil
+}
+
+func (x *dedupIndex) load(cachePath string) error {
+	f, err := os.Open(cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to read dedup index %s: %w", cachePath, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if built, ok := strings.CutPrefix(line, "# built "); ok {
+			x.built, _ = time.Parse(time.RFC3339Nano, built)
+		} else if line != "" {
+			x.known[line] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// Has reports whether the blob is already stored.
+func (x *dedupIndex) Has(hash string) bool {
+	return x.known[hash]
+}
+
+// Add records a blob that has just been uploaded.
+func (x *dedupIndex) Add(hash string) error {
+	x.known[hash] = true
+	if _, err := x.file.WriteString(hash + "\n"); err != nil {
+		return fmt.Errorf("failed to update dedup index: %w", err)
+	}
+	return nil
+}
+
+func (x *dedupIndex) Close() error {
+	return x.file.Close()
+}
+
+// ListTreeBackups lists the tree manifests stored under the configured
+// prefix, newest first.
+func ListTreeBackups(ctx context.Context, backend Backend, cfg *config.BackupConfig) ([]CommitBackup, error) {
+	prefix := ObjectKey(cfg, "trees/commit-")
+	objects, err := backend.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var backups []CommitBackup
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, prefix)
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		backups = append(backups, CommitBackup{
+			Commit:       strings.TrimSuffix(name, ".json"),
+			Key:          obj.Key,
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+		})
+	}
+	sortNewestFirst(backups)
+	return backups, nil
+}
+
+// GetTreeManifest resolves a full or abbreviated commit hash to its tree
+// manifest and downloads it.
+func GetTreeManifest(ctx context.Context, backend Backend, cfg *config.BackupConfig, commit string) (*TreeManifest, CommitBackup, error) {
+	if commit == "" {
+		return nil, CommitBackup{}, fmt.Errorf("a commit hash is required")
+	}
+	backups, err := ListTreeBackups(ctx, backend, cfg)
+	if err != nil {
+		return nil, CommitBackup{}, err
+	}
+	found, err := matchCommitBackup(backups, commit)
+	if err != nil {
+		return nil, CommitBackup{}, err
+	}
+	manifest, err := readTreeManifest(ctx, backend, found.Key)
+	return manifest, found, err
+}
+
+// readTreeManifest downloads and decodes the tree manifest stored under key.
+func readTreeManifest(ctx context.Context, backend Backend, key string) (*TreeManifest, error) {
+	body, err := backend.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download tree manifest: %w", err)
+	}
+	defer body.Close()
+	var manifest TreeManifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode tree manifest %s: %w", backend.Location(key), err)
+	}
+	return &manifest, nil
+}
+
+// treeChecksums downloads every blob a tree manifest refers to and returns
+// the SHA-256 of each file's content, keyed by path. Blobs shared by several
+// files are only downloaded once.
+func treeChecksums(ctx context.Context, backend Backend, cfg *config.BackupConfig, manifest *TreeManifest) (map[string]string, error) {
+	byBlob := map[string]string{}
+	sums := make(map[string]string, len(manifest.Files))
+	for _, f := range manifest.Files {
+		sum, ok := byBlob[f.Blob]
+		if !ok {
+			objectKey := ObjectKey(cfg, blobName(f.Blob))
+			body, err := backend.Get(ctx, objectKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to download %s (%s): %w", backend.Location(objectKey), f.Path, err)
+			}
+			h := sha256.New()
+			_, err = io.Copy(h, body)
+			body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", backend.Location(objectKey), err)
+			}
+			sum = hex.EncodeToString(h.Sum(nil))
+			byBlob[f.Blob] = sum
+		}
+		sums[f.Path] = sum
+	}
+	return sums, nil
+}
+
+// RestoreTree rebuilds a commit's files in targetDir from its tree manifest
+// and the shared blobs. A non-empty targetDir is refused unless force is set.
+func RestoreTree(ctx context.Context, backend Backend, cfg *config.BackupConfig, commit, targetDir string, force bool) error {
+	manifest, found, err := GetTreeManifest(ctx, backend, cfg, commit)
+	if err != nil {
+		return err
+	}
+	if err := prepareTarget(targetDir, force); err != nil {
+		return err
+	}
+	root, err := filepath.Abs(targetDir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Restore: Restoring %d file(s) listed in %s", len(manifest.Files), backend.Location(found.Key))
+	count := 0
+	for _, f := range manifest.Files {
+		if err := restoreTreeFile(ctx, backend, cfg, root, f, force); err != nil {
+			return err
+		}
+		count++
+	}
+	log.Printf("Restore: Restored %d file(s) from commit %s into %s", count, manifest.Commit, targetDir)
+	return nil
+}
+
+// restoreTreeFile writes one file of a tree manifest under root, with the
+// same path checks as archive extraction.
+func restoreTreeFile(ctx context.Context, backend Backend, cfg *config.BackupConfig, root string, f TreeFile, overwrite bool) error {
+	dest, err := safeJoin(root, f.Path)
+	if err != nil {
+		return err
+	}
+	if err := checkParentInside(root, dest); err != nil {
+		return err
+	}
+	objectKey := ObjectKey(cfg, blobName(f.Blob))
+	body, err := backend.Get(ctx, objectKey)
+	if err != nil {
+		return fmt.Errorf("failed to download %s (%s): %w", backend.Location(objectKey), f.Path, err)
+	}
+	defer body.Close()
+
+	switch f.Mode {
+	case "120000":
+		// A symlink's blob is its target
+		target, err := io.ReadAll(io.LimitReader(body, maxSymlinkTarget))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", backend.Location(objectKey), err)
+		}
+		linkTarget := string(target)
+		resolved := linkTarget
+		if !filepath.IsAbs(resolved) {
+			resolved = filepath.Join(filepath.Dir(dest), resolved)
+		}
+		if !isInside(root, filepath.Clean(resolved)) {
+			return fmt.Errorf("refusing symlink %s -> %s: points outside %s", f.Path, linkTarget, root)
+		}
+		if err := removeExisting(dest, overwrite); err != nil {
+			return err
+		}
+		if err := os.Symlink(linkTarget, dest); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", dest, err)
+		}
+		return nil
+	case "100755":
+		return writeFile(dest, body, 0755, overwrite)
+	default:
+		return writeFile(dest, body, 0644, overwrite)
+	}
+}
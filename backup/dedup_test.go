@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+// commitFiles commits files to a new or existing scratch repository and
+// returns the commit's hash.
+func commitFiles(t *testing.T, repo string, files map[string]string) string {
+	t.Helper()
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".git")); err != nil {
+		git("init", "-q")
+		git("config", "user.name", "Test")
+		git("config", "user.email", "test@example.com")
+		git("config", "commit.gpgsign", "false")
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "Update")
+	return git("rev-parse", "HEAD")
+}
+
+// pruneDuringPut runs a prune while a tree manifest is being stored, after
+// the prune has listed the manifests but before this one is in place.
+type pruneDuringPut struct {
+	Backend
+	t    *testing.T
+	cfg  *config.BackupConfig
+	plan []RetentionItem
+}
+
+func (b *pruneDuringPut) Put(ctx context.Context, key string, body io.Reader) error {
+	if strings.HasPrefix(key, "trees/") && b.plan != nil {
+		plan := b.plan
+		b.plan = nil
+		result, err := Prune(ctx, b.Backend, b.cfg, plan, false)
+		if err != nil {
+			b.t.Fatal(err)
+		}
+		if result.Blobs == 0 {
+			b.t.Fatal("the prune deleted no blobs, so there was no race")
+		}
+	}
+	return b.Backend.Put(ctx, key, body)
+}
+
+// A prune that finishes, lock and all, while a backup stores its manifest
+// must not leave the manifest pointing at blobs it deleted.
+func TestUploadTreeReuploadsBlobsPrunedMeanwhile(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	local, err := NewLocalBackend(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.BackupConfig{DedupCacheDir: t.TempDir()}
+	repo := t.TempDir()
+
+	first := commitFiles(t, repo, map[string]string{"a.txt": "shared", "b.txt": "only in the first"})
+	if _, err := uploadTree(ctx, local, cfg, repo, first); err != nil {
+		t.Fatal(err)
+	}
+	// Old enough for the prune to delete once nothing refers to them
+	old := time.Now().Add(-2 * blobGracePeriod)
+	blobs, err := local.List(ctx, ObjectKey(cfg, "blobs/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range blobs {
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(obj.Key)), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second commit reuses a.txt's blob, which the index says is stored
+	second := commitFiles(t, repo, map[string]string{"b.txt": "changed"})
+	racing := &pruneDuringPut{Backend: local, t: t, cfg: cfg, plan: []RetentionItem{
+		{Commit: first, Keys: []string{ObjectKey(cfg, treeName(first))}},
+	}}
+	if _, err := uploadTree(ctx, racing, cfg, repo, second); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := readTreeManifest(ctx, local, ObjectKey(cfg, treeName(second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range manifest.Files {
+		if _, err := local.Stat(ctx, ObjectKey(cfg, blobName(f.Blob))); err != nil {
+			t.Errorf("%s: blob %s is missing: %v", f.Path, f.Blob, err)
+		}
+	}
+}
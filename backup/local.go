@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalBackend stores backup objects as files under a root directory, e.g. a
+// NAS mount or an external drive.
+type LocalBackend struct {
+	root string
+}
+
+// NewLocalBackend creates a backend rooted at dir, creating it if needed.
+func NewLocalBackend(dir string) (*LocalBackend, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("backup config error: local_path is required for local backups")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve backup directory %s: %w", dir, err)
+	}
+	if err := os.MkdirAll(abs, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory %s: %w", abs, err)
+	}
+	return &LocalBackend{root: abs}, nil
+}
+
+// filePath maps a key to a path under the root, rejecting keys that escape it.
+func (b *LocalBackend) filePath(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != strings.TrimPrefix(key, "/") {
+		return "", fmt.Errorf("invalid backup key %q", key)
+	}
+	return filepath.Join(b.root, filepath.FromSlash(clean)), nil
+}
+
+// Put writes to a temporary file first so a failed backup never leaves a
+// truncated object behind under the final name.
+func (b *LocalBackend) Put(ctx context.Context, key string, body io.Reader) error {
+	target, err := b.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", target, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %w", target, err)
+	}
+	tmpName := tmp.Name()
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to close %s: %w", target, err)
+	}
+	if err := ctx.Err(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, target); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to move backup into place at %s: %w", target, err)
+	}
+	return nil
+}
+
+func (b *LocalBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	target, err := b.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(target)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", target, ErrNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", target, err)
+	}
+	return f, nil
+}
+
+func (b *LocalBackend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := filepath.WalkDir(b.root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil // Skip directories and in-flight uploads
+		}
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", b.root, err)
+	}
+	return objects, nil
+}
+
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	target, err := b.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); os.IsNotExist(err) {
+		return fmt.Errorf("%s: %w", target, ErrNotFound)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", target, err)
+	}
+	return nil
+}
+
+func (b *LocalBackend) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	target, err := b.filePath(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(target)
+	if os.IsNotExist(err) {
+		return ObjectInfo{}, fmt.Errorf("%s: %w", target, ErrNotFound)
+	} else if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to stat %s: %w", target, err)
+	}
+	return ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+func (b *LocalBackend) Location(key string) string {
+	if target, err := b.filePath(key); err == nil {
+		return target
+	}
+	return filepath.Join(b.root, key)
+}
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"git-monitor-app/config"    // Use correct module path
+	"git-monitor-app/gitutil"   // Use correct module path
+	"git-monitor-app/validator" // Use correct module path
+)
+
+// Metadata describes what a commit archive contains. It is stored next to
+// the archive as meta/commit-<hash>.json.
+type Metadata struct {
+	Repo      string              `json:"repo"`
+	Commit    string              `json:"commit"`
+	CreatedAt time.Time           `json:"created_at"`
+	Projects  []validator.Project `json:"projects"` // Every parseable project folder in the commit's tree
+}
+
+// metadataName returns the object name used for a commit's metadata.
+func metadataName(commitHash string) string {
+	return fmt.Sprintf("meta/commit-%s.json", commitHash)
+}
+
+// BuildMetadata collects the metadata for a commit from the repository.
+func BuildMetadata(repoPath, commitHash string) (*Metadata, error) {
+	entries, err := gitutil.ListTree(repoPath, commitHash)
+	if err != nil {
+		return nil, err
+	}
+	meta := &Metadata{Repo: repoPath, Commit: commitHash, CreatedAt: time.Now().UTC(), Projects: []validator.Project{}}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		folder := validator.ProjectFolder(e.Path)
+		if folder == "" || seen[folder] {
+			continue
+		}
+		seen[folder] = true
+		if project, err := validator.ParseProjectFolder(path.Base(folder)); err == nil {
+			meta.Projects = append(meta.Projects, project)
+		}
+	}
+	sort.Slice(meta.Projects, func(i, j int) bool {
+		return meta.Projects[i].Folder < meta.Projects[j].Folder
+	})
+	return meta, nil
+}
+
+// PutMetadata stores a commit's metadata in the backup destination.
+func PutMetadata(ctx context.Context, backend Backend, cfg *config.BackupConfig, meta *Metadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	objectKey := ObjectKey(cfg, metadataName(meta.Commit))
+	if err := backend.Put(ctx, objectKey, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to upload metadata to %s: %w", backend.Location(objectKey), err)
+	}
+	return nil
+}
+
+// GetMetadata fetches the metadata stored for a commit. Archives made before
+// metadata was written have none, which is reported as ErrNotFound.
+func GetMetadata(ctx context.Context, backend Backend, cfg *config.BackupConfig, commitHash string) (*Metadata, error) {
+	body, err := backend.Get(ctx, ObjectKey(cfg, metadataName(commitHash)))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var meta Metadata
+	if err := json.NewDecoder(body).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata for commit %s: %w", commitHash, err)
+	}
+	return &meta, nil
+}
@@ -0,0 +1,459 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	defaultPartSizeMB        = 16
+	defaultUploadConcurrency = 4
+	maxUploadParts           = 10000 // S3's limit per multipart upload
+)
+
+// Incomplete multipart uploads are aborted by CleanupUploads once they're
+// this old: tracked ones (ours, waiting to resume) after staleUploadAge since
+// their last part, untracked ones (from a lost state file or another machine)
+// after orphanUploadAge since they started.
+const (
+	staleUploadAge  = 7 * 24 * time.Hour
+	orphanUploadAge = 24 * time.Hour
+)
+
+// uploadState records an in-progress multipart upload, so that after an
+// interruption the parts already uploaded aren't sent again. It's saved
+// after every part and removed once the upload completes.
+type uploadState struct {
+	Bucket    string                 `json:"bucket"`
+	Key       string                 `json:"key"`
+	UploadID  string                 `json:"upload_id"`
+	PartSize  int64                  `json:"part_size"`
+	Salt      []byte                 `json:"salt,omitempty"` // Encrypted objects: the salt they're encrypted under, reused on resume
+	Parts     map[int32]uploadedPart `json:"parts"`
+	StartedAt time.Time              `json:"started_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// uploadedPart is recorded before a part is sent; ETag is set once S3 has it.
+type uploadedPart struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	ETag   string `json:"etag,omitempty"`
+}
+
+// statePath returns where the state of an upload to key is kept.
+func (b *S3Backend) statePath(key string) string {
+	sum := sha256.Sum256([]byte(b.bucket + "/" + key))
+	return filepath.Join(b.stateDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func (b *S3Backend) loadUploadState(path string) (*uploadState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload state %s: %w", path, err)
+	}
+	var state uploadState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Backup Warning: Ignoring unreadable upload state %s: %v", path, err)
+		return nil, nil
+	}
+	if state.Parts == nil {
+		state.Parts = map[int32]uploadedPart{}
+	}
+	return &state, nil
+}
+
+// saveUploadState writes the state through a temporary file, so a crash
+// never leaves it half-written.
+func (b *S3Backend) saveUploadState(state *uploadState) error {
+	if err := os.MkdirAll(b.stateDir, 0750); err != nil {
+		return fmt.Errorf("failed to create upload state directory %s: %w", b.stateDir, err)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := b.statePath(state.Key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return fmt.Errorf("failed to write upload state: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// putMultipart uploads first followed by the rest of body as a multipart
+// upload, resuming an earlier interrupted upload to the same key if there is
+// one. Parts are only skipped when their content hashes the same as the part
+// already uploaded, so a stream that differs from last time (e.g. a bundle
+// git packed differently) is uploaded again. The exception is an encrypted
+// stream (salt is set): sending different content under the same salt would
+// reuse its nonces, so the upload is dropped instead and starts over next time.
+func (b *S3Backend) putMultipart(parent context.Context, key string, salt, first []byte, body io.Reader) error {
+	state, err := b.startUpload(parent, key, salt)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	var mu sync.Mutex // Guards state, uploadErr and saving the state
+	var uploadErr error
+	fail := func(err error) {
+		mu.Lock()
+		if uploadErr == nil {
+			uploadErr = err
+		}
+		mu.Unlock()
+		cancel()
+	}
+
+	type job struct {
+		number int32
+		data   []byte
+		sum    string
+	}
+	jobs := make(chan job)
+	slots := make(chan struct{}, b.concurrency) // Bounds the parts held in memory
+	var wg sync.WaitGroup
+	for i := 0; i < b.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				out, err := b.client.UploadPart(ctx, &s3.UploadPartInput{
+					Bucket:        aws.String(b.bucket),
+					Key:           aws.String(key),
+					UploadId:      aws.String(state.UploadID),
+					PartNumber:    aws.Int32(j.number),
+					Body:          bytes.NewReader(j.data),
+					ContentLength: aws.Int64(int64(len(j.data))),
+				})
+				<-slots
+				if err != nil {
+					fail(fmt.Errorf("failed to upload part %d: %w", j.number, err))
+					continue
+				}
+				mu.Lock()
+				state.Parts[j.number] = uploadedPart{Size: int64(len(j.data)), SHA256: j.sum, ETag: aws.ToString(out.ETag)}
+				state.UpdatedAt = time.Now().UTC()
+				err = b.saveUploadState(state)
+				mu.Unlock()
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	// Read parts in order and hand the ones not already uploaded to the workers
+	number, resumed, changed := int32(0), 0, false
+	data := first
+	for ctx.Err() == nil {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		if number > 0 {
+			data = make([]byte, state.PartSize)
+			n, err := io.ReadFull(body, data)
+			if err == io.EOF {
+				<-slots
+				break // Ended on a part boundary
+			}
+			if err != nil && err != io.ErrUnexpectedEOF {
+				<-slots
+				fail(fmt.Errorf("failed to read upload body: %w", err))
+				break
+			}
+			data = data[:n]
+		}
+		number++
+		if number > maxUploadParts {
+			<-slots
+			fail(fmt.Errorf("object is larger than %d parts of %d bytes; raise part_size_mb", maxUploadParts, state.PartSize))
+			break
+		}
+		h := sha256.Sum256(data)
+		sum := hex.EncodeToString(h[:])
+		var saveErr error
+		mu.Lock()
+		sent, ok := state.Parts[number]
+		same := ok && sent.SHA256 == sum && sent.Size == int64(len(data))
+		if !same && !(ok && state.Salt != nil) {
+			// Recorded before it's sent, so a resumed encrypted upload knows
+			// what left this machine under its salt
+			state.Parts[number] = uploadedPart{Size: int64(len(data)), SHA256: sum}
+			saveErr = b.saveUploadState(state)
+		}
+		mu.Unlock()
+		switch {
+		case same && sent.ETag != "":
+			<-slots
+			resumed++
+		case ok && !same && state.Salt != nil:
+			<-slots
+			changed = true
+			fail(fmt.Errorf("part %d differs from the one sent before the upload was interrupted", number))
+		case saveErr != nil:
+			<-slots
+			fail(saveErr)
+		default:
+			select {
+			case jobs <- job{number, data, sum}:
+			case <-ctx.Done():
+				<-slots
+			}
+		}
+		if int64(len(data)) < state.PartSize {
+			break // A short part is the last
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if changed {
+		b.discardUpload(parent, key)
+		return fmt.Errorf("%w (the content changed since the upload was interrupted; it will start over)", uploadErr)
+	}
+	if uploadErr != nil {
+		return fmt.Errorf("%w (the upload will resume from the parts already sent)", uploadErr)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if resumed > 0 {
+		log.Printf("Backup: Reused %d unchanged part(s) of %s instead of sending them again", resumed, b.Location(key))
+	}
+
+	// Parts beyond the end of this stream (left from a longer earlier attempt) are left out
+	parts := make([]types.CompletedPart, 0, number)
+	for n := int32(1); n <= number; n++ {
+		parts = append(parts, types.CompletedPart{PartNumber: aws.Int32(n), ETag: aws.String(state.Parts[n].ETag)})
+	}
+	_, err = b.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(b.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(state.UploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	var noSuchUpload *types.NoSuchUpload
+	if err != nil && !errors.As(err, &noSuchUpload) {
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+	os.Remove(b.statePath(key))
+	if err != nil {
+		return fmt.Errorf("failed to complete upload (it was aborted meanwhile): %w", err)
+	}
+	return nil
+}
+
+// startUpload returns the state of the multipart upload to continue for
+// key: the recorded one if S3 still has it (trusting only the parts S3 agrees
+// were uploaded), or a newly created one. An upload encrypted under another
+// salt (or none) can't be continued.
+func (b *S3Backend) startUpload(ctx context.Context, key string, salt []byte) (*uploadState, error) {
+	path := b.statePath(key)
+	state, err := b.loadUploadState(path)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil && (state.Bucket != b.bucket || state.Key != key || state.PartSize != b.partSize || !bytes.Equal(state.Salt, salt)) {
+		b.abortUpload(ctx, state) // Parts of a different size or encryption can't be reused
+		state = nil
+	}
+	var sent map[int32]uploadedPart // What already left this machine under salt
+	if state != nil {
+		etags, err := b.listParts(ctx, key, state.UploadID)
+		var noSuchUpload *types.NoSuchUpload
+		switch {
+		case errors.As(err, &noSuchUpload):
+			log.Printf("Backup: Earlier upload of %s no longer exists; starting over", b.Location(key))
+			sent = state.Parts
+			state = nil
+		case err != nil:
+			return nil, fmt.Errorf("failed to list uploaded parts of %s: %w", b.Location(key), err)
+		default:
+			confirmed := 0
+			for n, p := range state.Parts {
+				if etags[n] != p.ETag || p.ETag == "" {
+					p.ETag = "" // Sent (maybe), but not usable
+					state.Parts[n] = p
+				} else {
+					confirmed++
+				}
+			}
+			log.Printf("Backup: Resuming earlier upload of %s with %d part(s) already sent", b.Location(key), confirmed)
+			return state, nil
+		}
+	}
+
+	out, err := b.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start multipart upload to %s: %w", b.Location(key), err)
+	}
+	now := time.Now().UTC()
+	state = &uploadState{
+		Bucket:    b.bucket,
+		Key:       key,
+		UploadID:  aws.ToString(out.UploadId),
+		PartSize:  b.partSize,
+		Salt:      salt,
+		Parts:     map[int32]uploadedPart{},
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	if salt != nil {
+		for n, p := range sent {
+			p.ETag = ""
+			state.Parts[n] = p
+		}
+	}
+	if err := b.saveUploadState(state); err != nil {
+		b.abortUpload(ctx, state)
+		return nil, err
+	}
+	return state, nil
+}
+
+// listParts returns the ETag of every part S3 has for an upload.
+func (b *S3Backend) listParts(ctx context.Context, key, uploadID string) (map[int32]string, error) {
+	etags := map[int32]string{}
+	paginator := s3.NewListPartsPaginator(b.client, &s3.ListPartsInput{
+		Bucket:   aws.String(b.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Parts {
+			etags[aws.ToInt32(p.PartNumber)] = aws.ToString(p.ETag)
+		}
+	}
+	return etags, nil
+}
+
+// abortUpload aborts a multipart upload, freeing its parts, and forgets it.
+func (b *S3Backend) abortUpload(ctx context.Context, state *uploadState) error {
+	_, err := b.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(state.Bucket),
+		Key:      aws.String(state.Key),
+		UploadId: aws.String(state.UploadID),
+	})
+	var noSuchUpload *types.NoSuchUpload
+	if err != nil && !errors.As(err, &noSuchUpload) {
+		return fmt.Errorf("failed to abort upload to %s: %w", b.Location(state.Key), err)
+	}
+	if err := os.Remove(b.statePath(state.Key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// UploadSalt returns the salt of the encrypted object whose upload to key was
+// interrupted, or nil. Encrypting it again under the same salt lets the parts
+// already sent be reused.
+func (b *S3Backend) UploadSalt(key string) []byte {
+	state, err := b.loadUploadState(b.statePath(key))
+	if err != nil || state == nil || state.Bucket != b.bucket || state.Key != key || state.PartSize != b.partSize {
+		return nil
+	}
+	return state.Salt
+}
+
+// discardUpload aborts the recorded upload to key, if any: used when the
+// key was just written in one piece, so the earlier attempt isn't needed.
+func (b *S3Backend) discardUpload(ctx context.Context, key string) {
+	state, err := b.loadUploadState(b.statePath(key))
+	if err != nil || state == nil {
+		return
+	}
+	if err := b.abortUpload(ctx, state); err != nil {
+		log.Printf("Backup Warning: %v", err)
+	}
+}
+
+// CleanupUploads aborts the incomplete multipart uploads under prefix that
+// won't be resumed: ours that haven't progressed in staleUploadAge, and ones
+// we have no record of once they're orphanUploadAge old. It returns a line
+// per upload aborted (or, with dryRun, that would be).
+func (b *S3Backend) CleanupUploads(ctx context.Context, prefix string, dryRun bool) ([]string, error) {
+	tracked := map[string]*uploadState{} // Upload ID -> state
+	stateFiles := map[string]string{}    // Upload ID -> state file
+	files, _ := filepath.Glob(filepath.Join(b.stateDir, "*.json"))
+	for _, f := range files {
+		if state, err := b.loadUploadState(f); err == nil && state != nil && state.Bucket == b.bucket {
+			tracked[state.UploadID] = state
+			stateFiles[state.UploadID] = f
+		}
+	}
+
+	var aborted []string
+	paginator := s3.NewListMultipartUploadsPaginator(b.client, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return aborted, fmt.Errorf("failed to list multipart uploads in s3://%s/%s: %w", b.bucket, prefix, err)
+		}
+		for _, u := range page.Uploads {
+			state := tracked[aws.ToString(u.UploadId)]
+			delete(tracked, aws.ToString(u.UploadId))
+			started := aws.ToTime(u.Initiated)
+			switch {
+			case state != nil && time.Since(state.UpdatedAt) < staleUploadAge:
+				continue // Waiting to resume
+			case state == nil && time.Since(started) < orphanUploadAge:
+				continue // Possibly still running elsewhere
+			}
+			aborted = append(aborted, fmt.Sprintf("%s (started %s)", b.Location(aws.ToString(u.Key)), started.Local().Format("2006-01-02 15:04")))
+			if dryRun {
+				continue
+			}
+			if state == nil {
+				state = &uploadState{Bucket: b.bucket, Key: aws.ToString(u.Key), UploadID: aws.ToString(u.UploadId)}
+			}
+			if err := b.abortUpload(ctx, state); err != nil {
+				return aborted, err
+			}
+		}
+	}
+
+	// Records of uploads S3 no longer has can't be resumed either
+	if !dryRun {
+		for id, state := range tracked {
+			if strings.HasPrefix(state.Key, prefix) {
+				os.Remove(stateFiles[id])
+			}
+		}
+	}
+	sort.Strings(aborted)
+	return aborted, nil
+}
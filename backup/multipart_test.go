@@ -0,0 +1,285 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeS3 keeps objects and multipart uploads in memory. Calls the tests
+// don't need fall through to the nil s3API and panic.
+type fakeS3 struct {
+	s3API
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int32][]byte // Upload ID -> part number -> content
+	nextID   int
+	failFrom int32   // When set, parts from this number on fail to upload
+	sent     []int32 // Part numbers uploaded successfully
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, uploads: map[string]map[int32][]byte{}}
+}
+
+func etag(data []byte) string {
+	return fmt.Sprintf("%q", fmt.Sprintf("%x", sha256.Sum256(data))[:16])
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f *fakeS3) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	id := fmt.Sprintf("upload-%d", f.nextID)
+	f.uploads[id] = map[int32][]byte{}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (f *fakeS3) UploadPart(ctx context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	number := aws.ToInt32(in.PartNumber)
+	if f.failFrom > 0 && number >= f.failFrom {
+		return nil, errors.New("connection reset")
+	}
+	parts, ok := f.uploads[aws.ToString(in.UploadId)]
+	if !ok {
+		return nil, &types.NoSuchUpload{}
+	}
+	parts[number] = data
+	f.sent = append(f.sent, number)
+	return &s3.UploadPartOutput{ETag: aws.String(etag(data))}, nil
+}
+
+func (f *fakeS3) ListParts(ctx context.Context, in *s3.ListPartsInput, _ ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts, ok := f.uploads[aws.ToString(in.UploadId)]
+	if !ok {
+		return nil, &types.NoSuchUpload{}
+	}
+	out := &s3.ListPartsOutput{}
+	for n, data := range parts {
+		out.Parts = append(out.Parts, types.Part{PartNumber: aws.Int32(n), ETag: aws.String(etag(data))})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts, ok := f.uploads[aws.ToString(in.UploadId)]
+	if !ok {
+		return nil, &types.NoSuchUpload{}
+	}
+	var object []byte
+	for _, p := range in.MultipartUpload.Parts {
+		data, ok := parts[aws.ToInt32(p.PartNumber)]
+		if !ok || etag(data) != aws.ToString(p.ETag) {
+			return nil, fmt.Errorf("InvalidPart: part %d", aws.ToInt32(p.PartNumber))
+		}
+		object = append(object, data...)
+	}
+	f.objects[aws.ToString(in.Key)] = object
+	delete(f.uploads, aws.ToString(in.UploadId))
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeS3) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.uploads[aws.ToString(in.UploadId)]; !ok {
+		return nil, &types.NoSuchUpload{}
+	}
+	delete(f.uploads, aws.ToString(in.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// interrupt makes uploads fail from part number on (0 for never) and
+// returns the parts sent since the last call.
+func (f *fakeS3) interrupt(number int32) []int32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sent := f.sent
+	f.sent, f.failFrom = nil, number
+	sort.Slice(sent, func(i, j int) bool { return sent[i] < sent[j] })
+	return sent
+}
+
+const testPartSize = 1024
+
+func newTestS3Backend(t *testing.T) (*S3Backend, *fakeS3) {
+	fake := newFakeS3()
+	return &S3Backend{client: fake, bucket: "bucket", partSize: testPartSize, concurrency: 2, stateDir: t.TempDir()}, fake
+}
+
+// uploadStateFiles returns the upload states recorded in the backend's state directory.
+func uploadStateFiles(t *testing.T, b *S3Backend) []string {
+	files, err := filepath.Glob(filepath.Join(b.stateDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestMultipartResume(t *testing.T) {
+	ctx := context.Background()
+	b, fake := newTestS3Backend(t)
+	content := testContent(5*testPartSize + 500) // Six parts
+
+	fake.interrupt(4)
+	err := b.Put(ctx, "commit-a.tar.gz", bytes.NewReader(content))
+	if err == nil || !strings.Contains(err.Error(), "resume") {
+		t.Fatalf("got %v, want an upload error that says it will resume", err)
+	}
+	if len(uploadStateFiles(t, b)) != 1 {
+		t.Fatal("the interrupted upload wasn't recorded")
+	}
+
+	fake.interrupt(0)
+	if err := b.Put(ctx, "commit-a.tar.gz", bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if sent := fake.interrupt(0); fmt.Sprint(sent) != "[4 5 6]" {
+		t.Fatalf("resumed upload sent parts %v, want [4 5 6]", sent)
+	}
+	if !bytes.Equal(fake.objects["commit-a.tar.gz"], content) {
+		t.Fatal("the completed object differs from what was uploaded")
+	}
+	if files := uploadStateFiles(t, b); len(files) != 0 {
+		t.Fatalf("upload state left behind: %v", files)
+	}
+}
+
+func TestMultipartResumeSendsChangedParts(t *testing.T) {
+	ctx := context.Background()
+	b, fake := newTestS3Backend(t)
+	content := testContent(5*testPartSize + 500)
+
+	fake.interrupt(4)
+	if err := b.Put(ctx, "bundle.bundle", bytes.NewReader(content)); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+
+	changed := append([]byte(nil), content...)
+	changed[testPartSize+10] ^= 0xFF // In part 2
+	fake.interrupt(0)
+	if err := b.Put(ctx, "bundle.bundle", bytes.NewReader(changed)); err != nil {
+		t.Fatal(err)
+	}
+	if sent := fake.interrupt(0); fmt.Sprint(sent) != "[2 4 5 6]" {
+		t.Fatalf("resumed upload sent parts %v, want [2 4 5 6]", sent)
+	}
+	if !bytes.Equal(fake.objects["bundle.bundle"], changed) {
+		t.Fatal("the completed object differs from what was uploaded")
+	}
+}
+
+func TestEncryptedMultipartResume(t *testing.T) {
+	ctx := context.Background()
+	s3Backend, fake := newTestS3Backend(t)
+	backend := &encryptedBackend{Backend: s3Backend, key: testKey}
+	content := testContent(5*testPartSize + 500)
+
+	fake.interrupt(4)
+	if err := backend.Put(ctx, "commit-a.tar.gz", bytes.NewReader(content)); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+
+	// Encrypted again under the recorded salt, the first parts come out the same
+	fake.interrupt(0)
+	if err := backend.Put(ctx, "commit-a.tar.gz", bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if sent := fake.interrupt(0); fmt.Sprint(sent) != "[4 5 6]" {
+		t.Fatalf("resumed upload sent parts %v, want [4 5 6]", sent)
+	}
+	if got := readObject(t, backend, "commit-a.tar.gz"); got != string(content) {
+		t.Fatal("the completed object doesn't decrypt to what was uploaded")
+	}
+}
+
+func TestEncryptedMultipartChangedContentStartsOver(t *testing.T) {
+	ctx := context.Background()
+	s3Backend, fake := newTestS3Backend(t)
+	backend := &encryptedBackend{Backend: s3Backend, key: testKey}
+	content := testContent(5*testPartSize + 500)
+
+	fake.interrupt(4)
+	if err := backend.Put(ctx, "bundle.bundle", bytes.NewReader(content)); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+
+	// Different content must never go out under the salt already used
+	changed := append([]byte(nil), content...)
+	changed[10] ^= 0xFF
+	fake.interrupt(0)
+	err := backend.Put(ctx, "bundle.bundle", bytes.NewReader(changed))
+	if err == nil || !strings.Contains(err.Error(), "start over") {
+		t.Fatalf("got %v, want an error saying the upload starts over", err)
+	}
+	if sent := fake.interrupt(0); len(sent) != 0 {
+		t.Fatalf("sent parts %v of changed content under the old salt", sent)
+	}
+	if len(fake.uploads) != 0 || len(uploadStateFiles(t, s3Backend)) != 0 {
+		t.Fatal("the interrupted upload wasn't discarded")
+	}
+
+	if err := backend.Put(ctx, "bundle.bundle", bytes.NewReader(changed)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, backend, "bundle.bundle"); got != string(changed) {
+		t.Fatal("the completed object doesn't decrypt to what was uploaded")
+	}
+}
+
+// The state file is what lets an upload resume after the process restarts.
+func TestMultipartResumeAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	b, fake := newTestS3Backend(t)
+	content := testContent(3*testPartSize + 1)
+
+	fake.interrupt(3)
+	if err := b.Put(ctx, "commit-a.tar.gz", bytes.NewReader(content)); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	restarted := &S3Backend{client: fake, bucket: b.bucket, partSize: b.partSize, concurrency: 1, stateDir: b.stateDir}
+	fake.interrupt(0)
+	if err := restarted.Put(ctx, "commit-a.tar.gz", bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if sent := fake.interrupt(0); fmt.Sprint(sent) != "[3 4]" {
+		t.Fatalf("resumed upload sent parts %v, want [3 4]", sent)
+	}
+	if _, err := os.Stat(b.statePath("commit-a.tar.gz")); !os.IsNotExist(err) {
+		t.Fatal("upload state left behind")
+	}
+}
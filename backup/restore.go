@@ -0,0 +1,274 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+// CommitBackup describes one commit archive found in the backup target.
+type CommitBackup struct {
+	Commit       string
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// archiveName returns the object name used for a commit's archive.
+func archiveName(commitHash string) string {
+	return fmt.Sprintf("commit-%s.tar.gz", commitHash)
+}
+
+// ListCommitBackups lists the commit archives stored directly under the
+// configured prefix, newest first.
+func ListCommitBackups(ctx context.Context, backend Backend, cfg *config.BackupConfig) ([]CommitBackup, error) {
+	prefix := ObjectKey(cfg, "commit-")
+	objects, err := backend.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []CommitBackup
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, prefix)
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".tar.gz") {
+			continue // Not a commit archive (or in a nested folder)
+		}
+		backups = append(backups, CommitBackup{
+			Commit:       strings.TrimSuffix(name, ".tar.gz"),
+			Key:          obj.Key,
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+		})
+	}
+	sortNewestFirst(backups)
+	return backups, nil
+}
+
+func sortNewestFirst(backups []CommitBackup) {
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].LastModified.After(backups[j].LastModified)
+	})
+}
+
+// FindCommitBackup resolves a full or abbreviated commit hash to exactly one backup.
+func FindCommitBackup(ctx context.Context, backend Backend, cfg *config.BackupConfig, commit string) (CommitBackup, error) {
+	if commit == "" {
+		return CommitBackup{}, fmt.Errorf("a commit hash is required")
+	}
+	backups, err := ListCommitBackups(ctx, backend, cfg)
+	if err != nil {
+		return CommitBackup{}, err
+	}
+	return matchCommitBackup(backups, commit)
+}
+
+// matchCommitBackup picks the one backup whose commit starts with commit.
+func matchCommitBackup(backups []CommitBackup, commit string) (CommitBackup, error) {
+	var matches []CommitBackup
+	for _, b := range backups {
+		if strings.HasPrefix(b.Commit, commit) {
+			matches = append(matches, b)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return CommitBackup{}, fmt.Errorf("no backup found for commit %s: %w", commit, ErrNotFound)
+	case 1:
+		return matches[0], nil
+	default:
+		return CommitBackup{}, fmt.Errorf("commit prefix %s is ambiguous (%d backups match)", commit, len(matches))
+	}
+}
+
+// RestoreCommit downloads a commit archive and extracts it into targetDir.
+// A non-empty targetDir is refused unless force is set.
+func RestoreCommit(ctx context.Context, backend Backend, cfg *config.BackupConfig, commit, targetDir string, force bool) error {
+	found, err := FindCommitBackup(ctx, backend, cfg, commit)
+	if err != nil {
+		return err
+	}
+
+	if err := prepareTarget(targetDir, force); err != nil {
+		return err
+	}
+
+	log.Printf("Restore: Downloading %s", backend.Location(found.Key))
+	body, err := backend.Get(ctx, found.Key)
+	if err != nil {
+		return fmt.Errorf("failed to download backup: %w", err)
+	}
+	defer body.Close()
+
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return fmt.Errorf("backup %s is not a valid gzip stream: %w", found.Key, err)
+	}
+	defer gz.Close()
+
+	count, err := ExtractTar(gz, targetDir, force)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", found.Key, err)
+	}
+	log.Printf("Restore: Extracted %d file(s) from commit %s into %s", count, found.Commit, targetDir)
+	return nil
+}
+
+// prepareTarget creates targetDir, refusing to reuse a non-empty directory unless forced.
+func prepareTarget(targetDir string, force bool) error {
+	entries, err := os.ReadDir(targetDir)
+	if err == nil && len(entries) > 0 && !force {
+		return fmt.Errorf("target directory %s is not empty (use -force to overwrite)", targetDir)
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read target directory %s: %w", targetDir, err)
+	}
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return fmt.Errorf("failed to create target directory %s: %w", targetDir, err)
+	}
+	return nil
+}
+
+// ExtractTar writes every entry of a tar stream under targetDir and returns
+// the number of files written. Entries that would land outside targetDir
+// (absolute paths, "..", symlinks pointing out) are rejected.
+func ExtractTar(r io.Reader, targetDir string, overwrite bool) (int, error) {
+	root, err := filepath.Abs(targetDir)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("corrupt tar stream: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue // git archive stores the commit id here
+		}
+
+		dest, err := safeJoin(root, hdr.Name)
+		if err != nil {
+			return count, err
+		}
+		if err := checkParentInside(root, dest); err != nil {
+			return count, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return count, fmt.Errorf("failed to create directory %s: %w", dest, err)
+			}
+		case tar.TypeReg:
+			if err := writeFile(dest, tr, hdr.FileInfo().Mode().Perm(), overwrite); err != nil {
+				return count, err
+			}
+			count++
+		case tar.TypeSymlink:
+			linkTarget := hdr.Linkname
+			if !filepath.IsAbs(linkTarget) {
+				linkTarget = filepath.Join(filepath.Dir(dest), linkTarget)
+			}
+			if !isInside(root, filepath.Clean(linkTarget)) {
+				return count, fmt.Errorf("refusing symlink %s -> %s: points outside %s", hdr.Name, hdr.Linkname, root)
+			}
+			if err := removeExisting(dest, overwrite); err != nil {
+				return count, err
+			}
+			if err := os.Symlink(hdr.Linkname, dest); err != nil {
+				return count, fmt.Errorf("failed to create symlink %s: %w", dest, err)
+			}
+			count++
+		default:
+			log.Printf("Restore Warning: Skipping unsupported tar entry %s (type %c)", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+// safeJoin resolves a tar entry name under root, rejecting path traversal.
+func safeJoin(root, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("refusing absolute path in archive: %s", name)
+	}
+	dest := filepath.Join(root, filepath.FromSlash(name))
+	if !isInside(root, dest) {
+		return "", fmt.Errorf("refusing path outside target directory: %s", name)
+	}
+	return dest, nil
+}
+
+// checkParentInside makes sure an existing parent directory doesn't resolve
+// (via a symlink) to somewhere outside root.
+func checkParentInside(root, dest string) error {
+	parent := filepath.Dir(dest)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", parent, err)
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(parent)
+	if err != nil {
+		return err
+	}
+	if !isInside(resolvedRoot, resolved) {
+		return fmt.Errorf("refusing to write %s: parent directory resolves outside target", dest)
+	}
+	return nil
+}
+
+// isInside reports whether p is root or a path below it.
+func isInside(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// removeExisting clears dest for overwriting, or fails if overwrite is off.
+func removeExisting(dest string, overwrite bool) error {
+	if _, err := os.Lstat(dest); os.IsNotExist(err) {
+		return nil
+	}
+	if !overwrite {
+		return fmt.Errorf("refusing to overwrite existing %s", dest)
+	}
+	if err := os.Remove(dest); err != nil {
+		return fmt.Errorf("failed to remove existing %s: %w", dest, err)
+	}
+	return nil
+}
+
+// writeFile copies one tar entry to dest. Any existing file (or symlink) is
+// removed first so we never write through a link.
+func writeFile(dest string, r io.Reader, perm os.FileMode, overwrite bool) error {
+	if err := removeExisting(dest, overwrite); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm|0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,322 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"git-monitor-app/config"    // Use correct module path
+	"git-monitor-app/gitutil"   // Use correct module path
+	"git-monitor-app/validator" // Use correct module path
+)
+
+// blobGracePeriod protects recently uploaded blobs from pruning: a backup
+// running at the same time uploads its blobs before its tree manifest.
+const blobGracePeriod = 24 * time.Hour
+
+// A backup running during a prune may reuse a blob the prune is about to
+// delete. While deleting blobs, Prune keeps pruneLockName in place, and it
+// rewrites prunedStampName before removing the lock whenever blobs went. A
+// backup that reused blobs waits for the lock to go (see waitForPrune) and
+// uploads any that are gone if there was a lock or the stamp changed while it
+// ran. The stamp also tells dedup indexes cached elsewhere to rebuild.
+const (
+	pruneLockName   = "locks/prune"
+	prunedStampName = "locks/pruned"
+	pruneLockMaxAge = 6 * time.Hour // A lock this old is left from a prune that died
+)
+
+// RetentionItem is one backed-up commit and the retention decision for it.
+type RetentionItem struct {
+	Commit  string
+	Time    time.Time // Commit date, or upload time for commits the repository doesn't have
+	Keys    []string  // The commit's archive and/or tree manifest
+	Keep    bool
+	Reasons []string // Why it's kept
+}
+
+func (item *RetentionItem) keep(reason string) {
+	item.Keep = true
+	item.Reasons = append(item.Reasons, reason)
+}
+
+// PlanRetention applies the configured retention rules to every commit
+// archive and tree manifest, newest first. Commits that are tagged or touch
+// an export with finalStatus are always kept, as are commits the repository
+// no longer has, since the backup may be their only copy. Bundles aren't
+// included: each one is needed by the ones after it in its chain.
+func PlanRetention(ctx context.Context, backend Backend, cfg *config.BackupConfig, repoPath, finalStatus string, now time.Time) ([]RetentionItem, error) {
+	archives, err := ListCommitBackups(ctx, backend, cfg)
+	if err != nil {
+		return nil, err
+	}
+	trees, err := ListTreeBackups(ctx, backend, cfg)
+	if err != nil {
+		return nil, err
+	}
+	byCommit := map[string]*RetentionItem{}
+	var items []*RetentionItem
+	for _, b := range append(archives, trees...) {
+		item := byCommit[b.Commit]
+		if item == nil {
+			item = &RetentionItem{Commit: b.Commit, Time: b.LastModified}
+			byCommit[b.Commit] = item
+			items = append(items, item)
+		}
+		item.Keys = append(item.Keys, b.Key)
+	}
+
+	refs, err := gitutil.ListRefs(repoPath, "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]string{} // Commit -> tag name
+	for ref, hash := range refs {
+		name := strings.TrimPrefix(ref, "refs/tags/")
+		if existing, ok := tags[hash]; !ok || name < existing {
+			tags[hash] = name
+		}
+	}
+
+	missing := map[*RetentionItem]bool{}
+	for _, item := range items {
+		if !gitutil.CommitExists(repoPath, item.Commit) {
+			item.keep("not in repository")
+			missing[item] = true
+			continue
+		}
+		if when, err := gitutil.CommitTime(repoPath, item.Commit); err == nil {
+			item.Time = when
+		}
+		if tag, ok := tags[item.Commit]; ok {
+			item.keep("tag " + tag)
+		}
+		export, err := finalExportTouched(repoPath, item.Commit, finalStatus)
+		if err != nil {
+			item.keep("exports unknown")
+		} else if export != "" {
+			item.keep(finalStatus + " export")
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Time.After(items[j].Time)
+	})
+	// Backups of commits the repository doesn't have (perhaps another
+	// repository's, sharing the prefix) are kept anyway and take up no slots
+	r := cfg.Retention
+	days, weeks := map[string]bool{}, map[string]bool{}
+	counted := 0
+	for _, item := range items {
+		if missing[item] {
+			continue
+		}
+		if counted < r.KeepLast {
+			item.keep(fmt.Sprintf("last %d", r.KeepLast))
+		}
+		counted++
+		// Newest first, so the first backup seen for a day or week is its newest
+		local := item.Time.Local()
+		if r.KeepDaily > 0 && local.After(now.AddDate(0, 0, -r.KeepDaily)) {
+			if day := local.Format("2006-01-02"); !days[day] {
+				days[day] = true
+				item.keep("daily " + day)
+			}
+		}
+		if r.KeepWeekly > 0 && local.After(now.AddDate(0, 0, -7*r.KeepWeekly)) {
+			year, week := local.ISOWeek()
+			if key := fmt.Sprintf("%d-W%02d", year, week); !weeks[key] {
+				weeks[key] = true
+				item.keep("weekly " + key)
+			}
+		}
+	}
+
+	plan := make([]RetentionItem, len(items))
+	for i, item := range items {
+		plan[i] = *item
+	}
+	return plan, nil
+}
+
+// finalExportTouched returns the first export with the final status that a
+// commit adds or changes, or "" if there is none.
+func finalExportTouched(repoPath, commitHash, finalStatus string) (string, error) {
+	files, err := gitutil.GetChangedFilesInCommit(repoPath, commitHash)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		folder := validator.ProjectFolder(f)
+		if folder == "" || !strings.HasPrefix(f, folder+"/exports/") {
+			continue
+		}
+		if _, status, ok := validator.ParseExportName(f); ok && strings.EqualFold(status, finalStatus) {
+			return f, nil
+		}
+	}
+	return "", nil
+}
+
+// PruneResult lists what Prune deleted (or, in a dry run, would delete).
+type PruneResult struct {
+	Commits   int      // Commits whose backups were pruned
+	Deleted   []string // Keys of pruned archives, tree manifests, metadata and reports
+	Blobs     int      // Blobs no remaining tree manifest refers to
+	BlobBytes int64
+}
+
+// Prune deletes the backups of every commit the plan doesn't keep, along
+// with their metadata and reports, then the blobs that no remaining tree
+// manifest refers to. With dryRun set nothing is deleted, but the result is
+// the same.
+func Prune(ctx context.Context, backend Backend, cfg *config.BackupConfig, plan []RetentionItem, dryRun bool) (*PruneResult, error) {
+	result := &PruneResult{}
+	remove := func(key string) error {
+		if dryRun {
+			return nil
+		}
+		if err := backend.Delete(ctx, key); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to delete %s: %w", backend.Location(key), err)
+		}
+		return nil
+	}
+
+	for _, item := range plan {
+		if item.Keep {
+			continue
+		}
+		// The backup itself first, so a failure never leaves it without its metadata
+		keys := append([]string(nil), item.Keys...)
+		for _, name := range []string{"meta/commit-" + item.Commit, "reports/commit-" + item.Commit} {
+			objects, err := backend.List(ctx, ObjectKey(cfg, name))
+			if err != nil {
+				return result, err
+			}
+			for _, obj := range objects {
+				rest := strings.TrimPrefix(obj.Key, ObjectKey(cfg, name))
+				if strings.HasPrefix(rest, ".") { // Not another commit sharing the prefix
+					keys = append(keys, obj.Key)
+				}
+			}
+		}
+		for _, key := range keys {
+			if err := remove(key); err != nil {
+				return result, err
+			}
+			result.Deleted = append(result.Deleted, key)
+		}
+		result.Commits++
+	}
+
+	// Blobs, if this destination has any
+	prefix := ObjectKey(cfg, "blobs/")
+	blobs, err := backend.List(ctx, prefix)
+	if err != nil || len(blobs) == 0 {
+		return result, err
+	}
+	if !dryRun {
+		lockKey := ObjectKey(cfg, pruneLockName)
+		if err := backend.Put(ctx, lockKey, strings.NewReader(time.Now().UTC().Format(time.RFC3339))); err != nil {
+			return result, fmt.Errorf("failed to create %s: %w", backend.Location(lockKey), err)
+		}
+		defer backend.Delete(context.Background(), lockKey)
+	}
+
+	// Listed only now the lock is in place, so a manifest stored by a backup
+	// running meanwhile is either seen here or its backup sees the lock
+	trees, err := ListTreeBackups(ctx, backend, cfg)
+	if err != nil {
+		return result, err
+	}
+	pruned := map[string]bool{}
+	for _, item := range plan {
+		if !item.Keep {
+			for _, key := range item.Keys {
+				pruned[key] = true
+			}
+		}
+	}
+	referenced := map[string]bool{}
+	for _, tree := range trees {
+		if pruned[tree.Key] {
+			continue // Still listed in a dry run
+		}
+		// A manifest we can't read could refer to anything; don't guess
+		manifest, err := readTreeManifest(ctx, backend, tree.Key)
+		if err != nil {
+			return result, fmt.Errorf("not pruning blobs: %w", err)
+		}
+		for _, f := range manifest.Files {
+			referenced[f.Blob] = true
+		}
+	}
+	for _, obj := range blobs {
+		hash := strings.TrimPrefix(obj.Key, prefix)
+		if strings.Contains(hash, "/") || strings.HasPrefix(hash, ".") || referenced[hash] || time.Since(obj.LastModified) < blobGracePeriod {
+			continue
+		}
+		if err = remove(obj.Key); err != nil {
+			break // Still stamp the blobs already gone
+		}
+		result.Blobs++
+		result.BlobBytes += obj.Size
+	}
+	if dryRun || result.Blobs == 0 {
+		return result, err
+	}
+	stampKey := ObjectKey(cfg, prunedStampName)
+	if err := backend.Put(ctx, stampKey, strings.NewReader(time.Now().UTC().Format(time.RFC3339Nano))); err != nil {
+		return result, fmt.Errorf("failed to update %s: %w", backend.Location(stampKey), err)
+	}
+	if err != nil {
+		return result, err
+	}
+	return result, dropDedupIndex(backend, cfg)
+}
+
+// lastPruned returns when blobs were last deleted from the destination, or
+// the zero time if they never were.
+func lastPruned(ctx context.Context, backend Backend, cfg *config.BackupConfig) (time.Time, error) {
+	info, err := backend.Stat(ctx, ObjectKey(cfg, prunedStampName))
+	if errors.Is(err, ErrNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to check for pruned blobs: %w", err)
+	}
+	return info.LastModified, nil
+}
+
+// waitForPrune blocks while a prune of the destination is deleting blobs,
+// reporting whether there was one.
+func waitForPrune(ctx context.Context, backend Backend, cfg *config.BackupConfig) (bool, error) {
+	lockKey := ObjectKey(cfg, pruneLockName)
+	waited := false
+	for {
+		info, err := backend.Stat(ctx, lockKey)
+		if errors.Is(err, ErrNotFound) {
+			return waited, nil
+		}
+		if err != nil {
+			return waited, fmt.Errorf("failed to check for a running prune: %w", err)
+		}
+		if time.Since(info.LastModified) > pruneLockMaxAge {
+			log.Printf("Backup Warning: Ignoring %s, left from a prune that didn't finish", backend.Location(lockKey))
+			return true, nil // Its blobs may still have gone
+		}
+		if !waited {
+			log.Printf("Backup: Waiting for the prune of %s to finish...", backend.Location(ObjectKey(cfg, "")))
+		}
+		waited = true
+		select {
+		case <-ctx.Done():
+			return waited, ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
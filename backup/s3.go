@@ -0,0 +1,211 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"git-monitor-app/config" // Use correct module path
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Backend stores backup objects in an S3 or S3-compatible (Wasabi) bucket.
+type S3Backend struct {
+	client      s3API
+	bucket      string
+	partSize    int64  // Objects larger than this go up as multipart uploads in parts of this size
+	concurrency int    // Parts uploaded at once
+	stateDir    string // Where in-progress multipart uploads are recorded
+}
+
+// s3API is the part of the S3 client the backend uses.
+type s3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	s3.ListObjectsV2APIClient
+	s3.ListPartsAPIClient
+	s3.ListMultipartUploadsAPIClient
+}
+
+// NewS3Backend configures an S3 client from the backup settings.
+func NewS3Backend(ctx context.Context, cfg *config.BackupConfig) (*S3Backend, error) {
+	// Basic validation of essential config
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("backup config error: S3 bucket name is required")
+	}
+
+	// --- Configure AWS SDK ---
+	log.Println("Backup: Configuring S3 client...")
+	sdkConfigOptions := []func(*awsConfig.LoadOptions) error{}
+
+	// 1. Custom Endpoint Resolver (Essential for Wasabi/S3 Compatible)
+	if cfg.EndpointURL != "" {
+		customResolver := aws.EndpointResolverWithOptionsFunc( // Use newer resolver type
+			func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+				if service == s3.ServiceID {
+					ep := aws.Endpoint{
+						URL:           cfg.EndpointURL,
+						SigningRegion: cfg.Region, // Use region from config for signing
+					}
+					return ep, nil
+				}
+				return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+			})
+		sdkConfigOptions = append(sdkConfigOptions, awsConfig.WithEndpointResolverWithOptions(customResolver))
+	}
+
+	// 2. Region
+	if cfg.Region != "" {
+		sdkConfigOptions = append(sdkConfigOptions, awsConfig.WithRegion(cfg.Region))
+	}
+
+	// 3. Credentials
+	if cfg.AccessKeyID != "" && cfg.SecretKey != "" {
+		log.Println("Backup: Using static credentials from config file.")
+		staticCreds := credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretKey, "")
+		sdkConfigOptions = append(sdkConfigOptions, awsConfig.WithCredentialsProvider(staticCreds))
+	} else {
+		log.Println("Backup: Using default AWS credential chain.")
+	}
+
+	// Load the final configuration
+	sdkConfig, err := awsConfig.LoadDefaultConfig(ctx, sdkConfigOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS SDK config: %w", err)
+	}
+	if cfg.Region != "" && sdkConfig.Region != cfg.Region {
+		sdkConfig.Region = cfg.Region
+		log.Printf("Backup: Explicitly setting region in loaded SDK config: %s", cfg.Region)
+	}
+
+	backend := &S3Backend{
+		client:      s3.NewFromConfig(sdkConfig),
+		bucket:      cfg.Bucket,
+		partSize:    defaultPartSizeMB << 20,
+		concurrency: defaultUploadConcurrency,
+		stateDir:    cfg.UploadStateDir,
+	}
+	if cfg.PartSizeMB > 0 {
+		backend.partSize = int64(cfg.PartSizeMB) << 20
+	}
+	if cfg.UploadConcurrency > 0 {
+		backend.concurrency = cfg.UploadConcurrency
+	}
+	return backend, nil
+}
+
+// Put buffers up to one part of body. Anything that fits is sent with a
+// single PutObject of known length; anything larger goes up as a resumable
+// multipart upload.
+func (b *S3Backend) Put(ctx context.Context, key string, body io.Reader) error {
+	return b.PutSalted(ctx, key, nil, body)
+}
+
+// PutSalted is Put for an object encrypted under salt. The salt is recorded
+// with a multipart upload so an interrupted one can be encrypted the same way
+// again (see UploadSalt) and resumed.
+func (b *S3Backend) PutSalted(ctx context.Context, key string, salt []byte, body io.Reader) error {
+	var first bytes.Buffer
+	n, err := io.CopyN(&first, body, b.partSize)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read upload body: %w", err)
+	}
+	if n == b.partSize {
+		return b.putMultipart(ctx, key, salt, first.Bytes(), body)
+	}
+
+	_, err = b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(b.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(first.Bytes()),
+		ContentLength: aws.Int64(n),
+	})
+	if err == nil {
+		b.discardUpload(ctx, key) // An earlier, interrupted attempt at this key isn't needed any more
+	}
+	return err
+}
+
+func (b *S3Backend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, wrapS3NotFound(b.Location(key), err)
+	}
+	return out.Body, nil
+}
+
+func (b *S3Backend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", b.bucket, prefix, err)
+		}
+		for _, obj := range page.Contents {
+			info := ObjectInfo{Key: aws.ToString(obj.Key), Size: aws.ToInt64(obj.Size)}
+			if obj.LastModified != nil {
+				info.LastModified = *obj.LastModified
+			}
+			objects = append(objects, info)
+		}
+	}
+	return objects, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (b *S3Backend) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	out, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, wrapS3NotFound(b.Location(key), err)
+	}
+	info := ObjectInfo{Key: key, Size: aws.ToInt64(out.ContentLength)}
+	if out.LastModified != nil {
+		info.LastModified = *out.LastModified
+	}
+	return info, nil
+}
+
+func (b *S3Backend) Location(key string) string {
+	return fmt.Sprintf("s3://%s/%s", b.bucket, key)
+}
+
+// wrapS3NotFound maps the SDK's "no such key" errors onto ErrNotFound.
+func wrapS3NotFound(location string, err error) error {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+		return fmt.Errorf("%s: %w", location, ErrNotFound)
+	}
+	return fmt.Errorf("%s: %w", location, err)
+}
@@ -0,0 +1,210 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"git-monitor-app/config"  // Use correct module path
+	"git-monitor-app/gitutil" // Use correct module path
+)
+
+// VerifyReport is the outcome of checking one commit backup against git.
+type VerifyReport struct {
+	Commit       string
+	Location     string
+	FilesChecked int
+	Missing      []string // In the commit but not in the archive
+	Unexpected   []string // In the archive but not in the commit
+	Mismatched   []string // Present in both, content differs
+}
+
+// OK reports whether the archive matched the commit exactly.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Unexpected) == 0 && len(r.Mismatched) == 0
+}
+
+// Problems renders every mismatch as a human-readable line.
+func (r *VerifyReport) Problems() []string {
+	var problems []string
+	for _, p := range r.Missing {
+		problems = append(problems, fmt.Sprintf("missing from archive: %s", p))
+	}
+	for _, p := range r.Unexpected {
+		problems = append(problems, fmt.Sprintf("not in commit: %s", p))
+	}
+	for _, p := range r.Mismatched {
+		problems = append(problems, fmt.Sprintf("SHA-256 mismatch: %s", p))
+	}
+	return problems
+}
+
+// VerifyCommit streams a commit's archive back from the backend, checks that it
+// decompresses and untars cleanly, and compares its file list and per-file
+// SHA-256 against `git ls-tree -r` for that commit. An error means the archive
+// couldn't be checked at all (or is corrupt); content differences are reported
+// in the returned VerifyReport. A commit without an archive is checked
+// against its dedup tree manifest instead.
+func VerifyCommit(ctx context.Context, backend Backend, cfg *config.BackupConfig, repoPath, commit string) (*VerifyReport, error) {
+	found, err := FindCommitBackup(ctx, backend, cfg, commit)
+	if errors.Is(err, ErrNotFound) {
+		// No archive; the commit may have been backed up in dedup mode
+		var trees []CommitBackup
+		if trees, err = ListTreeBackups(ctx, backend, cfg); err == nil {
+			found, err = matchCommitBackup(trees, commit)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return VerifyBackup(ctx, backend, cfg, repoPath, found)
+}
+
+// VerifyBackup is VerifyCommit for an archive or tree manifest already found
+// by ListCommitBackups or ListTreeBackups, so checking many of them doesn't
+// list the destination again for each.
+func VerifyBackup(ctx context.Context, backend Backend, cfg *config.BackupConfig, repoPath string, found CommitBackup) (*VerifyReport, error) {
+	if strings.HasPrefix(found.Key, ObjectKey(cfg, "trees/")) {
+		return verifyTree(ctx, backend, cfg, repoPath, found)
+	}
+	report := &VerifyReport{Commit: found.Commit, Location: backend.Location(found.Key)}
+
+	expected, err := expectedChecksums(repoPath, found.Commit)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Verify: Downloading %s", report.Location)
+	body, err := backend.Get(ctx, found.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+	defer body.Close()
+
+	actual, err := archiveChecksums(body)
+	if err != nil {
+		return nil, fmt.Errorf("backup %s is corrupt: %w", report.Location, err)
+	}
+	report.compare(expected, actual)
+	return report, nil
+}
+
+// verifyTree checks a dedup backup: every blob its tree manifest lists is
+// downloaded and hashed, and the result compared against the commit the
+// same way as an archive.
+func verifyTree(ctx context.Context, backend Backend, cfg *config.BackupConfig, repoPath string, found CommitBackup) (*VerifyReport, error) {
+	manifest, err := readTreeManifest(ctx, backend, found.Key)
+	if err != nil {
+		return nil, err
+	}
+	report := &VerifyReport{Commit: manifest.Commit, Location: backend.Location(found.Key)}
+
+	expected, err := expectedChecksums(repoPath, manifest.Commit)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Verify: Downloading %d file(s) listed in %s", len(manifest.Files), report.Location)
+	actual, err := treeChecksums(ctx, backend, cfg, manifest)
+	if err != nil {
+		return nil, err
+	}
+	report.compare(expected, actual)
+	return report, nil
+}
+
+// compare fills in the report from the checksums git expects and the ones
+// found in the backup, both keyed by path.
+func (report *VerifyReport) compare(expected, actual map[string]string) {
+	for path, sum := range expected {
+		got, ok := actual[path]
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, path)
+		case got != sum:
+			report.Mismatched = append(report.Mismatched, path)
+		default:
+			report.FilesChecked++
+		}
+	}
+	for path := range actual {
+		if _, ok := expected[path]; !ok {
+			report.Unexpected = append(report.Unexpected, path)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unexpected)
+	sort.Strings(report.Mismatched)
+}
+
+// expectedChecksums hashes every file of the commit straight out of git.
+// Symlinks are hashed by their target, which is what both git and tar store.
+func expectedChecksums(repoPath, commit string) (map[string]string, error) {
+	entries, err := gitutil.ListTree(repoPath, commit)
+	if err != nil {
+		return nil, err
+	}
+	var blobs []string
+	for _, e := range entries {
+		if e.Type == "blob" {
+			blobs = append(blobs, e.Hash)
+		}
+	}
+	sums, err := gitutil.BlobSHA256s(repoPath, blobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash files of commit %s: %w", commit, err)
+	}
+
+	expected := make(map[string]string, len(blobs))
+	for _, e := range entries {
+		if e.Type == "blob" { // Submodules aren't included by git archive
+			expected[e.Path] = sums[e.Hash]
+		}
+	}
+	return expected, nil
+}
+
+// archiveChecksums reads a .tar.gz stream to the end and hashes every file in it.
+func archiveChecksums(r io.Reader) (map[string]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	sums := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corrupt tar stream: %w", err)
+		}
+		h := sha256.New()
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			if _, err := io.Copy(h, tr); err != nil {
+				return nil, fmt.Errorf("failed reading %s from archive: %w", hdr.Name, err)
+			}
+		case tar.TypeSymlink:
+			io.WriteString(h, hdr.Linkname)
+		default:
+			continue // Directories, the pax global header, etc.
+		}
+		sums[strings.TrimPrefix(hdr.Name, "./")] = hex.EncodeToString(h.Sum(nil))
+	}
+	// Drain the gzip trailer so its checksum is verified too
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return nil, fmt.Errorf("corrupt gzip stream: %w", err)
+	}
+	return sums, nil
+}
@@ -0,0 +1,168 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git-monitor-app/config"    // Use correct module path
+	"git-monitor-app/gitutil"   // Use correct module path
+	"git-monitor-app/validator" // Use correct module path
+)
+
+// Formats understood by Write.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Entry is one project folder in the catalog. Folders that don't follow the
+// naming convention are still listed, with Problem explaining why.
+type Entry struct {
+	validator.Project
+	LatestStatus  string    `json:"latest_status,omitempty"` // Most finished export status present
+	Statuses      []string  `json:"statuses"`                // Every export status present, in lifecycle order
+	ExportFormats []string  `json:"export_formats"`          // Lowercase extensions, e.g. "mp3", "wav"
+	LastCommit    string    `json:"last_commit"`             // Most recent commit touching the folder
+	LastModified  time.Time `json:"last_modified"`
+	Problem       string    `json:"problem,omitempty"`
+}
+
+// Build walks src/projects/ in a commit's tree and returns one entry per
+// project folder, sorted by folder name.
+func Build(repoPath, commitHash string, cfg config.ValidationConfig) ([]Entry, error) {
+	entries, err := gitutil.ListTree(repoPath, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rank statuses by their position in the lifecycle
+	statuses := validator.ExportStatuses(cfg)
+	rank := map[string]int{}
+	for i, s := range statuses {
+		rank[s] = i
+	}
+
+	type projectFiles struct {
+		statuses map[string]bool
+		formats  map[string]bool
+	}
+	folders := map[string]*projectFiles{}
+	var names []string
+	for _, e := range entries {
+		folder := validator.ProjectFolder(e.Path)
+		if folder == "" {
+			continue
+		}
+		name := path.Base(folder)
+		files, ok := folders[name]
+		if !ok {
+			files = &projectFiles{statuses: map[string]bool{}, formats: map[string]bool{}}
+			folders[name] = files
+			names = append(names, name)
+		}
+
+		// Only exports named after their own folder count towards its status
+		rel := strings.TrimPrefix(e.Path, folder+"/")
+		if !strings.HasPrefix(rel, "exports/") {
+			continue
+		}
+		if ext := strings.TrimPrefix(strings.ToLower(path.Ext(rel)), "."); ext != "" {
+			files.formats[ext] = true
+		}
+		if base, status, ok := validator.ParseExportName(rel); ok && base == name {
+			if _, known := rank[status]; known {
+				files.statuses[status] = true
+			}
+		}
+	}
+	sort.Strings(names)
+
+	catalog := make([]Entry, 0, len(names))
+	for _, name := range names {
+		files := folders[name]
+		entry := Entry{Statuses: []string{}, ExportFormats: []string{}}
+		if project, err := validator.ParseProjectFolder(name); err != nil {
+			entry.Folder = name
+			entry.Collaborators = []string{}
+			entry.Problem = err.Error()
+		} else {
+			entry.Project = project
+		}
+		for _, s := range statuses {
+			if files.statuses[s] {
+				entry.Statuses = append(entry.Statuses, s)
+				entry.LatestStatus = s
+			}
+		}
+		for ext := range files.formats {
+			entry.ExportFormats = append(entry.ExportFormats, ext)
+		}
+		sort.Strings(entry.ExportFormats)
+
+		entry.LastCommit, entry.LastModified, err = gitutil.LastCommitForPath(repoPath, commitHash, "src/projects/"+name)
+		if err != nil {
+			return nil, err
+		}
+		catalog = append(catalog, entry)
+	}
+	return catalog, nil
+}
+
+// WriteJSON writes the catalog as an indented JSON array.
+func WriteJSON(w io.Writer, entries []Entry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// WriteCSV writes the catalog with a header row. List columns are joined
+// with "; " so they survive being opened in a spreadsheet.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"folder", "title", "collaborators", "key", "bpm", "producer", "latest_status", "statuses", "export_formats", "last_commit", "last_modified", "problem"})
+	for _, e := range entries {
+		bpm := ""
+		if e.BPM > 0 {
+			bpm = strconv.Itoa(e.BPM)
+		}
+		lastModified := ""
+		if !e.LastModified.IsZero() {
+			lastModified = e.LastModified.Format(time.RFC3339)
+		}
+		cw.Write([]string{
+			e.Folder,
+			e.Title,
+			strings.Join(e.Collaborators, "; "),
+			e.Key,
+			bpm,
+			e.Producer,
+			e.LatestStatus,
+			strings.Join(e.Statuses, "; "),
+			strings.Join(e.ExportFormats, "; "),
+			e.LastCommit,
+			lastModified,
+			e.Problem,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Write renders the catalog in the given format (csv or json).
+func Write(w io.Writer, format string, entries []Entry) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, entries)
+	case FormatJSON:
+		return WriteJSON(w, entries)
+	default:
+		return fmt.Errorf("unknown catalog format %q (expected %q or %q)", format, FormatCSV, FormatJSON)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"git-monitor-app/catalog" // Use correct module path
+	"git-monitor-app/config"  // Use correct module path
+	"git-monitor-app/gitutil" // Use correct module path
+)
+
+// runCatalog implements the `catalog` subcommand: list every project folder
+// at a revision with its parsed name, export progress and last change.
+func runCatalog(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("catalog", flag.ExitOnError)
+	repoName := fs.String("repo", "", "Repository to catalog (path or directory name; optional with a single repository)")
+	format := fs.String("format", catalog.FormatCSV, "Output format: csv or json")
+	output := fs.String("output", "", "Write the catalog to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: catalog [-repo name] [-format csv|json] [-output file] [rev]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *format != catalog.FormatCSV && *format != catalog.FormatJSON {
+		return fmt.Errorf("unknown -format %q (expected csv or json)", *format)
+	}
+
+	repo, err := selectRepository(cfg, *repoName)
+	if err != nil {
+		return err
+	}
+	rev := "HEAD"
+	if fs.NArg() > 0 {
+		rev = fs.Arg(0)
+	}
+	commitHash, err := gitutil.ResolveCommit(repo.Path, rev)
+	if err != nil {
+		return err
+	}
+
+	entries, err := catalog.Build(repo.Path, commitHash, cfg.ValidationFor(repo))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create catalog file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	return catalog.Write(w, *format, entries)
+}
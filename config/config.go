@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/BurntSushi/toml"
@@ -13,20 +15,313 @@ import (
 
 // Config holds the application configuration
 type Config struct {
-	RepoPath     string       `toml:"repository_path"`
-	DebounceSecs int          `toml:"debounce_seconds"`
-	Backup       BackupConfig `toml:"backup"`
-	// Add validation rules here if they become configurable
+	RepoPath     string             `toml:"repository_path,omitempty"` // Single-repo shorthand for one [[repositories]] entry
+	DebounceSecs int                `toml:"debounce_seconds"`          // Default for repositories that don't set their own
+	StateFile    string             `toml:"state_file,omitempty"`      // Optional: defaults to state.json next to the config file
+	Backup       BackupConfig       `toml:"backup"`
+	Validation   ValidationConfig   `toml:"validation,omitempty"`   // Defaults for every repository
+	Refs         RefsConfig         `toml:"refs,omitempty"`         // Which branches/tags to follow, for every repository
+	Reports      ReportsConfig      `toml:"reports,omitempty"`      // Machine-readable validation reports
+	Tagging      TaggingConfig      `toml:"tagging,omitempty"`      // Optional export tagging after validation
+	Repositories []RepositoryConfig `toml:"repositories,omitempty"` // Repositories supervised by this daemon
+
+	Path string `toml:"-"` // Absolute path the config was loaded from
+}
+
+// RepositoryConfig holds per-repository settings. Empty fields inherit the
+// top-level defaults when the config is loaded.
+type RepositoryConfig struct {
+	Path         string            `toml:"path"`
+	DebounceSecs int               `toml:"debounce_seconds,omitempty"`
+	BackupPrefix string            `toml:"s3_prefix,omitempty"`  // Overrides [backup] s3_prefix for this repo
+	Validation   *ValidationConfig `toml:"validation,omitempty"` // Overrides individual [validation] settings
+	Refs         *RefsConfig       `toml:"refs,omitempty"`       // Replaces [refs] entirely for this repo
+}
+
+// ReportsConfig controls the machine-readable validation reports the monitor
+// writes for each processed commit.
+type ReportsConfig struct {
+	JSONLFile string `toml:"jsonl_file,omitempty"`         // Append every finding to this JSON lines file (relative to the config file)
+	Upload    *bool  `toml:"upload_with_backup,omitempty"` // Store JSON lines and SARIF reports for each commit in the backup destination (default true)
+}
+
+// UploadEnabled reports whether per-commit reports go to the backup destination.
+func (r ReportsConfig) UploadEnabled() bool {
+	return r.Upload == nil || *r.Upload
+}
+
+// TaggingConfig controls the optional tagging step the monitor runs after a
+// commit passes validation. The monitor never commits to a watched repository
+// itself; it leaves a patch for review, to be applied with `git apply`.
+type TaggingConfig struct {
+	AfterValidation bool   `toml:"after_validation,omitempty"` // Write a patch for each valid commit whose MP3/FLAC tags are out of date
+	PatchDir        string `toml:"patch_dir,omitempty"`        // Where patches go (relative to the config file; default "tag-patches")
+}
+
+// RefsConfig selects which refs the monitor follows. Globs use path.Match
+// syntax against the short branch name, so "release/*" matches "release/1.0"
+// but "*" does not cross a "/".
+type RefsConfig struct {
+	IncludeBranches []string `toml:"include_branches,omitempty"` // Empty means every branch
+	ExcludeBranches []string `toml:"exclude_branches,omitempty"`
+	IncludeTags     bool     `toml:"include_tags,omitempty"` // Also process commits that are only reachable from tags
+}
+
+// FollowsBranch reports whether a branch (short name, e.g. "main") should be monitored.
+func (r RefsConfig) FollowsBranch(name string) bool {
+	if len(r.IncludeBranches) > 0 && !matchAny(r.IncludeBranches, name) {
+		return false
+	}
+	return !matchAny(r.ExcludeBranches, name)
+}
+
+// validate checks that every glob is well-formed.
+func (r RefsConfig) validate() error {
+	for _, pattern := range append(append([]string{}, r.IncludeBranches...), r.ExcludeBranches...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid branch glob %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether name matches any of the (pre-validated) globs.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidationConfig holds the configurable parts of the validation rules.
+// Nil slices and empty strings mean "use the built-in default", which matches
+// the rules the validator has always enforced.
+type ValidationConfig struct {
+	AllowedRootFiles     []string `toml:"allowed_root_files,omitempty"`
+	RequiredRootFiles    []string `toml:"required_root_files,omitempty"`
+	ProjectExtensions    []string `toml:"project_extensions,omitempty"`     // DAW project file extensions, e.g. ["flp", "als", "bwproject"]
+	ExportExtensions     []string `toml:"export_extensions,omitempty"`      // Audio export extensions, e.g. ["wav", "mp3", "flac"]
+	ExportStatuses       []string `toml:"export_statuses,omitempty"`        // Allowed export statuses, in lifecycle order
+	FinalStatus          string   `toml:"final_status,omitempty"`           // Status of delivered masters, guarded against replacement and always kept by retention (default "finalmaster")
+	ProjectFolderPattern string   `toml:"project_folder_pattern,omitempty"` // Regexp a project folder name must match in full
+
+	AudioPolicies []AudioPolicy             `toml:"audio_policies,omitempty"` // Technical requirements for exports, checked against file headers
+	Loudness      map[string]LoudnessTarget `toml:"loudness,omitempty"`       // Delivery targets keyed by export status, e.g. [validation.loudness.mastered]
+
+	Rules map[string]RuleConfig `toml:"rules,omitempty"` // Per-rule tuning, keyed by rule ID (e.g. [validation.rules.no-spaces])
+}
+
+// DefaultFinalStatus is the export status of delivered masters unless
+// final_status says otherwise.
+const DefaultFinalStatus = "finalmaster"
+
+// Rule severities. Only "error" findings block a backup.
+const (
+	SeverityError = "error"
+	SeverityWarn  = "warn"
+	SeverityInfo  = "info"
+)
+
+// AudioPolicy constrains the exports it applies to, e.g. "finalmaster must be
+// 24-bit/48kHz WAV or FLAC". Empty lists don't constrain anything.
+type AudioPolicy struct {
+	Statuses    []string `toml:"statuses,omitempty"`     // Export statuses the policy applies to; empty means all
+	Formats     []string `toml:"formats,omitempty"`      // Allowed formats: "wav", "flac", "mp3"
+	SampleRates []int    `toml:"sample_rates,omitempty"` // Allowed sample rates in Hz, e.g. [48000]
+	BitDepths   []int    `toml:"bit_depths,omitempty"`   // Allowed bits per sample, e.g. [24]; lossy formats have none
+}
+
+// LoudnessTarget is the delivery spec for WAV/FLAC exports of one status.
+// Unset limits aren't checked, and exports of a status without a target
+// aren't decoded at all.
+type LoudnessTarget struct {
+	IntegratedLUFS    *float64 `toml:"integrated_lufs,omitempty"`     // Target integrated loudness, e.g. -14
+	ToleranceLU       float64  `toml:"tolerance_lu,omitempty"`        // Allowed distance from the target (default 1)
+	MaxTruePeakDBTP   *float64 `toml:"max_true_peak_dbtp,omitempty"`  // e.g. -1.0
+	MaxClippedSamples *int64   `toml:"max_clipped_samples,omitempty"` // e.g. 0
+}
+
+// RuleConfig tunes a single validation rule.
+type RuleConfig struct {
+	Enabled     *bool    `toml:"enabled,omitempty"`      // Defaults to true
+	Severity    string   `toml:"severity,omitempty"`     // "error", "warn" or "info"; defaults to the rule's own severity
+	IgnorePaths []string `toml:"ignore_paths,omitempty"` // Folders or path.Match globs the rule skips, e.g. "src/legacy"
+}
+
+// Merge returns v with every setting that is set in override replaced.
+func (v ValidationConfig) Merge(override *ValidationConfig) ValidationConfig {
+	if override == nil {
+		return v
+	}
+	if override.AllowedRootFiles != nil {
+		v.AllowedRootFiles = override.AllowedRootFiles
+	}
+	if override.RequiredRootFiles != nil {
+		v.RequiredRootFiles = override.RequiredRootFiles
+	}
+	if override.ProjectExtensions != nil {
+		v.ProjectExtensions = override.ProjectExtensions
+	}
+	if override.ExportExtensions != nil {
+		v.ExportExtensions = override.ExportExtensions
+	}
+	if override.ExportStatuses != nil {
+		v.ExportStatuses = override.ExportStatuses
+	}
+	if override.FinalStatus != "" {
+		v.FinalStatus = override.FinalStatus
+	}
+	if override.ProjectFolderPattern != "" {
+		v.ProjectFolderPattern = override.ProjectFolderPattern
+	}
+	if override.AudioPolicies != nil {
+		v.AudioPolicies = override.AudioPolicies
+	}
+	if len(override.Loudness) > 0 {
+		// Targets merge per status, like rules
+		merged := make(map[string]LoudnessTarget, len(v.Loudness)+len(override.Loudness))
+		for status, t := range v.Loudness {
+			merged[status] = t
+		}
+		for status, t := range override.Loudness {
+			merged[status] = t
+		}
+		v.Loudness = merged
+	}
+	if len(override.Rules) > 0 {
+		// Rules merge per rule ID, so a repo can tweak one rule and keep the rest
+		merged := make(map[string]RuleConfig, len(v.Rules)+len(override.Rules))
+		for id, rc := range v.Rules {
+			merged[id] = rc
+		}
+		for id, rc := range override.Rules {
+			merged[id] = rc
+		}
+		v.Rules = merged
+	}
+	return v
+}
+
+// validate checks that the configured pattern compiles and that no list the
+// rules can't do without was set to an empty one.
+func (v ValidationConfig) validate() error {
+	for _, list := range []struct {
+		name   string
+		values []string
+	}{
+		{"project_extensions", v.ProjectExtensions},
+		{"export_extensions", v.ExportExtensions},
+		{"export_statuses", v.ExportStatuses},
+	} {
+		if list.values != nil && len(list.values) == 0 {
+			return fmt.Errorf("%s must not be empty; leave it out to use the defaults", list.name)
+		}
+	}
+	// Against the default statuses, this is checked once the validator has them
+	if v.ExportStatuses != nil {
+		final := v.FinalStatus
+		if final == "" {
+			final = DefaultFinalStatus
+		}
+		known := false
+		for _, status := range v.ExportStatuses {
+			known = known || status == final
+		}
+		if !known {
+			return fmt.Errorf("final_status %q is not one of export_statuses; set final_status to the status of delivered masters", final)
+		}
+	}
+	if v.ProjectFolderPattern != "" {
+		if _, err := regexp.Compile(v.ProjectFolderPattern); err != nil {
+			return fmt.Errorf("invalid project_folder_pattern: %w", err)
+		}
+	}
+	for i, policy := range v.AudioPolicies {
+		for _, format := range policy.Formats {
+			switch strings.ToLower(format) {
+			case "wav", "flac", "mp3":
+			default:
+				return fmt.Errorf("audio_policies[%d]: unsupported format %q (expected wav, flac or mp3)", i, format)
+			}
+		}
+	}
+	for status, t := range v.Loudness {
+		if t.ToleranceLU < 0 {
+			return fmt.Errorf("loudness.%s: tolerance_lu must not be negative", status)
+		}
+	}
+	for id, rc := range v.Rules {
+		switch rc.Severity {
+		case "", SeverityError, SeverityWarn, SeverityInfo:
+		default:
+			return fmt.Errorf("rule %s: invalid severity %q (expected %q, %q or %q)", id, rc.Severity, SeverityError, SeverityWarn, SeverityInfo)
+		}
+		for _, pattern := range rc.IgnorePaths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %s: invalid ignore_paths glob %q: %w", id, pattern, err)
+			}
+		}
+	}
+	return nil
 }
 
-// BackupConfig holds S3/Wasabi specific settings
+// Backup target types
+const (
+	BackupTypeS3    = "s3"
+	BackupTypeLocal = "local"
+)
+
+// Backup modes: what gets uploaded for each commit
+const (
+	BackupModeArchive = "archive" // A complete git archive tarball of the commit's tree
+	BackupModeBundle  = "bundle"  // A git bundle with the commit's history, incremental between periodic full bundles
+	BackupModeDedup   = "dedup"   // Each file stored once by blob hash, plus a small per-commit tree manifest
+)
+
+// BackupConfig holds backup target settings (S3/Wasabi or a local directory)
 type BackupConfig struct {
-	Bucket      string `toml:"s3_bucket"`
-	EndpointURL string `toml:"s3_endpoint_url"`   // Crucial for Wasabi/S3 compatible
-	Region      string `toml:"aws_region"`        // Often needed for Wasabi/S3 compatible
-	Prefix      string `toml:"s3_prefix"`         // Optional folder inside bucket
-	AccessKeyID string `toml:"aws_access_key_id"` // Optional: Use standard AWS creds chain if empty
-	SecretKey   string `toml:"aws_secret_key"`    // Optional: Use standard AWS creds chain if empty
+	Type            string `toml:"type"`                          // "s3" (default) or "local"
+	LocalPath       string `toml:"local_path,omitempty"`          // Target directory for type = "local" (NAS mount, external drive...; relative to the config file)
+	Mode            string `toml:"mode,omitempty"`                // "archive" (default), "bundle" or "dedup"
+	BundleFullEvery int    `toml:"bundle_full_every,omitempty"`   // Bundle mode: start a new chain with a full bundle after this many incrementals (default 30)
+	DedupCacheDir   string `toml:"dedup_cache_dir,omitempty"`     // Dedup mode: where the index of already-uploaded blobs is cached (relative to the config file; default "dedup-cache")
+	Verify          bool   `toml:"verify_after_upload,omitempty"` // Re-download and checksum every archive after upload
+	Bucket          string `toml:"s3_bucket"`
+	EndpointURL     string `toml:"s3_endpoint_url"`   // Crucial for Wasabi/S3 compatible
+	Region          string `toml:"aws_region"`        // Often needed for Wasabi/S3 compatible
+	Prefix          string `toml:"s3_prefix"`         // Optional folder inside bucket
+	AccessKeyID     string `toml:"aws_access_key_id"` // Optional: Use standard AWS creds chain if empty
+	SecretKey       string `toml:"aws_secret_key"`    // Optional: Use standard AWS creds chain if empty
+
+	// Client-side encryption: every object is encrypted before upload when
+	// either is set. The key file holds 32 random bytes, hex or base64
+	// encoded (e.g. `openssl rand -hex 32`); relative to the config file.
+	EncryptionKeyFile       string `toml:"encryption_key_file,omitempty"`
+	EncryptionPassphraseEnv string `toml:"encryption_passphrase_env,omitempty"` // Name of the environment variable holding a passphrase to derive the key from
+	AllowUnencrypted        bool   `toml:"allow_unencrypted,omitempty"`         // Accept objects stored before encryption was turned on, including older blobs dedup mode still reuses; otherwise they're rejected as tampered
+
+	Retention RetentionConfig `toml:"retention"` // Which commit backups `prune` keeps
+
+	// S3 uploads larger than one part are sent as resumable multipart uploads
+	PartSizeMB        int    `toml:"part_size_mb,omitempty"`       // Part size in MiB (default 16, minimum 5)
+	UploadConcurrency int    `toml:"upload_concurrency,omitempty"` // Parts uploaded at once (default 4)
+	UploadStateDir    string `toml:"upload_state_dir,omitempty"`   // Where in-progress uploads are recorded so they can resume after a restart (relative to the config file; default "uploads")
+}
+
+// RetentionConfig decides which commit backups `prune` keeps. A backup kept
+// by any rule survives, as do commits that are tagged or touch a final
+// export. With every rule at zero, nothing is pruned.
+type RetentionConfig struct {
+	KeepLast   int `toml:"keep_last,omitempty"`   // The N most recent commit backups
+	KeepDaily  int `toml:"keep_daily,omitempty"`  // The newest backup of each day, for the last D days
+	KeepWeekly int `toml:"keep_weekly,omitempty"` // The newest backup of each week, for the last W weeks
+}
+
+// Enabled reports whether any retention rule is configured.
+func (r RetentionConfig) Enabled() bool {
+	return r.KeepLast > 0 || r.KeepDaily > 0 || r.KeepWeekly > 0
 }
 
 // DefaultConfigFile returns the default path for the config file
@@ -71,15 +366,144 @@ func LoadConfig(configPath string) (*Config, error) {
 	}
 
 	// Basic validation
-	if cfg.RepoPath == "" {
-		return nil, fmt.Errorf("repository_path must be set in the config file")
+	if err := cfg.resolveRepositories(); err != nil {
+		return nil, err
+	}
+	// Check if each repository path exists and is a directory with .git inside? Maybe too strict.
+
+	if cfg.Backup.Type == "" {
+		cfg.Backup.Type = BackupTypeS3
+	}
+	switch cfg.Backup.Mode {
+	case "":
+		cfg.Backup.Mode = BackupModeArchive
+	case BackupModeArchive, BackupModeBundle, BackupModeDedup:
+	default:
+		return nil, fmt.Errorf("backup config error: unknown mode %q (expected %q, %q or %q)", cfg.Backup.Mode, BackupModeArchive, BackupModeBundle, BackupModeDedup)
+	}
+	if cfg.Backup.Mode == BackupModeBundle {
+		// Each destination has a single bundle manifest, describing one repository's history
+		byPrefix := map[string]string{}
+		for _, repo := range cfg.Repositories {
+			prefix := strings.Trim(repo.BackupPrefix, "/")
+			if other, ok := byPrefix[prefix]; ok {
+				return nil, fmt.Errorf("backup config error: repositories %s and %s back up to the same s3_prefix %q; bundle mode needs a separate prefix per repository", other, repo.Path, prefix)
+			}
+			byPrefix[prefix] = repo.Path
+		}
+	}
+	if r := cfg.Backup.Retention; r.KeepLast < 0 || r.KeepDaily < 0 || r.KeepWeekly < 0 {
+		return nil, fmt.Errorf("backup config error: retention counts can't be negative")
+	}
+	if cfg.Backup.EncryptionKeyFile != "" && cfg.Backup.EncryptionPassphraseEnv != "" {
+		return nil, fmt.Errorf("backup config error: set only one of encryption_key_file and encryption_passphrase_env")
+	}
+	if cfg.Backup.LocalPath != "" && !filepath.IsAbs(cfg.Backup.LocalPath) {
+		cfg.Backup.LocalPath = filepath.Join(filepath.Dir(configPath), cfg.Backup.LocalPath)
+	}
+	if cfg.Backup.EncryptionKeyFile != "" && !filepath.IsAbs(cfg.Backup.EncryptionKeyFile) {
+		cfg.Backup.EncryptionKeyFile = filepath.Join(filepath.Dir(configPath), cfg.Backup.EncryptionKeyFile)
+	}
+	if cfg.Backup.PartSizeMB != 0 && (cfg.Backup.PartSizeMB < 5 || cfg.Backup.PartSizeMB > 5120) {
+		return nil, fmt.Errorf("backup config error: part_size_mb must be between 5 and 5120")
+	}
+	if cfg.Backup.UploadConcurrency < 0 {
+		return nil, fmt.Errorf("backup config error: upload_concurrency can't be negative")
+	}
+	if cfg.Backup.UploadStateDir == "" {
+		cfg.Backup.UploadStateDir = "uploads"
+	}
+	if !filepath.IsAbs(cfg.Backup.UploadStateDir) {
+		cfg.Backup.UploadStateDir = filepath.Join(filepath.Dir(configPath), cfg.Backup.UploadStateDir)
+	}
+	if cfg.Backup.DedupCacheDir == "" {
+		cfg.Backup.DedupCacheDir = "dedup-cache"
+	}
+	if !filepath.IsAbs(cfg.Backup.DedupCacheDir) {
+		cfg.Backup.DedupCacheDir = filepath.Join(filepath.Dir(configPath), cfg.Backup.DedupCacheDir)
+	}
+
+	// Keep monitor state next to the config file unless told otherwise
+	if cfg.StateFile == "" {
+		cfg.StateFile = filepath.Join(filepath.Dir(configPath), "state.json")
+	}
+
+	// A relative report file lives next to the config, like the state file
+	if cfg.Reports.JSONLFile != "" && !filepath.IsAbs(cfg.Reports.JSONLFile) {
+		cfg.Reports.JSONLFile = filepath.Join(filepath.Dir(configPath), cfg.Reports.JSONLFile)
+	}
+
+	if cfg.Tagging.PatchDir == "" {
+		cfg.Tagging.PatchDir = "tag-patches"
+	}
+	if !filepath.IsAbs(cfg.Tagging.PatchDir) {
+		cfg.Tagging.PatchDir = filepath.Join(filepath.Dir(configPath), cfg.Tagging.PatchDir)
+	}
+
+	if abs, err := filepath.Abs(configPath); err == nil {
+		cfg.Path = abs
+	} else {
+		cfg.Path = configPath
 	}
-	// Check if RepoPath exists and is a directory with .git inside? Maybe too strict.
 
 	log.Printf("Configuration loaded from %s", configPath)
 	return cfg, nil
 }
 
+// resolveRepositories folds the legacy repository_path into the repositories
+// list and fills per-repo settings from the top-level defaults.
+func (cfg *Config) resolveRepositories() error {
+	if cfg.RepoPath != "" {
+		cfg.Repositories = append([]RepositoryConfig{{Path: cfg.RepoPath}}, cfg.Repositories...)
+	}
+	if len(cfg.Repositories) == 0 {
+		return fmt.Errorf("repository_path or at least one [[repositories]] entry must be set in the config file")
+	}
+
+	seen := map[string]bool{}
+	for i := range cfg.Repositories {
+		repo := &cfg.Repositories[i]
+		if repo.Path == "" {
+			return fmt.Errorf("repositories[%d]: path must be set", i)
+		}
+		cleanPath := filepath.Clean(repo.Path)
+		if seen[cleanPath] {
+			return fmt.Errorf("repository %s is configured more than once", repo.Path)
+		}
+		seen[cleanPath] = true
+
+		if repo.DebounceSecs <= 0 {
+			repo.DebounceSecs = cfg.DebounceSecs
+		}
+		if repo.BackupPrefix == "" {
+			repo.BackupPrefix = cfg.Backup.Prefix
+		}
+		if repo.Refs == nil {
+			refs := cfg.Refs
+			repo.Refs = &refs
+		}
+		if err := repo.Refs.validate(); err != nil {
+			return fmt.Errorf("repository %s: %w", repo.Path, err)
+		}
+		if err := cfg.ValidationFor(*repo).validate(); err != nil {
+			return fmt.Errorf("repository %s: %w", repo.Path, err)
+		}
+	}
+	return nil
+}
+
+// BackupFor returns the backup settings to use for a repository.
+func (cfg *Config) BackupFor(repo RepositoryConfig) BackupConfig {
+	b := cfg.Backup
+	b.Prefix = repo.BackupPrefix
+	return b
+}
+
+// ValidationFor returns the validation settings to use for a repository.
+func (cfg *Config) ValidationFor(repo RepositoryConfig) ValidationConfig {
+	return cfg.Validation.Merge(repo.Validation)
+}
+
 // initialSetup guides the user through setting up the initial configuration.
 func initialSetup(configPath string, cfg *Config) error {
 	reader := bufio.NewReader(os.Stdin)
@@ -100,34 +524,54 @@ func initialSetup(configPath string, cfg *Config) error {
 	}
 
 	// Get Backup Config
-	fmt.Print("Enter the S3/Wasabi bucket name: ")
-	cfg.Backup.Bucket, _ = reader.ReadString('\n')
-	cfg.Backup.Bucket = strings.TrimSpace(cfg.Backup.Bucket)
-
-	fmt.Print("Enter the S3/Wasabi Endpoint URL (e.g., https://s3.us-east-1.wasabisys.com or leave blank for AWS default): ")
-	cfg.Backup.EndpointURL, _ = reader.ReadString('\n')
-	cfg.Backup.EndpointURL = strings.TrimSpace(cfg.Backup.EndpointURL)
-
-	fmt.Print("Enter the AWS/Wasabi Region (e.g., us-east-1, eu-central-1): ")
-	cfg.Backup.Region, _ = reader.ReadString('\n')
-	cfg.Backup.Region = strings.TrimSpace(cfg.Backup.Region)
-
-	fmt.Print("Enter an optional S3 prefix (folder) for backups (e.g., git-backups/my-repo) or leave blank: ")
-	cfg.Backup.Prefix, _ = reader.ReadString('\n')
-	cfg.Backup.Prefix = strings.TrimSpace(cfg.Backup.Prefix)
-
-	fmt.Println("\n--- AWS/Wasabi Credentials ---")
-	fmt.Println("It's recommended to use standard AWS credential methods (environment variables like AWS_ACCESS_KEY_ID,")
-	fmt.Println("AWS_SECRET_ACCESS_KEY, or the ~/.aws/credentials file).")
-	fmt.Println("You can optionally specify keys directly in the config file (less secure).")
-
-	fmt.Print("Enter AWS/Wasabi Access Key ID (leave blank to use standard methods): ")
-	cfg.Backup.AccessKeyID, _ = reader.ReadString('\n')
-	cfg.Backup.AccessKeyID = strings.TrimSpace(cfg.Backup.AccessKeyID)
-
-	fmt.Print("Enter AWS/Wasabi Secret Key (leave blank to use standard methods): ")
-	cfg.Backup.SecretKey, _ = reader.ReadString('\n')
-	cfg.Backup.SecretKey = strings.TrimSpace(cfg.Backup.SecretKey)
+	fmt.Print("Backup target type, 's3' (S3/Wasabi) or 'local' (directory, e.g. a NAS mount) [s3]: ")
+	cfg.Backup.Type, _ = reader.ReadString('\n')
+	cfg.Backup.Type = strings.ToLower(strings.TrimSpace(cfg.Backup.Type))
+	if cfg.Backup.Type == "" {
+		cfg.Backup.Type = BackupTypeS3
+	}
+
+	if cfg.Backup.Type == BackupTypeLocal {
+		fmt.Print("Enter the directory to store backups in: ")
+		cfg.Backup.LocalPath, _ = reader.ReadString('\n')
+		cfg.Backup.LocalPath = strings.TrimSpace(cfg.Backup.LocalPath)
+		if abs, err := filepath.Abs(cfg.Backup.LocalPath); err == nil && cfg.Backup.LocalPath != "" {
+			cfg.Backup.LocalPath = abs // Typed relative to here, not to the config file
+		}
+
+		fmt.Print("Enter an optional sub-folder for backups (e.g., git-backups/my-repo) or leave blank: ")
+		cfg.Backup.Prefix, _ = reader.ReadString('\n')
+		cfg.Backup.Prefix = strings.TrimSpace(cfg.Backup.Prefix)
+	} else {
+		fmt.Print("Enter the S3/Wasabi bucket name: ")
+		cfg.Backup.Bucket, _ = reader.ReadString('\n')
+		cfg.Backup.Bucket = strings.TrimSpace(cfg.Backup.Bucket)
+
+		fmt.Print("Enter the S3/Wasabi Endpoint URL (e.g., https://s3.us-east-1.wasabisys.com or leave blank for AWS default): ")
+		cfg.Backup.EndpointURL, _ = reader.ReadString('\n')
+		cfg.Backup.EndpointURL = strings.TrimSpace(cfg.Backup.EndpointURL)
+
+		fmt.Print("Enter the AWS/Wasabi Region (e.g., us-east-1, eu-central-1): ")
+		cfg.Backup.Region, _ = reader.ReadString('\n')
+		cfg.Backup.Region = strings.TrimSpace(cfg.Backup.Region)
+
+		fmt.Print("Enter an optional S3 prefix (folder) for backups (e.g., git-backups/my-repo) or leave blank: ")
+		cfg.Backup.Prefix, _ = reader.ReadString('\n')
+		cfg.Backup.Prefix = strings.TrimSpace(cfg.Backup.Prefix)
+
+		fmt.Println("\n--- AWS/Wasabi Credentials ---")
+		fmt.Println("It's recommended to use standard AWS credential methods (environment variables like AWS_ACCESS_KEY_ID,")
+		fmt.Println("AWS_SECRET_ACCESS_KEY, or the ~/.aws/credentials file).")
+		fmt.Println("You can optionally specify keys directly in the config file (less secure).")
+
+		fmt.Print("Enter AWS/Wasabi Access Key ID (leave blank to use standard methods): ")
+		cfg.Backup.AccessKeyID, _ = reader.ReadString('\n')
+		cfg.Backup.AccessKeyID = strings.TrimSpace(cfg.Backup.AccessKeyID)
+
+		fmt.Print("Enter AWS/Wasabi Secret Key (leave blank to use standard methods): ")
+		cfg.Backup.SecretKey, _ = reader.ReadString('\n')
+		cfg.Backup.SecretKey = strings.TrimSpace(cfg.Backup.SecretKey)
+	}
 
 	// Ensure config directory exists
 	configDir := filepath.Dir(configPath)
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidationConfigValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     ValidationConfig
+		wantErr string // Empty when the config is valid
+	}{
+		{"defaults", ValidationConfig{}, ""},
+		{"custom statuses ending in finalmaster", ValidationConfig{ExportStatuses: []string{"rough", "finalmaster"}}, ""},
+		{"final status before extra statuses", ValidationConfig{ExportStatuses: []string{"rough", "finalmaster", "stems", "clean"}}, ""},
+		{"custom final status", ValidationConfig{ExportStatuses: []string{"rough", "master", "clean"}, FinalStatus: "master"}, ""},
+		{"final status left to the validator's defaults", ValidationConfig{FinalStatus: "mastered"}, ""},
+		{"empty export statuses", ValidationConfig{ExportStatuses: []string{}}, "export_statuses must not be empty"},
+		{"empty project extensions", ValidationConfig{ProjectExtensions: []string{}}, "project_extensions must not be empty"},
+		{"empty export extensions", ValidationConfig{ExportExtensions: []string{}}, "export_extensions must not be empty"},
+		{"empty root file lists are fine", ValidationConfig{AllowedRootFiles: []string{}, RequiredRootFiles: []string{}}, ""},
+		{"custom statuses without finalmaster", ValidationConfig{ExportStatuses: []string{"rough", "master"}}, `final_status "finalmaster"`},
+		{"final status not in the statuses", ValidationConfig{ExportStatuses: []string{"rough", "master"}, FinalStatus: "done"}, `final_status "done"`},
+		{"bad folder pattern", ValidationConfig{ProjectFolderPattern: "(unclosed"}, "invalid project_folder_pattern"},
+		{"bad audio format", ValidationConfig{AudioPolicies: []AudioPolicy{{Formats: []string{"wav", "ogg"}}}}, `unsupported format "ogg"`},
+		{"audio format in capitals", ValidationConfig{AudioPolicies: []AudioPolicy{{Formats: []string{"WAV"}}}}, ""},
+		{"negative tolerance", ValidationConfig{Loudness: map[string]LoudnessTarget{"mastered": {ToleranceLU: -1}}}, "loudness.mastered: tolerance_lu"},
+		{"bad severity", ValidationConfig{Rules: map[string]RuleConfig{"no-spaces": {Severity: "fatal"}}}, `rule no-spaces: invalid severity "fatal"`},
+		{"bad ignore glob", ValidationConfig{Rules: map[string]RuleConfig{"no-spaces": {IgnorePaths: []string{"src/["}}}}, "invalid ignore_paths glob"},
+		{"good rule settings", ValidationConfig{Rules: map[string]RuleConfig{"no-spaces": {Severity: SeverityWarn, IgnorePaths: []string{"src/legacy", "src/*/tmp"}}}}, ""},
+	}
+	for _, c := range cases {
+		err := c.cfg.validate()
+		switch {
+		case c.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+		case c.wantErr != "" && err == nil:
+			t.Errorf("%s: accepted, want an error containing %q", c.name, c.wantErr)
+		case c.wantErr != "" && !strings.Contains(err.Error(), c.wantErr):
+			t.Errorf("%s: got %v, want an error containing %q", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestValidationConfigMerge(t *testing.T) {
+	enabled := true
+	base := ValidationConfig{
+		ExportStatuses: []string{"rough", "finalmaster"},
+		FinalStatus:    "finalmaster",
+		Rules: map[string]RuleConfig{
+			"no-spaces":   {Severity: SeverityWarn},
+			"export-name": {Enabled: &enabled},
+		},
+	}
+	merged := base.Merge(&ValidationConfig{
+		ExportStatuses: []string{"rough", "master"},
+		FinalStatus:    "master",
+		Rules:          map[string]RuleConfig{"no-spaces": {Severity: SeverityInfo}},
+	})
+	want := ValidationConfig{
+		ExportStatuses: []string{"rough", "master"},
+		FinalStatus:    "master",
+		Rules: map[string]RuleConfig{
+			"no-spaces":   {Severity: SeverityInfo},
+			"export-name": {Enabled: &enabled},
+		},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Fatalf("got %+v, want %+v", merged, want)
+	}
+	if !reflect.DeepEqual(base.Merge(nil), base) {
+		t.Fatal("merging nothing changed the config")
+	}
+
+	// An empty override list is still an override, so validate can reject it
+	if got := base.Merge(&ValidationConfig{ExportStatuses: []string{}}); got.ExportStatuses == nil || len(got.ExportStatuses) != 0 {
+		t.Fatalf("got statuses %v, want the empty override", got.ExportStatuses)
+	}
+}
+
+func TestLoadConfigRejectsEmptyStatuses(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := "repository_path = \"" + filepath.ToSlash(dir) + "\"\n[backup]\ntype = \"local\"\nlocal_path = \"out\"\n[validation]\nexport_statuses = []\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "export_statuses must not be empty") {
+		t.Fatalf("got %v, want the empty export_statuses rejected", err)
+	}
+}
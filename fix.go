@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"git-monitor-app/config"    // Use correct module path
+	"git-monitor-app/gitutil"   // Use correct module path
+	"git-monitor-app/validator" // Use correct module path
+)
+
+// runFix implements the `fix` subcommand: check the name of every tracked
+// file, work out renames for the violations that have an obvious fix, show
+// them and apply them with `git mv`.
+func runFix(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("fix", flag.ExitOnError)
+	repoName := fs.String("repo", "", "Repository to fix (path or directory name; optional with a single repository)")
+	dryRun := fs.Bool("dry-run", false, "Only show the rename plan")
+	yes := fs.Bool("yes", false, "Apply the plan without asking for confirmation")
+	commit := fs.Bool("commit", false, "Commit the renames once applied")
+	message := fs.String("message", "Fix file naming violations", "Commit message used with -commit")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: fix [-repo name] [-dry-run] [-yes] [-commit [-message msg]]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	repo, err := selectRepository(cfg, *repoName)
+	if err != nil {
+		return err
+	}
+
+	files, err := gitutil.ListTrackedFiles(repo.Path)
+	if err != nil {
+		return err
+	}
+	validation := cfg.ValidationFor(repo)
+	result := validator.ValidateNames(files, validation)
+	plan, err := validator.PlanRenames(files, result, validation)
+	if err != nil {
+		return err
+	}
+
+	printRenamePlan(plan)
+	if len(plan.Collisions) > 0 {
+		return fmt.Errorf("refusing to rename: %d collision(s) need fixing by hand first", len(plan.Collisions))
+	}
+	if len(plan.Renames) == 0 || *dryRun {
+		return nil
+	}
+
+	// A fixup commit must only contain the renames
+	if *commit {
+		staged, err := gitutil.GetStagedFiles(repo.Path)
+		if err != nil {
+			return err
+		}
+		if len(staged) > 0 {
+			return fmt.Errorf("%d file(s) already staged; commit or unstage them before using -commit", len(staged))
+		}
+	}
+
+	if !*yes && !confirm(fmt.Sprintf("Apply %d rename(s)? [y/N] ", len(plan.Renames))) {
+		fmt.Println("Aborted; nothing renamed.")
+		return nil
+	}
+
+	for i, r := range plan.Renames {
+		if err := gitutil.MoveFile(repo.Path, r.From, r.To); err != nil {
+			return fmt.Errorf("stopped after %d of %d rename(s): %w", i, len(plan.Renames), err)
+		}
+	}
+	fmt.Printf("Renamed %d file(s).\n", len(plan.Renames))
+
+	if *commit {
+		hash, err := gitutil.Commit(repo.Path, *message)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Committed renames as %s\n", hash)
+	}
+	return nil
+}
+
+// printRenamePlan shows the plan as a diff of paths, followed by anything it
+// can't fix.
+func printRenamePlan(plan *validator.RenamePlan) {
+	if len(plan.Renames) == 0 {
+		fmt.Println("No fixable naming violations found.")
+	}
+	for _, r := range plan.Renames {
+		fmt.Printf("-%s\n+%s\n   (fixes %s)\n", r.From, r.To, strings.Join(r.Rules, ", "))
+	}
+	if len(plan.Collisions) > 0 {
+		fmt.Printf("\nCollisions (%d):\n", len(plan.Collisions))
+		for _, c := range plan.Collisions {
+			fmt.Printf("  - %s\n", c)
+		}
+	}
+	if len(plan.Unfixable) > 0 {
+		fmt.Printf("\nNot fixable automatically (%d):\n", len(plan.Unfixable))
+		for _, f := range plan.Unfixable {
+			fmt.Printf("  - %s\n", f)
+		}
+	}
+}
+
+// confirm asks a yes/no question on stdin; anything but "y" or "yes" is no.
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
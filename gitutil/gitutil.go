@@ -2,11 +2,18 @@ package gitutil
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // GetCurrentCommitHash reads the commit hash pointed to by HEAD.
@@ -62,17 +69,36 @@ func runGitRevParse(repoPath string, revision string) (string, error) {
 }
 
 // GetChangedFilesInCommit gets list of files changed in specific commit.
+// Merge commits are diffed against their first parent, so the result is the
+// set of files the merge brought into the branch it landed on.
 func GetChangedFilesInCommit(repoPath, commitHash string) ([]string, error) {
-	// Using git show is often simpler than diff-tree for a single commit
-	// Use -C repoPath to ensure git command runs in the correct directory
-	cmd := exec.Command("git", "-C", repoPath, "show", "--pretty=", "--name-status", commitHash)
+	parents, err := GetCommitParents(repoPath, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	if len(parents) > 1 {
+		// `git show` on a merge uses the combined diff format, which only lists
+		// files that differ from *every* parent. Diff against the first parent
+		// explicitly so files merged in from the other side are validated too.
+		cmd = exec.Command("git", "-C", repoPath, "diff-tree", "-r", "--no-commit-id", "--name-status", "-M", parents[0], commitHash)
+	} else {
+		// Using git show is often simpler than diff-tree for a single commit
+		// Use -C repoPath to ensure git command runs in the correct directory
+		cmd = exec.Command("git", "-C", repoPath, "show", "--pretty=", "--name-status", commitHash)
+	}
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("git show failed for commit %s: %w", commitHash, err)
 	}
+	return parseNameStatus(string(out))
+}
 
+// parseNameStatus extracts the validated paths from `--name-status` output.
+func parseNameStatus(output string) ([]string, error) {
 	files := []string{}
-	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line != "" {
@@ -106,6 +132,136 @@ func GetChangedFilesInCommit(repoPath, commitHash string) ([]string, error) {
 	return files, nil
 }
 
+// GetCommitParents returns the parent hashes of a commit (empty for a root commit).
+func GetCommitParents(repoPath, commitHash string) ([]string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "rev-list", "--parents", "-n", "1", commitHash)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-list --parents failed for commit %s: %w", commitHash, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("git rev-list returned no output for commit %s", commitHash)
+	}
+	return fields[1:], nil // First field is the commit itself
+}
+
+// GetCommitRange lists the commits reachable from toHash but not from fromHash
+// (i.e. `git rev-list fromHash..toHash`), oldest first in topological order so
+// parents are always processed before their children.
+// An empty fromHash returns just toHash.
+func GetCommitRange(repoPath, fromHash, toHash string) ([]string, error) {
+	if fromHash == "" {
+		return []string{toHash}, nil
+	}
+	return GetNewCommits(repoPath, toHash, []string{fromHash})
+}
+
+// GetNewCommits lists the commits reachable from tip but not from any of the
+// excluded commits, oldest first in topological order. Excluded hashes that no
+// longer exist (e.g. garbage-collected after a force push) are ignored.
+func GetNewCommits(repoPath, tip string, exclude []string) ([]string, error) {
+	args := []string{"-C", repoPath, "rev-list", "--topo-order", "--reverse", "--ignore-missing", tip}
+	for _, hash := range exclude {
+		if hash != "" {
+			args = append(args, "^"+hash)
+		}
+	}
+	cmd := exec.Command("git", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-list %s failed: %w", tip, err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// ListRefs returns the commit each ref under the given prefixes (e.g.
+// "refs/heads") points at, keyed by full ref name. Annotated tags are peeled to
+// their commit; refs that don't resolve to a commit are skipped.
+func ListRefs(repoPath string, prefixes ...string) (map[string]string, error) {
+	args := []string{"-C", repoPath, "for-each-ref", "--format=%(objectname) %(objecttype) %(*objectname) %(*objecttype) %(refname)"}
+	args = append(args, prefixes...)
+	cmd := exec.Command("git", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref failed: %w", err)
+	}
+
+	refs := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		// Non-tag refs leave the peeled fields empty, so split on single spaces
+		fields := strings.SplitN(scanner.Text(), " ", 5)
+		if len(fields) != 5 {
+			continue
+		}
+		hash, objType, peeledHash, peeledType, refName := fields[0], fields[1], fields[2], fields[3], fields[4]
+		if objType == "tag" {
+			hash, objType = peeledHash, peeledType
+		}
+		if objType == "commit" {
+			refs[refName] = hash
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning git for-each-ref output: %w", err)
+	}
+	return refs, nil
+}
+
+// GetHeadRef returns the branch HEAD points at (e.g. "refs/heads/main"), or
+// "" when HEAD is detached.
+func GetHeadRef(repoPath string) (string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "symbolic-ref", "-q", "HEAD")
+	out, err := cmd.Output()
+	if err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return "", nil // Exit code 1 means HEAD is not a symbolic ref
+	}
+	return "", fmt.Errorf("git symbolic-ref HEAD failed: %w", err)
+}
+
+// GetGitDir returns the absolute path of the repository's git directory:
+// <repo>/.git for a normal checkout, or the repository itself when bare.
+func GetGitDir(repoPath string) (string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "rev-parse", "--absolute-git-dir")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s is not a git repository: %w", repoPath, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsAncestor reports whether ancestorHash is reachable from commitHash.
+// Used to tell fast-forwards apart from rewritten history (rebase, reset).
+func IsAncestor(repoPath, ancestorHash, commitHash string) (bool, error) {
+	cmd := exec.Command("git", "-C", repoPath, "merge-base", "--is-ancestor", ancestorHash, commitHash)
+	err := cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return false, nil // Exit code 1 means "not an ancestor"
+	}
+	return false, fmt.Errorf("git merge-base --is-ancestor %s %s failed: %w", ancestorHash, commitHash, err)
+}
+
+// IndependentCommits reduces commits to the ones not reachable from any of
+// the others: the heads whose history covers all of them.
+func IndependentCommits(repoPath string, commits []string) ([]string, error) {
+	if len(commits) < 2 {
+		return commits, nil
+	}
+	args := append([]string{"-C", repoPath, "merge-base", "--independent"}, commits...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git merge-base --independent failed: %w", err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
 // CheckFileExists checks if a file exists and is tracked by Git.
 func CheckFileExists(repoPath, filePath string) bool {
 	// git ls-files checks the index
@@ -113,3 +269,518 @@ func CheckFileExists(repoPath, filePath string) bool {
 	err := cmd.Run() // We only care about the exit code (0 if found, non-zero if not)
 	return err == nil
 }
+
+// FileExistsInCommit checks if a path exists in the tree of a specific commit.
+func FileExistsInCommit(repoPath, commitHash, filePath string) bool {
+	cmd := exec.Command("git", "-C", repoPath, "cat-file", "-e", commitHash+":"+filePath)
+	return cmd.Run() == nil
+}
+
+// TreeEntry is one line of `git ls-tree -r` output.
+type TreeEntry struct {
+	Mode string // e.g. "100644", "100755", "120000" (symlink), "160000" (submodule)
+	Type string // "blob" or "commit" (submodule)
+	Hash string
+	Path string
+}
+
+// ListTree lists every file in a commit's tree, recursively.
+func ListTree(repoPath, commitHash string) ([]TreeEntry, error) {
+	// -z keeps paths with spaces or unusual characters unquoted
+	cmd := exec.Command("git", "-C", repoPath, "ls-tree", "-r", "-z", commitHash)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-tree failed for commit %s: %w", commitHash, err)
+	}
+
+	var entries []TreeEntry
+	for _, record := range strings.Split(string(out), "\x00") {
+		if record == "" {
+			continue
+		}
+		// Format: "<mode> SP <type> SP <hash> TAB <path>"
+		meta, path, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected git ls-tree output: %q", record)
+		}
+		entries = append(entries, TreeEntry{Mode: fields[0], Type: fields[1], Hash: fields[2], Path: path})
+	}
+	return entries, nil
+}
+
+// BlobSHA256s streams the given blobs out of the object database with
+// `git cat-file --batch` and returns the SHA-256 of each blob's content,
+// keyed by blob hash.
+func BlobSHA256s(repoPath string, blobHashes []string) (map[string]string, error) {
+	sums := make(map[string]string, len(blobHashes))
+	err := ReadBlobs(repoPath, blobHashes, func(hash string, size int64, content io.Reader) error {
+		h := sha256.New()
+		if _, err := io.Copy(h, content); err != nil {
+			return err
+		}
+		sums[hash] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	return sums, err
+}
+
+// ReadBlobs streams blobs out of the object database with `git cat-file
+// --batch`, calling fn once per blob in the order requested. fn must not keep
+// content after returning; unread content is discarded.
+func ReadBlobs(repoPath string, blobHashes []string, fn func(hash string, size int64, content io.Reader) error) error {
+	cmd := exec.Command("git", "-C", repoPath, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdin pipe for git cat-file: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdout pipe for git cat-file: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start git cat-file: %w", err)
+	}
+
+	// Feed requests in the background so a large batch can't deadlock on full pipes
+	go func() {
+		w := bufio.NewWriter(stdin)
+		for _, hash := range blobHashes {
+			fmt.Fprintln(w, hash)
+		}
+		w.Flush()
+		stdin.Close()
+	}()
+
+	reader := bufio.NewReader(stdout)
+	readErr := func() error {
+		for _, hash := range blobHashes {
+			header, err := reader.ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("failed reading git cat-file header for %s: %w", hash, err)
+			}
+			// Format: "<hash> <type> <size>" or "<hash> missing"
+			fields := strings.Fields(header)
+			if len(fields) != 3 {
+				return fmt.Errorf("object %s not found: %s", hash, strings.TrimSpace(header))
+			}
+			size, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return fmt.Errorf("bad size in git cat-file header %q: %w", header, err)
+			}
+			content := io.LimitReader(reader, size)
+			if err := fn(hash, size, content); err != nil {
+				return err
+			}
+			// Skip whatever fn didn't read, plus the trailing newline
+			if _, err := io.Copy(io.Discard, content); err != nil {
+				return err
+			}
+			if _, err := reader.Discard(1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if readErr != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return readErr
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git cat-file failed: %w", err)
+	}
+	return nil
+}
+
+// ResolveCommit turns a revision (branch, tag, abbreviated hash, HEAD~2...)
+// into a full commit hash.
+func ResolveCommit(repoPath, revision string) (string, error) {
+	return runGitRevParse(repoPath, revision+"^{commit}")
+}
+
+// ListWorkingTreeFiles lists tracked and untracked (but not ignored) files
+// that currently exist in the working tree.
+func ListWorkingTreeFiles(repoPath string) ([]string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files failed: %w", err)
+	}
+	var files []string
+	seen := map[string]bool{}
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file == "" || seen[file] {
+			continue // Unmerged files are listed once per stage
+		}
+		seen[file] = true
+		// Skip files deleted from the working tree but still in the index
+		if _, err := os.Lstat(filepath.Join(repoPath, filepath.FromSlash(file))); err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// GetStagedFiles lists the files staged in the index (`git diff --cached`),
+// using the same filtering as GetChangedFilesInCommit.
+func GetStagedFiles(repoPath string) ([]string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "diff", "--cached", "--name-status", "-M")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff --cached failed: %w", err)
+	}
+	return parseNameStatus(string(out))
+}
+
+// GetUnpushedCommits lists commits reachable from tip that aren't on any
+// remote-tracking branch, oldest first in topological order.
+func GetUnpushedCommits(repoPath, tip string) ([]string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "rev-list", "--topo-order", "--reverse", tip, "--not", "--remotes")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-list %s --not --remotes failed: %w", tip, err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// GetHooksDir returns the directory git runs hooks from, honouring core.hooksPath.
+func GetHooksDir(repoPath string) (string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "rev-parse", "--path-format=absolute", "--git-path", "hooks")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate hooks directory for %s: %w", repoPath, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ListTrackedFiles lists every file in the index (`git ls-files`).
+func ListTrackedFiles(repoPath string) ([]string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "ls-files", "-z")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files failed: %w", err)
+	}
+	var files []string
+	seen := map[string]bool{}
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file == "" || seen[file] {
+			continue // Unmerged files are listed once per stage
+		}
+		seen[file] = true
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// MoveFile renames a tracked file with `git mv`, creating the destination
+// directory first since git mv won't.
+func MoveFile(repoPath, from, to string) error {
+	if err := os.MkdirAll(filepath.Join(repoPath, filepath.Dir(filepath.FromSlash(to))), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", to, err)
+	}
+	cmd := exec.Command("git", "-C", repoPath, "mv", "--", from, to)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git mv %s %s failed: %s: %w", from, to, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// Commit records the staged changes as a new commit and returns its hash.
+// env adds "NAME=value" entries to the environment git commit runs in.
+func Commit(repoPath, message string, env ...string) (string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "commit", "-q", "-m", message)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...) // Seen by the repository's hooks
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git commit failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return ResolveCommit(repoPath, "HEAD")
+}
+
+// LastCommitForPath returns the most recent commit reachable from rev that
+// touched path (a file or directory), with its committer date. hash is empty
+// if no commit touched it.
+func LastCommitForPath(repoPath, rev, path string) (hash string, when time.Time, err error) {
+	cmd := exec.Command("git", "-C", repoPath, "log", "-1", "--format=%H %cI", rev, "--", path)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("git log for %s failed: %w", path, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return "", time.Time{}, nil
+	}
+	when, err = time.Parse(time.RFC3339, fields[1])
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unexpected commit date %q: %w", fields[1], err)
+	}
+	return fields[0], when, nil
+}
+
+// CommitTime returns a commit's committer date.
+func CommitTime(repoPath, commitHash string) (time.Time, error) {
+	cmd := exec.Command("git", "-C", repoPath, "log", "-1", "--format=%cI", commitHash, "--")
+	out, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("git log failed for commit %s: %w", commitHash, err)
+	}
+	when, err := time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected commit date %q: %w", strings.TrimSpace(string(out)), err)
+	}
+	return when, nil
+}
+
+// FileChange is one line of `git diff --name-status` output.
+type FileChange struct {
+	Status string // "A", "M", "D", "T", ...
+	Path   string
+}
+
+// DiffNameStatus lists the files that differ between two commits, limited to
+// paths. An empty to compares from against the index instead. Renames are
+// reported as a delete plus an add.
+func DiffNameStatus(repoPath, from, to string, paths ...string) ([]FileChange, error) {
+	args := []string{"-C", repoPath, "diff", "--name-status", "--no-renames", "-z"}
+	if to == "" {
+		args = append(args, "--cached", from)
+	} else {
+		args = append(args, from, to)
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s %s failed: %w", from, to, err)
+	}
+
+	// -z output alternates "<status>\0<path>\0"
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	var changes []FileChange
+	for i := 0; i+1 < len(fields); i += 2 {
+		changes = append(changes, FileChange{Status: fields[i], Path: fields[i+1]})
+	}
+	return changes, nil
+}
+
+// ListPathHistory lists every file that has ever existed under path in the
+// history of rev, including files since deleted or renamed away.
+func ListPathHistory(repoPath, rev, path string) ([]string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "log", "--format=", "--name-only", "--no-renames", "-z", rev, "--", path)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log for %s failed: %w", path, err)
+	}
+	var files []string
+	seen := map[string]bool{}
+	for _, file := range strings.Split(string(out), "\x00") {
+		file = strings.TrimLeft(file, "\n") // Each commit's list starts on a new line
+		if file != "" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// GetCommitMessage returns the full message of a commit.
+func GetCommitMessage(repoPath, commitHash string) (string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "log", "-1", "--format=%B", commitHash)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read message of commit %s: %w", commitHash, err)
+	}
+	return string(out), nil
+}
+
+// IndexBlobs returns the blob hash staged in the index for each of paths.
+// Paths that aren't in the index are left out.
+func IndexBlobs(repoPath string, paths []string) (map[string]string, error) {
+	args := append([]string{"-C", repoPath, "ls-files", "-s", "-z", "--"}, paths...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files -s failed: %w", err)
+	}
+	blobs := map[string]string{}
+	for _, record := range strings.Split(string(out), "\x00") {
+		// Format: "<mode> SP <hash> SP <stage> TAB <path>"
+		meta, path, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 {
+			continue
+		}
+		blobs[path] = fields[1]
+	}
+	return blobs, nil
+}
+
+// WriteBlob stores content in the object database and returns its hash.
+func WriteBlob(repoPath string, content []byte) (string, error) {
+	return hashObject(repoPath, content, "-w")
+}
+
+// HashBlob returns the hash content would have as a blob, without storing it.
+func HashBlob(repoPath string, content []byte) (string, error) {
+	return hashObject(repoPath, content)
+}
+
+func hashObject(repoPath string, content []byte, flags ...string) (string, error) {
+	args := append([]string{"-C", repoPath, "hash-object"}, flags...)
+	cmd := exec.Command("git", append(args, "--stdin")...)
+	cmd.Stdin = bytes.NewReader(content)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git hash-object failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ReplaceBlobs writes a tree that is commitHash's tree with the given
+// entries' blobs swapped in, and returns the tree hash. It works on a
+// temporary index, so the repository's index and working tree are untouched.
+func ReplaceBlobs(repoPath, commitHash string, entries []TreeEntry) (string, error) {
+	indexFile, err := os.CreateTemp("", "git-monitor-index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	indexFile.Close()
+	defer os.Remove(indexFile.Name())
+
+	git := func(stdin string, args ...string) (string, error) {
+		cmd := exec.Command("git", append([]string{"-C", repoPath}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+indexFile.Name())
+		cmd.Stdin = strings.NewReader(stdin)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s failed: %s: %w", args[0], strings.TrimSpace(string(out)), err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	if _, err := git("", "read-tree", commitHash); err != nil {
+		return "", err
+	}
+	// --index-info takes "<mode> SP <hash> TAB <path>" records
+	var info strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&info, "%s %s\t%s\x00", e.Mode, e.Hash, e.Path)
+	}
+	if _, err := git(info.String(), "update-index", "-z", "--index-info"); err != nil {
+		return "", err
+	}
+	return git("", "write-tree")
+}
+
+// DiffBinary returns a patch, including binary files, that turns from into
+// to. Both can be any tree-ish.
+func DiffBinary(repoPath, from, to string) ([]byte, error) {
+	cmd := exec.Command("git", "-C", repoPath, "diff", "--binary", "--full-index", from, to)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s %s failed: %w", from, to, err)
+	}
+	return out, nil
+}
+
+// ApplyPatch applies a patch to the working tree, and to the index too if
+// index is set. Nothing is changed if any part of it doesn't apply.
+func ApplyPatch(repoPath string, patch []byte, index bool) error {
+	args := []string{"-C", repoPath, "apply"}
+	if index {
+		args = append(args, "--index")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Stdin = bytes.NewReader(patch)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git apply failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// BundleRefPrefix namespaces the refs inside backup bundles. git only bundles
+// refs, so each bundled commit gets a ref refs/backups/<hash>.
+const BundleRefPrefix = "refs/backups/"
+
+// ErrEmptyBundle is returned by CreateBundle when every commit it would
+// contain is already excluded.
+var ErrEmptyBundle = errors.New("nothing to bundle")
+
+// CreateBundle writes a git bundle to file containing the history of tips,
+// minus everything reachable from exclude. The tips are exposed as
+// BundleRefPrefix refs, created in the repository only for the duration of
+// the call.
+func CreateBundle(repoPath, file string, tips, exclude []string) error {
+	for _, tip := range tips {
+		ref := BundleRefPrefix + tip
+		if out, err := exec.Command("git", "-C", repoPath, "update-ref", ref, tip).CombinedOutput(); err != nil {
+			return fmt.Errorf("git update-ref %s failed: %s: %w", ref, strings.TrimSpace(string(out)), err)
+		}
+		defer exec.Command("git", "-C", repoPath, "update-ref", "-d", ref).Run()
+	}
+
+	// Revisions go over stdin so a long exclude list can't hit argument limits
+	var revs strings.Builder
+	for _, tip := range tips {
+		fmt.Fprintln(&revs, BundleRefPrefix+tip)
+	}
+	for _, hash := range exclude {
+		fmt.Fprintln(&revs, "^"+hash)
+	}
+	cmd := exec.Command("git", "-C", repoPath, "bundle", "create", "-q", file, "--stdin")
+	cmd.Stdin = strings.NewReader(revs.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if strings.Contains(string(out), "empty bundle") {
+			return ErrEmptyBundle
+		}
+		return fmt.Errorf("git bundle create failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// VerifyBundle checks that a bundle file is intact and that the repository
+// has every commit it builds on.
+func VerifyBundle(repoPath, file string) error {
+	cmd := exec.Command("git", "-C", repoPath, "bundle", "verify", "-q", file)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git bundle verify failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// FetchBundle fetches every BundleRefPrefix ref in a bundle file into the
+// repository under the same name.
+func FetchBundle(repoPath, file string) error {
+	refspec := "+" + BundleRefPrefix + "*:" + BundleRefPrefix + "*"
+	cmd := exec.Command("git", "-C", repoPath, "fetch", "-q", file, refspec)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git fetch from bundle failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// CommitExists reports whether the repository has the commit (full hash or
+// unique prefix).
+func CommitExists(repoPath, commit string) bool {
+	return exec.Command("git", "-C", repoPath, "cat-file", "-e", commit+"^{commit}").Run() == nil
+}
+
+// InitRepo creates an empty repository in dir.
+func InitRepo(dir string) error {
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		return fmt.Errorf("git init %s failed: %s: %w", dir, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// CheckoutBranch points branch at commit and checks it out, overwriting the
+// working tree.
+func CheckoutBranch(repoPath, branch, commit string) error {
+	cmd := exec.Command("git", "-C", repoPath, "checkout", "-q", "-f", "-B", branch, commit)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git checkout %s failed: %s: %w", commit, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package hooks
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git-monitor-app/gitutil" // Use correct module path
+)
+
+// marker identifies hooks written by this tool, so they can be replaced or
+// removed without clobbering hand-written hooks.
+const marker = "# Installed by git-monitor-app"
+
+// Names of the hooks we manage.
+var Names = []string{"pre-commit", "pre-push"}
+
+// Options describes how the installed hooks should invoke the binary.
+type Options struct {
+	RepoPath   string // Repository the hooks are installed into
+	Binary     string // Absolute path of the git-monitor-app executable
+	ConfigPath string // Absolute path of the config file to pass with -config
+	Force      bool   // Overwrite hooks that weren't written by us
+}
+
+// Install writes the pre-commit and pre-push hooks and returns their paths.
+func Install(opts Options) ([]string, error) {
+	hooksDir, err := gitutil.GetHooksDir(opts.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create hooks directory %s: %w", hooksDir, err)
+	}
+
+	var written []string
+	for _, name := range Names {
+		hookPath := filepath.Join(hooksDir, name)
+		if !opts.Force {
+			if existing, err := os.ReadFile(hookPath); err == nil && !bytes.Contains(existing, []byte(marker)) {
+				return written, fmt.Errorf("%s already exists and was not installed by git-monitor-app (use -force to overwrite)", hookPath)
+			}
+		}
+		if err := os.WriteFile(hookPath, []byte(script(name, opts)), 0755); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", hookPath, err)
+		}
+		// WriteFile keeps the old mode of an existing file, so set it explicitly
+		if err := os.Chmod(hookPath, 0755); err != nil {
+			return written, fmt.Errorf("failed to make %s executable: %w", hookPath, err)
+		}
+		written = append(written, hookPath)
+	}
+	return written, nil
+}
+
+// Uninstall removes the hooks this tool installed, leaving any others alone.
+func Uninstall(repoPath string) ([]string, error) {
+	hooksDir, err := gitutil.GetHooksDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, name := range Names {
+		hookPath := filepath.Join(hooksDir, name)
+		existing, err := os.ReadFile(hookPath)
+		if err != nil || !bytes.Contains(existing, []byte(marker)) {
+			continue
+		}
+		if err := os.Remove(hookPath); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", hookPath, err)
+		}
+		removed = append(removed, hookPath)
+	}
+	return removed, nil
+}
+
+// script renders the shell script for one hook.
+func script(name string, opts Options) string {
+	invoke := fmt.Sprintf("%s -config %s validate -repo %s", shellQuote(opts.Binary), shellQuote(opts.ConfigPath), shellQuote(opts.RepoPath))
+	switch name {
+	case "pre-commit":
+		return fmt.Sprintf(`#!/bin/sh
+%s: validates staged files before they are committed.
+# Bypass once with: git commit --no-verify
+exec %s -staged
+`, marker, invoke)
+	case "pre-push":
+		// git feeds "<local ref> <local sha> <remote ref> <remote sha>" lines on stdin
+		return fmt.Sprintf(`#!/bin/sh
+%s: validates every commit about to be pushed.
+# Bypass once with: git push --no-verify
+while read local_ref local_sha remote_ref remote_sha; do
+	case "$local_sha" in
+	*[!0]*) ;;
+	*) continue ;; # Branch deletion, nothing to validate
+	esac
+	case "$remote_sha" in
+	*[!0]*) range="$remote_sha..$local_sha" ;;
+	*) range="$local_sha" ;; # New branch: commits not on any remote yet
+	esac
+	%s -range "$range" || exit 1
+done
+exit 0
+`, marker, invoke)
+	}
+	return ""
+}
+
+// shellQuote single-quotes s for /bin/sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"git-monitor-app/config" // Use correct module path
+	"git-monitor-app/hooks"  // Use correct module path
+)
+
+// runHooks implements the `hooks` subcommand: install or remove the git
+// pre-commit / pre-push hooks that run the validator before commits land.
+func runHooks(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("hooks", flag.ExitOnError)
+	repoName := fs.String("repo", "", "Repository to install hooks into (path or directory name; optional with a single repository)")
+	force := fs.Bool("force", false, "Overwrite existing hooks that weren't installed by this tool")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: hooks install [-repo name] [-force]")
+		fmt.Fprintln(fs.Output(), "       hooks uninstall [-repo name]")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing action (install or uninstall)")
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+
+	repo, err := selectRepository(cfg, *repoName)
+	if err != nil {
+		return err
+	}
+	repoPath, err := filepath.Abs(repo.Path)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "install":
+		binary, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate this executable: %w", err)
+		}
+		if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+			binary = resolved
+		}
+		written, err := hooks.Install(hooks.Options{
+			RepoPath:   repoPath,
+			Binary:     binary,
+			ConfigPath: cfg.Path,
+			Force:      *force,
+		})
+		for _, p := range written {
+			fmt.Printf("Installed %s\n", p)
+		}
+		return err
+	case "uninstall":
+		removed, err := hooks.Uninstall(repoPath)
+		for _, p := range removed {
+			fmt.Printf("Removed %s\n", p)
+		}
+		if err == nil && len(removed) == 0 {
+			fmt.Println("No git-monitor-app hooks found.")
+		}
+		return err
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown action %q (expected install or uninstall)", action)
+	}
+}
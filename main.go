@@ -2,9 +2,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"git-monitor-app/config"  // Use correct module path
@@ -14,6 +16,7 @@ import (
 func main() {
 	// Command line flag for custom config file path
 	configFile := flag.String("config", "", "Path to configuration file (default: ~/.config/git-monitor-app/config.toml)")
+	flag.Usage = usage
 	flag.Parse()
 
 	// --- Load Configuration ---
@@ -25,6 +28,62 @@ func main() {
 	// --- Setup Logging ---
 	// TODO: Implement more robust logging (e.g., to a file)
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile) // Basic logging setup
+
+	// --- Dispatch Subcommand ---
+	// No subcommand means run the monitoring daemon, as before.
+	command, args := "run", flag.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "run":
+		runDaemon(cfg)
+	case "restore":
+		err = runRestore(cfg, args)
+	case "verify":
+		err = runVerify(cfg, args)
+	case "validate":
+		err = runValidate(cfg, args)
+	case "hooks":
+		err = runHooks(cfg, args)
+	case "fix":
+		err = runFix(cfg, args)
+	case "catalog":
+		err = runCatalog(cfg, args)
+	case "tag":
+		err = runTag(cfg, args)
+	case "prune":
+		err = runPrune(cfg, args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("FATAL: %s: %v", command, err)
+	}
+}
+
+// usage prints the top-level help text.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [-config path] [command] [options]\n\n", filepath.Base(os.Args[0]))
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  run       Watch the configured repositories, validate and back up new commits (default)")
+	fmt.Fprintln(out, "  restore   List backups or extract a commit's archive into a directory")
+	fmt.Fprintln(out, "  verify    Re-download commit archives and checksum them against git")
+	fmt.Fprintln(out, "  validate  Check a commit, a whole tree or the working tree against the validation rules")
+	fmt.Fprintln(out, "  hooks     Install or remove git pre-commit/pre-push hooks that run the validator")
+	fmt.Fprintln(out, "  fix       Rename files to fix naming violations, using git mv")
+	fmt.Fprintln(out, "  catalog   Export a CSV or JSON catalog of the projects at a revision")
+	fmt.Fprintln(out, "  tag       Write title, artist, BPM and producer tags into MP3/FLAC exports")
+	fmt.Fprintln(out, "  prune     Delete commit backups the retention policy no longer keeps, and abandoned uploads")
+	fmt.Fprintln(out, "\nGlobal options:")
+	flag.PrintDefaults()
+}
+
+// runDaemon starts monitoring and blocks until SIGINT/SIGTERM.
+func runDaemon(cfg *config.Config) {
 	log.Println("--- Git Monitor App Starting ---")
 
 	// --- Setup Signal Handling for Graceful Shutdown ---
@@ -48,3 +107,21 @@ func main() {
 	<-done // Block until a signal is received and processed
 	log.Println("--- Git Monitor App Exiting ---")
 }
+
+// selectRepository picks the configured repository a command applies to.
+// name may be the repository path or its directory name; it can be omitted
+// when only one repository is configured.
+func selectRepository(cfg *config.Config, name string) (config.RepositoryConfig, error) {
+	if name == "" {
+		if len(cfg.Repositories) == 1 {
+			return cfg.Repositories[0], nil
+		}
+		return config.RepositoryConfig{}, fmt.Errorf("%d repositories are configured; choose one with -repo", len(cfg.Repositories))
+	}
+	for _, repo := range cfg.Repositories {
+		if filepath.Clean(repo.Path) == filepath.Clean(name) || filepath.Base(repo.Path) == name {
+			return repo, nil
+		}
+	}
+	return config.RepositoryConfig{}, fmt.Errorf("repository %s is not configured", name)
+}
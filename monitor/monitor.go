@@ -1,54 +1,125 @@
 package monitor
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"git-monitor-app/backup"    // Use correct module path
 	"git-monitor-app/config"    // Use correct module path
 	"git-monitor-app/gitutil"   // Use correct module path
+	"git-monitor-app/report"    // Use correct module path
+	"git-monitor-app/state"     // Use correct module path
+	"git-monitor-app/tagging"   // Use correct module path
 	"git-monitor-app/validator" // Use correct module path
 
 	"github.com/fsnotify/fsnotify"
 )
 
-var (
-	lastKnownHash string
-	repoPath      string
+// Monitor watches a single repository. Each Monitor has its own state, timer
+// and locks, so many of them can run side by side in one process.
+type Monitor struct {
+	repo       config.RepositoryConfig
+	backupCfg  config.BackupConfig
+	validation config.ValidationConfig
+	reports    config.ReportsConfig
+	tagging    config.TaggingConfig
+	store      *state.Store   // Durable record of processed commits, shared between monitors
+	logger     *log.Logger    // Prefixes every message with the repository name
+	backend    backup.Backend // Connected on first use by backupBackend, then reused
+
+	knownRefs     map[string]string // Last processed commit per followed ref ("HEAD" when detached)
 	debounceTimer *time.Timer
 	debounceMu    sync.Mutex // Protect timer access
 	processingMu  sync.Mutex // Prevent concurrent processing of commits
-	appConfig     *config.Config
-)
+}
 
-// Start initializes and runs the file system watcher.
+// New creates a Monitor for one configured repository.
+func New(repo config.RepositoryConfig, cfg *config.Config, store *state.Store) *Monitor {
+	return &Monitor{
+		repo:       repo,
+		backupCfg:  cfg.BackupFor(repo),
+		validation: cfg.ValidationFor(repo),
+		reports:    cfg.Reports,
+		tagging:    cfg.Tagging,
+		store:      store,
+		logger:     log.New(log.Writer(), "["+filepath.Base(repo.Path)+"] ", log.Flags()|log.Lmsgprefix),
+	}
+}
+
+// Start opens the shared state store and runs one Monitor per configured
+// repository, blocking until all of them have stopped.
 func Start(cfg *config.Config) {
-	appConfig = cfg // Store config for access in callbacks
-	repoPath = cfg.RepoPath
-	gitDir := filepath.Join(repoPath, ".git")
-	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		log.Fatalf("Monitor Error: '.git' directory not found in %s", repoPath)
-		return
+	store, err := state.Open(cfg.StateFile)
+	if err != nil {
+		log.Fatalf("Monitor Error: Failed to open state file: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, repo := range cfg.Repositories {
+		m := New(repo, cfg, store)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Run(); err != nil {
+				m.logger.Printf("Monitor Error: Stopped monitoring %s: %v", repo.Path, err)
+			}
+		}()
 	}
+	log.Printf("Monitor: Supervising %d repository monitor(s).", len(cfg.Repositories))
+	wg.Wait()
+	log.Println("Monitor: All repository monitors have stopped.")
+}
 
-	var err error
-	lastKnownHash, err = gitutil.GetCurrentCommitHash(repoPath)
+// Run initializes and runs the file system watcher for this repository.
+// It blocks until the watcher shuts down or fails to start.
+func (m *Monitor) Run() error {
+	// Works for both normal checkouts (<repo>/.git) and bare repositories
+	gitDir, err := gitutil.GetGitDir(m.repo.Path)
 	if err != nil {
-		log.Printf("Monitor Warning: Could not get initial commit hash for %s: %v. Will process the first detected commit.", repoPath, err)
-		lastKnownHash = "" // Start fresh
+		return err
+	}
+
+	// Resume from the refs we processed (possibly in a previous run) so
+	// commits made while the daemon was down are caught up on below.
+	m.knownRefs = m.store.Refs(m.repo.Path)
+	if m.knownRefs != nil {
+		m.logger.Printf("Monitor: Resuming %d ref(s) from state: %s", len(m.knownRefs), m.store.Path())
+	} else {
+		// First run for this repo: seed from the current refs rather than processing all of history
+		m.knownRefs, err = m.snapshotRefs()
+		if err != nil {
+			return fmt.Errorf("failed to read initial refs: %w", err)
+		}
+		// State written before refs were tracked only knows the HEAD commit;
+		// resume the current branch from there so nothing is skipped.
+		if last := m.store.LastProcessedCommit(m.repo.Path); last != "" {
+			headRef, err := gitutil.GetHeadRef(m.repo.Path)
+			if err != nil || headRef == "" {
+				headRef = "HEAD"
+			}
+			m.logger.Printf("Monitor: Resuming %s from last processed commit %s", headRef, last)
+			m.knownRefs[headRef] = last
+		}
+		if err := m.store.SetRefs(m.repo.Path, m.knownRefs); err != nil {
+			m.logger.Printf("Monitor Warning: Failed to save initial state: %v", err)
+		}
 	}
-	log.Printf("Monitor: Starting monitoring for repo: %s", repoPath)
-	if lastKnownHash != "" {
-		log.Printf("Monitor: Initial commit hash: %s", lastKnownHash)
+	m.logger.Printf("Monitor: Starting monitoring for repo: %s", m.repo.Path)
+	for _, ref := range sortedKeys(m.knownRefs) {
+		m.logger.Printf("Monitor: Initial commit for %s: %s", ref, m.knownRefs[ref])
 	}
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Fatal("Monitor Error: Failed to create watcher:", err)
+		return fmt.Errorf("failed to create watcher: %w", err)
 	}
 	defer watcher.Close() // Ensure watcher is closed on exit
 
@@ -57,7 +128,7 @@ func Start(cfg *config.Config) {
 	// Watching directories might generate more events but is often more robust.
 	// Key directories/files involved in commits:
 	pathsToWatch := []string{
-		gitDir,                        // Watch base .git dir for changes to HEAD, index etc.
+		gitDir,                        // Watch base git dir for changes to HEAD, packed-refs, index etc.
 		filepath.Join(gitDir, "refs"), // Watch for ref changes (branches, tags)
 		// filepath.Join(gitDir, "logs"), // Watch logs for refs like HEAD - might be noisy
 	}
@@ -66,65 +137,82 @@ func Start(cfg *config.Config) {
 	for _, p := range pathsToWatch {
 		if _, err := os.Stat(p); err == nil {
 			// Watch directory recursively - fsnotify might need manual recursion depending on platform/usage
-			log.Printf("Monitor: Adding watch on: %s", p)
-			err = addRecursiveWatch(watcher, p) // Use helper for recursion
+			m.logger.Printf("Monitor: Adding watch on: %s", p)
+			err = m.addRecursiveWatch(watcher, p) // Use helper for recursion
 			if err != nil {
-				log.Printf("Monitor Error: Failed to add watch on %s: %v", p, err)
+				m.logger.Printf("Monitor Error: Failed to add watch on %s: %v", p, err)
 				watchErrors++
 			}
 		} else {
-			log.Printf("Monitor Warning: Path %s does not exist, skipping watch.", p)
+			m.logger.Printf("Monitor Warning: Path %s does not exist, skipping watch.", p)
 		}
 	}
 
 	if watchErrors > 0 {
-		log.Printf("Monitor Warning: %d errors occurred adding watches. Monitoring might be incomplete.", watchErrors)
+		m.logger.Printf("Monitor Warning: %d errors occurred adding watches. Monitoring might be incomplete.", watchErrors)
 		// Decide if this is fatal - for now, continue if some watches were added.
 		// if len(watcher.WatchList()) == 0 { log.Fatal("Monitor Error: Failed to add any watches.")}
 	}
 
-	log.Println("Monitor: Watcher started. Waiting for Git activity...")
+	m.logger.Println("Monitor: Watcher started. Waiting for Git activity...")
+
+	// Retry backups that failed last time, then catch up on anything committed
+	// while the daemon wasn't running. Watches are already in place, so
+	// nothing committed from here on is missed.
+	go func() {
+		m.retryFailedBackups()
+		m.handleCommitCheck()
+	}()
 
 	// --- Event Loop ---
 	for {
 		select {
 		case event, ok := <-watcher.Events:
 			if !ok {
-				log.Println("Monitor: Watcher events channel closed.")
-				return // Channel closed
+				m.logger.Println("Monitor: Watcher events channel closed.")
+				return nil // Channel closed
 			}
 			// Log event details for debugging if needed
-			// log.Printf("Monitor Event: Op=%s, Name=%s", event.Op, event.Name)
+			// m.logger.Printf("Monitor Event: Op=%s, Name=%s", event.Op, event.Name)
 
 			// Filter events - React mainly to writes/creates/renames
 			// Note: Rename/Chmod might also indicate commit finished. Write is common.
+			if event.Has(fsnotify.Create) {
+				// New branch namespaces (e.g. refs/heads/feature/) create directories
+				// that need their own watch, or commits on them would go unnoticed.
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := m.addRecursiveWatch(watcher, event.Name); err != nil {
+						m.logger.Printf("Monitor Warning: Failed to watch new directory %s: %v", event.Name, err)
+					}
+				}
+			}
 			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
 				// Debounce: Reset timer on relevant events
-				debounceMu.Lock()
-				if debounceTimer != nil {
-					debounceTimer.Stop()
+				m.debounceMu.Lock()
+				if m.debounceTimer != nil {
+					m.debounceTimer.Stop()
 				}
-				debounceDuration := time.Duration(appConfig.DebounceSecs) * time.Second
-				debounceTimer = time.AfterFunc(debounceDuration, handleCommitCheck)
-				debounceMu.Unlock()
+				debounceDuration := time.Duration(m.repo.DebounceSecs) * time.Second
+				m.debounceTimer = time.AfterFunc(debounceDuration, m.handleCommitCheck)
+				m.debounceMu.Unlock()
 			}
 
 		case err, ok := <-watcher.Errors:
 			if !ok {
-				log.Println("Monitor: Watcher errors channel closed.")
-				return // Channel closed
+				m.logger.Println("Monitor: Watcher errors channel closed.")
+				return nil // Channel closed
 			}
-			log.Println("Monitor Error: Watcher error:", err)
+			m.logger.Println("Monitor Error: Watcher error:", err)
 		}
 	}
 }
 
 // addRecursiveWatch adds watches to a directory and all its subdirectories.
-func addRecursiveWatch(watcher *fsnotify.Watcher, rootPath string) error {
+func (m *Monitor) addRecursiveWatch(watcher *fsnotify.Watcher, rootPath string) error {
 	err := filepath.WalkDir(rootPath, func(path string, d os.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			// Report error but continue walking other paths if possible
-			log.Printf("Monitor Warning: Error accessing path %q: %v", path, walkErr)
+			m.logger.Printf("Monitor Warning: Error accessing path %q: %v", path, walkErr)
 			return nil // Continue walking if possible, or return walkErr to stop
 		}
 		if d.IsDir() {
@@ -132,15 +220,15 @@ func addRecursiveWatch(watcher *fsnotify.Watcher, rootPath string) error {
 			// Add other ignores if necessary (e.g., hooks)
 			base := filepath.Base(path)
 			if base == "objects" || base == "hooks" {
-				// log.Printf("Monitor: Skipping watch on subdir: %s", path)
+				// m.logger.Printf("Monitor: Skipping watch on subdir: %s", path)
 				return filepath.SkipDir // Don't descend into this directory
 			}
 
-			// log.Printf("Monitor: Adding recursive watch on dir: %s", path)
+			// m.logger.Printf("Monitor: Adding recursive watch on dir: %s", path)
 			err := watcher.Add(path)
 			if err != nil {
 				// Log error but continue trying to add other watches
-				log.Printf("Monitor Error: Failed to add watch on directory %s: %v", path, err)
+				m.logger.Printf("Monitor Error: Failed to add watch on directory %s: %v", path, err)
 			}
 		}
 		return nil // Continue walking
@@ -151,72 +239,405 @@ func addRecursiveWatch(watcher *fsnotify.Watcher, rootPath string) error {
 	}
 	// Also add watch to the root path itself
 	if err := watcher.Add(rootPath); err != nil {
-		log.Printf("Monitor Error: Failed to add watch on root path %s: %v", rootPath, err)
+		m.logger.Printf("Monitor Error: Failed to add watch on root path %s: %v", rootPath, err)
 		return err
 	}
 	return nil
 }
 
+// CommitResult records the outcome of processing a single commit.
+type CommitResult struct {
+	Hash            string
+	Ref             string // Ref the commit was found on
+	IsMerge         bool
+	Valid           bool
+	Validation      validator.Result
+	BackupAttempted bool
+	BackupErr       error
+	Err             error // Set if the commit could not be processed at all
+}
+
+// record converts the result into its persisted form.
+func (r CommitResult) record() state.CommitRecord {
+	rec := state.CommitRecord{
+		Hash:             r.Hash,
+		Ref:              r.Ref,
+		Valid:            r.Valid,
+		ValidationErrors: findingStrings(r.Validation.Errors()),
+		Warnings:         findingStrings(r.Validation.Warnings()),
+		BackupAttempted:  r.BackupAttempted,
+		BackupOK:         r.BackupAttempted && r.BackupErr == nil,
+	}
+	if r.BackupErr != nil {
+		rec.BackupError = r.BackupErr.Error()
+	}
+	return rec
+}
+
+// snapshotRefs reads the current tip of every followed ref. Branches are
+// filtered by the include/exclude globs; a detached HEAD is tracked as "HEAD".
+func (m *Monitor) snapshotRefs() (map[string]string, error) {
+	prefixes := []string{"refs/heads"}
+	if m.repo.Refs.IncludeTags {
+		prefixes = append(prefixes, "refs/tags")
+	}
+	refs, err := gitutil.ListRefs(m.repo.Path, prefixes...)
+	if err != nil {
+		return nil, err
+	}
+	for ref := range refs {
+		if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok && !m.repo.Refs.FollowsBranch(branch) {
+			delete(refs, ref)
+		}
+	}
+
+	headRef, err := gitutil.GetHeadRef(m.repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	if headRef == "" {
+		// Detached HEAD: commits made here aren't on any branch yet
+		headHash, err := gitutil.GetCurrentCommitHash(m.repo.Path)
+		if err != nil {
+			return nil, err
+		}
+		refs["HEAD"] = headHash
+	}
+	return refs, nil
+}
+
 // handleCommitCheck is called after the debounce timer fires.
-// It checks if a new commit has occurred and triggers validation/backup.
-func handleCommitCheck() {
+// It snapshots the followed refs, diffs them against the last processed
+// snapshot, and triggers validation/backup for every new commit on every
+// changed ref, not just the tip of HEAD.
+func (m *Monitor) handleCommitCheck() {
 	// Ensure only one check runs at a time
-	if !processingMu.TryLock() {
-		log.Println("Monitor: Commit check already in progress, skipping.")
+	if !m.processingMu.TryLock() {
+		m.logger.Println("Monitor: Commit check already in progress, skipping.")
 		return
 	}
-	defer processingMu.Unlock()
+	defer m.processingMu.Unlock()
 
-	log.Println("Monitor: Debounce triggered, checking for new commit...")
-	currentHash, err := gitutil.GetCurrentCommitHash(repoPath)
+	m.logger.Println("Monitor: Debounce triggered, checking for new commits...")
+	snapshot, err := m.snapshotRefs()
 	if err != nil {
-		log.Printf("Monitor Error: Could not get current commit hash during check: %v", err)
+		m.logger.Printf("Monitor Error: Could not read refs during check: %v", err)
 		return
 	}
 
-	if currentHash != "" && currentHash != lastKnownHash {
-		log.Printf("Monitor: New commit detected! Previous: %s, Current: %s", lastKnownHash, currentHash)
-		commitHashToProcess := currentHash // Capture the hash we are processing
+	// Commits reachable from anything we've already processed are never
+	// processed again, so a branch fast-forwarded onto another (or a merge of
+	// an already-seen branch) only yields the genuinely new commits.
+	processedTips := make([]string, 0, len(m.knownRefs)+len(snapshot))
+	for _, hash := range m.knownRefs {
+		processedTips = append(processedTips, hash)
+	}
 
-		// Update state *before* processing to prevent reprocessing if errors occur mid-way
-		originalLastHash := lastKnownHash
-		lastKnownHash = currentHash
+	changed := false
+	var results []CommitResult
+	for _, ref := range sortedKeys(snapshot) {
+		tip, previous := snapshot[ref], m.knownRefs[ref]
+		if tip == previous {
+			continue
+		}
+		changed = true
 
-		// Get changed files for the *new* commit
-		changedFiles, err := gitutil.GetChangedFilesInCommit(repoPath, commitHashToProcess)
+		commits, err := gitutil.GetNewCommits(m.repo.Path, tip, processedTips)
 		if err != nil {
-			log.Printf("Monitor Error: Failed getting changed files for commit %s: %v. Skipping processing.", commitHashToProcess, err)
-			lastKnownHash = originalLastHash // Revert state if we couldn't get files
-			return
+			m.logger.Printf("Monitor Error: Could not list new commits on %s: %v. Will retry on next check.", ref, err)
+			continue
+		}
+		if len(commits) == 0 {
+			// Ref moved backwards (e.g. `git reset`) or to a commit processed via another ref
+			m.logger.Printf("Monitor: %s moved to already-processed commit %s, nothing to do.", ref, tip)
+			m.knownRefs[ref] = tip
+			processedTips = append(processedTips, tip)
+			continue
 		}
 
-		// Validate the changes
-		log.Printf("Monitor: Starting validation for commit %s...", commitHashToProcess)
-		isValid, validationErrors := validator.Validate(repoPath, changedFiles)
-
-		if isValid {
-			log.Printf("Monitor: Commit %s PASSED validation.", commitHashToProcess)
-			log.Printf("Monitor: Starting backup for commit %s...", commitHashToProcess)
+		if previous == "" {
+			m.logger.Printf("Monitor: New ref %s with %d unprocessed commit(s), tip %s", ref, len(commits), tip)
+		} else {
+			m.logger.Printf("Monitor: New commit(s) on %s! Previous: %s, Current: %s (%d to process)", ref, previous, tip, len(commits))
+			if isFastForward, err := gitutil.IsAncestor(m.repo.Path, previous, tip); err == nil && !isFastForward {
+				m.logger.Printf("Monitor Warning: %s was rewritten (%s is no longer an ancestor). Processing only commits not seen before.", ref, previous)
+			}
+		}
 
-			err := backup.RunBackup(repoPath, commitHashToProcess, &appConfig.Backup)
-			if err != nil {
-				log.Printf("Monitor Error: Backup FAILED for commit %s: %v", commitHashToProcess, err)
-				// Should we revert lastKnownHash here? Maybe not, commit is valid but backup failed.
-				// Maybe add retry logic? For now, just log.
-			} else {
-				log.Printf("Monitor: Backup SUCCEEDED for commit %s.", commitHashToProcess)
+		completed := true
+		for _, hash := range commits {
+			result := m.processCommit(ref, hash)
+			results = append(results, result)
+			if result.Err != nil {
+				// Stop here so the failed commit (and everything after it) is retried on the next check
+				m.logger.Printf("Monitor Error: Stopping processing of %s at commit %s; will retry on next check.", ref, hash)
+				completed = false
+				break
 			}
-		} else {
-			log.Printf("Monitor: Commit %s FAILED validation:", commitHashToProcess)
-			for _, verr := range validationErrors {
-				log.Printf("  - %s", verr)
+			m.knownRefs[ref] = hash
+			processedTips = append(processedTips, hash)
+			if err := m.store.RecordCommit(m.repo.Path, result.record()); err != nil {
+				m.logger.Printf("Monitor Warning: Failed to save state after commit %s: %v", hash, err)
 			}
-			log.Printf("Monitor: Backup SKIPPED for invalid commit %s.", commitHashToProcess)
-			// TODO: Optional - Send system notification
 		}
-	} else if currentHash == lastKnownHash {
-		log.Println("Monitor: No new commit detected since last check.")
+		if completed {
+			m.knownRefs[ref] = tip
+			processedTips = append(processedTips, tip)
+		}
+	}
+
+	for ref := range m.knownRefs {
+		if _, ok := snapshot[ref]; !ok {
+			m.logger.Printf("Monitor: %s no longer exists (or is no longer followed), forgetting it.", ref)
+			delete(m.knownRefs, ref)
+			changed = true
+		}
+	}
+
+	if !changed {
+		m.logger.Println("Monitor: No new commit detected since last check.")
+		return
+	}
+	if err := m.store.SetRefs(m.repo.Path, m.knownRefs); err != nil {
+		m.logger.Printf("Monitor Warning: Failed to save state: %v", err)
+	}
+	m.logRangeSummary(results)
+}
+
+// processCommit validates a single commit and backs it up if it passes.
+func (m *Monitor) processCommit(ref, commitHash string) CommitResult {
+	result := CommitResult{Hash: commitHash, Ref: ref}
+
+	parents, err := gitutil.GetCommitParents(m.repo.Path, commitHash)
+	if err != nil {
+		m.logger.Printf("Monitor Error: Failed reading parents of commit %s: %v", commitHash, err)
+		result.Err = err
+		return result
+	}
+	result.IsMerge = len(parents) > 1
+	if result.IsMerge {
+		m.logger.Printf("Monitor: Commit %s is a merge of %d parents; validating changes relative to first parent %s.", commitHash, len(parents), parents[0])
+	}
+
+	// Get changed files for this commit
+	changedFiles, err := gitutil.GetChangedFilesInCommit(m.repo.Path, commitHash)
+	if err != nil {
+		m.logger.Printf("Monitor Error: Failed getting changed files for commit %s: %v. Skipping processing.", commitHash, err)
+		result.Err = err
+		return result
+	}
+
+	// Validate the changes
+	m.logger.Printf("Monitor: Starting validation for commit %s...", commitHash)
+	result.Validation = validator.Validate(m.repo.Path, commitHash, changedFiles, m.validation)
+	result.Valid = result.Validation.Valid()
+	m.writeReports(commitHash, result.Validation)
+
+	if result.Valid {
+		m.logger.Printf("Monitor: Commit %s PASSED validation.", commitHash)
+		for _, f := range result.Validation.Findings { // Warnings and notes only
+			m.logger.Printf("  - %s", f)
+		}
+		if m.tagging.AfterValidation {
+			m.writeTagPatch(commitHash)
+		}
+		m.logger.Printf("Monitor: Starting backup for commit %s...", commitHash)
+
+		result.BackupAttempted = true
+		result.BackupErr = m.runBackup(commitHash)
+		if result.BackupErr != nil {
+			m.logger.Printf("Monitor Error: Backup FAILED for commit %s: %v", commitHash, result.BackupErr)
+			// Commit is valid but backup failed; retried when the daemon next starts
+		} else {
+			m.logger.Printf("Monitor: Backup SUCCEEDED for commit %s.", commitHash)
+		}
 	} else {
-		log.Printf("Monitor: Current commit hash is empty, skipping check (perhaps repo initializing?).")
+		m.logger.Printf("Monitor: Commit %s FAILED validation:", commitHash)
+		for _, f := range result.Validation.Findings {
+			m.logger.Printf("  - %s", f)
+		}
+		m.logger.Printf("Monitor: Backup SKIPPED for invalid commit %s.", commitHash)
+		// TODO: Optional - Send system notification
+	}
+	return result
+}
+
+// retryFailedBackups backs up again every valid commit whose last backup
+// attempt failed, oldest first. An interrupted multipart upload picks up from
+// the parts it already sent.
+func (m *Monitor) retryFailedBackups() {
+	m.processingMu.Lock()
+	defer m.processingMu.Unlock()
+
+	rs, ok := m.store.Repo(m.repo.Path)
+	if !ok {
+		return
+	}
+	// Only the latest record for each commit counts
+	latest := map[string]state.CommitRecord{}
+	var order []string
+	for _, rec := range rs.History {
+		if _, seen := latest[rec.Hash]; !seen {
+			order = append(order, rec.Hash)
+		}
+		latest[rec.Hash] = rec
+	}
+	for _, hash := range order {
+		rec := latest[hash]
+		if !rec.Valid || !rec.BackupAttempted || rec.BackupOK {
+			continue
+		}
+		if !gitutil.CommitExists(m.repo.Path, hash) {
+			m.logger.Printf("Monitor Warning: Not retrying backup of commit %s: it's no longer in the repository.", hash)
+			continue
+		}
+		m.logger.Printf("Monitor: Retrying failed backup for commit %s...", hash)
+		err := m.runBackup(hash)
+		if err != nil {
+			m.logger.Printf("Monitor Error: Backup FAILED again for commit %s: %v", hash, err)
+		} else {
+			m.logger.Printf("Monitor: Backup SUCCEEDED for commit %s.", hash)
+		}
+		if err := m.store.SetBackupResult(m.repo.Path, hash, err); err != nil {
+			m.logger.Printf("Monitor Warning: Failed to save state after retrying commit %s: %v", hash, err)
+		}
+	}
+}
+
+// runBackup backs up a commit to the configured destination. Callers hold
+// processingMu.
+func (m *Monitor) runBackup(commitHash string) error {
+	backend, err := m.backupBackend()
+	if err != nil {
+		return err
+	}
+	return backup.RunBackup(backend, m.repo.Path, commitHash, &m.backupCfg)
+}
+
+// backupBackend returns the backup destination, connecting to it (and, for
+// an encrypted one, loading its key) only the first time it's needed. A
+// failure isn't kept, so the next commit tries again. Callers hold
+// processingMu.
+func (m *Monitor) backupBackend() (backup.Backend, error) {
+	if m.backend == nil {
+		backend, err := backup.NewBackend(context.TODO(), &m.backupCfg)
+		if err != nil {
+			return nil, err
+		}
+		m.backend = backend
+	}
+	return m.backend, nil
+}
+
+// writeTagPatch leaves a patch in [tagging] patch_dir that brings the
+// commit's MP3/FLAC tags in line with their project folders. Like reports,
+// failures are logged but never block the backup.
+func (m *Monitor) writeTagPatch(commitHash string) {
+	plan, err := tagging.Build(m.repo.Path, commitHash)
+	if err != nil {
+		m.logger.Printf("Monitor Warning: Failed to check export tags for commit %s: %v", commitHash, err)
+		return
+	}
+	for _, skipped := range plan.Skipped {
+		m.logger.Printf("Monitor Warning: Can't tag %s", skipped)
+	}
+	if len(plan.Changes) == 0 {
+		return
+	}
+	patch, err := plan.Patch(m.repo.Path)
+	if err != nil {
+		m.logger.Printf("Monitor Warning: Failed to build tag patch for commit %s: %v", commitHash, err)
+		return
+	}
+	if err := os.MkdirAll(m.tagging.PatchDir, 0755); err != nil {
+		m.logger.Printf("Monitor Warning: Failed to create tag patch directory: %v", err)
+		return
+	}
+	name := filepath.Join(m.tagging.PatchDir, fmt.Sprintf("%s-%s.tags.patch", filepath.Base(m.repo.Path), commitHash))
+	if err := os.WriteFile(name, patch, 0644); err != nil {
+		m.logger.Printf("Monitor Warning: Failed to write tag patch for commit %s: %v", commitHash, err)
+		return
+	}
+	m.logger.Printf("Monitor: %d export(s) in commit %s need new tags; wrote %s for review.", len(plan.Changes), commitHash, name)
+}
+
+// writeReports persists the machine-readable validation reports configured
+// in [reports]. Failures are logged but never block the backup.
+func (m *Monitor) writeReports(commitHash string, result validator.Result) {
+	findings := report.CommitFindings{Repo: m.repo.Path, Commit: commitHash, Findings: result.Findings}
+
+	if m.reports.JSONLFile != "" {
+		if err := report.AppendJSONLines(m.reports.JSONLFile, findings); err != nil {
+			m.logger.Printf("Monitor Warning: Failed to append report for commit %s: %v", commitHash, err)
+		}
+	}
+
+	if !m.reports.UploadEnabled() {
+		return
+	}
+	backend, err := m.backupBackend()
+	if err != nil {
+		m.logger.Printf("Monitor Warning: Failed to upload reports for commit %s: %v", commitHash, err)
+		return
+	}
+	// Uploaded for every commit, so the destination also explains why an
+	// invalid commit has no archive.
+	for _, format := range []struct{ name, ext string }{
+		{report.FormatJSONL, ".jsonl"},
+		{report.FormatSARIF, ".sarif.json"},
+	} {
+		var buf bytes.Buffer
+		if err := report.Write(&buf, format.name, findings); err != nil {
+			m.logger.Printf("Monitor Warning: Failed to render %s report for commit %s: %v", format.name, commitHash, err)
+			continue
+		}
+		if err := backup.PutReport(backend, &m.backupCfg, commitHash, format.ext, buf.Bytes()); err != nil {
+			m.logger.Printf("Monitor Warning: Failed to upload %s report for commit %s: %v", format.name, commitHash, err)
+		}
+	}
+}
+
+// logRangeSummary prints a one-line-per-commit summary when a check covered
+// more than one commit.
+func (m *Monitor) logRangeSummary(results []CommitResult) {
+	if len(results) < 2 {
+		return
+	}
+	m.logger.Printf("Monitor: Summary for %d processed commit(s):", len(results))
+	for _, r := range results {
+		status := "valid"
+		switch {
+		case r.Err != nil:
+			status = fmt.Sprintf("error: %v", r.Err)
+		case !r.Valid:
+			status = fmt.Sprintf("invalid (%d problem(s)), backup skipped", len(r.Validation.Errors()))
+		case r.BackupErr != nil:
+			status = "valid, backup FAILED"
+		case r.BackupAttempted:
+			status = "valid, backed up"
+		}
+		if r.IsMerge {
+			status += " [merge]"
+		}
+		m.logger.Printf("  %s (%s): %s", r.Hash, r.Ref, status)
+	}
+}
+
+// findingStrings renders findings for the state file.
+func findingStrings(findings []validator.Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.String())
+	}
+	return out
+}
+
+// sortedKeys returns the keys of a ref map in a stable order.
+func sortedKeys(refs map[string]string) []string {
+	keys := make([]string, 0, len(refs))
+	for k := range refs {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"git-monitor-app/backup"    // Use correct module path
+	"git-monitor-app/config"    // Use correct module path
+	"git-monitor-app/validator" // Use correct module path
+)
+
+// runPrune implements the `prune` subcommand: abort abandoned multipart
+// uploads, then apply the retention policy in [backup.retention] and delete
+// the commit backups it doesn't keep.
+func runPrune(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	repoName := fs.String("repo", "", "Repository whose backups to prune (path or directory name; optional with a single repository)")
+	dryRun := fs.Bool("dry-run", false, "Only list what would be deleted")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: prune [-repo name] [-dry-run]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	repo, err := selectRepository(cfg, *repoName)
+	if err != nil {
+		return err
+	}
+	backupCfg := cfg.BackupFor(repo)
+
+	ctx := context.Background()
+	backend, err := backup.NewBackend(ctx, &backupCfg)
+	if err != nil {
+		return err
+	}
+
+	// Incomplete multipart uploads are billed like any other storage
+	aborted, err := backup.CleanupUploads(ctx, backend, backup.ObjectKey(&backupCfg, ""), *dryRun)
+	for _, a := range aborted {
+		if *dryRun {
+			fmt.Printf("Would abort incomplete upload %s\n", a)
+		} else {
+			fmt.Printf("Aborted incomplete upload %s\n", a)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !backupCfg.Retention.Enabled() {
+		fmt.Println("No retention policy configured (keep_last, keep_daily or keep_weekly under [backup.retention]); no backups pruned.")
+		return nil
+	}
+	if len(aborted) > 0 {
+		fmt.Println()
+	}
+	finalStatus, err := validator.FinalStatus(cfg.ValidationFor(repo))
+	if err != nil {
+		return err
+	}
+	plan, err := backup.PlanRetention(ctx, backend, &backupCfg, repo.Path, finalStatus, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		fmt.Printf("No commit backups found at %s\n", backend.Location(backup.ObjectKey(&backupCfg, "")))
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "COMMIT\tDATE\tACTION\tREASON")
+		for _, item := range plan {
+			action := "delete"
+			if item.Keep {
+				action = "keep"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.Commit, item.Time.Local().Format("2006-01-02 15:04"), action, strings.Join(item.Reasons, ", "))
+		}
+		w.Flush()
+	}
+
+	result, err := backup.Prune(ctx, backend, &backupCfg, plan, *dryRun)
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	if result != nil {
+		fmt.Printf("\n%s %d commit backup(s) (%d object(s)) and %d unreferenced blob(s) (%d bytes).\n", verb, result.Commits, len(result.Deleted), result.Blobs, result.BlobBytes)
+	}
+	if err != nil {
+		return err
+	}
+	if manifest, err := backup.GetBundleManifest(ctx, backend, &backupCfg); err == nil && len(manifest.Bundles) > 0 {
+		fmt.Printf("%d bundle(s) left as they are: bundles aren't pruned.\n", len(manifest.Bundles))
+	}
+	return nil
+}
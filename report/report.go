@@ -0,0 +1,244 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"git-monitor-app/validator" // Use correct module path
+)
+
+// Formats understood by Write.
+const (
+	FormatText  = "text"
+	FormatJSONL = "jsonl"
+	FormatSARIF = "sarif"
+)
+
+// CommitFindings is the validation outcome for one commit (or for the
+// index/working tree when Commit is empty).
+type CommitFindings struct {
+	Repo     string
+	Commit   string
+	Findings []validator.Finding
+}
+
+// Line is one finding in the JSON lines format.
+type Line struct {
+	Time     time.Time          `json:"time"`
+	Repo     string             `json:"repo"`
+	Commit   string             `json:"commit,omitempty"`
+	Rule     string             `json:"rule"`
+	Severity string             `json:"severity"`
+	Path     string             `json:"path,omitempty"`
+	Message  string             `json:"message"`
+	Project  *validator.Project `json:"project,omitempty"` // Parsed project folder the file sits in
+}
+
+// WriteJSONLines writes one JSON object per finding.
+func WriteJSONLines(w io.Writer, reports ...CommitFindings) error {
+	enc := json.NewEncoder(w)
+	now := time.Now().UTC()
+	for _, r := range reports {
+		for _, f := range r.Findings {
+			line := Line{
+				Time:     now,
+				Repo:     r.Repo,
+				Commit:   r.Commit,
+				Rule:     f.RuleID,
+				Severity: f.Severity.String(),
+				Path:     f.Path,
+				Message:  f.Message,
+			}
+			if project, ok := validator.ProjectForPath(f.Path); ok {
+				line.Project = &project
+			}
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendMu serialises appends from monitors sharing one JSON lines file.
+var appendMu sync.Mutex
+
+// AppendJSONLines appends the findings to a JSON lines file, creating it if needed.
+func AppendJSONLines(path string, reports ...CommitFindings) error {
+	appendMu.Lock()
+	defer appendMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create report directory for %s: %w", path, err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open report file %s: %w", path, err)
+	}
+	if err := WriteJSONLines(f, reports...); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write report file %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// --- SARIF 2.1.0 ---
+// Only the subset of the schema we populate is modelled here.
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolName     = "git-monitor-app"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool                     sarifTool                 `json:"tool"`
+	Results                  []sarifResult             `json:"results"`
+	VersionControlProvenance []sarifVersionControlInfo `json:"versionControlProvenance,omitempty"`
+	OriginalURIBaseIDs       map[string]sarifLocation  `json:"originalUriBaseIds,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocWrapper `json:"locations,omitempty"`
+	Properties map[string]any    `json:"properties,omitempty"`
+}
+
+type sarifLocWrapper struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifLocation `json:"artifactLocation"`
+}
+
+type sarifLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifVersionControlInfo struct {
+	RepositoryURI string `json:"repositoryUri"`
+	RevisionID    string `json:"revisionId,omitempty"`
+}
+
+// sarifLevel maps a severity onto SARIF's result levels.
+func sarifLevel(s validator.Severity) string {
+	switch s {
+	case validator.SeverityError:
+		return "error"
+	case validator.SeverityWarn:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// WriteSARIF writes a SARIF 2.1.0 log with one run per commit.
+func WriteSARIF(w io.Writer, reports ...CommitFindings) error {
+	var rules []sarifRule
+	for _, rule := range validator.Rules() {
+		rules = append(rules, sarifRule{
+			ID:                   rule.ID(),
+			ShortDescription:     sarifMessage{Text: rule.Description()},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(rule.DefaultSeverity())},
+		})
+	}
+
+	doc := sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{}}
+	for _, r := range reports {
+		repoURI := fileURI(r.Repo)
+		run := sarifRun{
+			Tool:               sarifTool{Driver: sarifDriver{Name: toolName, Rules: rules}},
+			Results:            []sarifResult{},
+			OriginalURIBaseIDs: map[string]sarifLocation{"REPOROOT": {URI: repoURI}},
+		}
+		if r.Commit != "" {
+			run.VersionControlProvenance = []sarifVersionControlInfo{{RepositoryURI: repoURI, RevisionID: r.Commit}}
+		}
+		for _, f := range r.Findings {
+			result := sarifResult{
+				RuleID:  f.RuleID,
+				Level:   sarifLevel(f.Severity),
+				Message: sarifMessage{Text: f.Message},
+			}
+			if f.Path != "" {
+				result.Locations = []sarifLocWrapper{{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifLocation{URI: (&url.URL{Path: filepath.ToSlash(f.Path)}).EscapedPath(), URIBaseID: "REPOROOT"},
+				}}}
+			}
+			result.Properties = map[string]any{}
+			if r.Commit != "" {
+				result.Properties["commit"] = r.Commit
+			}
+			if project, ok := validator.ProjectForPath(f.Path); ok {
+				result.Properties["project"] = project
+			}
+			run.Results = append(run.Results, result)
+		}
+		doc.Runs = append(doc.Runs, run)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// Write renders reports in a machine-readable format (jsonl or sarif).
+func Write(w io.Writer, format string, reports ...CommitFindings) error {
+	switch format {
+	case FormatJSONL:
+		return WriteJSONLines(w, reports...)
+	case FormatSARIF:
+		return WriteSARIF(w, reports...)
+	default:
+		return fmt.Errorf("unknown report format %q (expected %q or %q)", format, FormatJSONL, FormatSARIF)
+	}
+}
+
+// fileURI turns a repository path into a file:// URI with a trailing slash,
+// as SARIF expects for base URIs.
+func fileURI(repoPath string) string {
+	abs, err := filepath.Abs(repoPath)
+	if err != nil {
+		abs = repoPath
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs) + "/"}
+	return u.String()
+}
@@ -0,0 +1,237 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxHistory caps how many commit records are kept per repository.
+const maxHistory = 100
+
+// CommitRecord is the persisted outcome of processing a single commit.
+type CommitRecord struct {
+	Hash             string    `json:"hash"`
+	Ref              string    `json:"ref,omitempty"` // Ref the commit was discovered on, e.g. refs/heads/main
+	ProcessedAt      time.Time `json:"processed_at"`
+	Valid            bool      `json:"valid"`
+	ValidationErrors []string  `json:"validation_errors,omitempty"`
+	Warnings         []string  `json:"warnings,omitempty"`
+	BackupAttempted  bool      `json:"backup_attempted"`
+	BackupOK         bool      `json:"backup_ok"`
+	BackupError      string    `json:"backup_error,omitempty"`
+}
+
+// RepoState is everything the monitor remembers about one repository.
+type RepoState struct {
+	LastProcessedCommit string            `json:"last_processed_commit"`
+	Refs                map[string]string `json:"refs,omitempty"` // Last processed commit per followed ref
+	UpdatedAt           time.Time         `json:"updated_at"`
+	History             []CommitRecord    `json:"history,omitempty"` // Most recent last
+}
+
+// fileFormat is the on-disk JSON layout.
+type fileFormat struct {
+	Version int                   `json:"version"`
+	Repos   map[string]*RepoState `json:"repos"` // Keyed by absolute repository path
+}
+
+// Store is a small JSON-file backed state store. It is safe for concurrent use.
+type Store struct {
+	path string
+	mu   sync.Mutex
+	data fileFormat
+}
+
+// Open loads the state file at path, or starts empty if it doesn't exist yet.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		data: fileFormat{Version: 1, Repos: map[string]*RepoState{}},
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(content, &s.data); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if s.data.Repos == nil {
+		s.data.Repos = map[string]*RepoState{}
+	}
+	return s, nil
+}
+
+// Path returns the location of the backing file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// LastProcessedCommit returns the last commit recorded for repoPath, or "" if none.
+func (s *Store) LastProcessedCommit(repoPath string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rs, ok := s.data.Repos[repoKey(repoPath)]; ok {
+		return rs.LastProcessedCommit
+	}
+	return ""
+}
+
+// Repo returns a copy of the state recorded for repoPath.
+func (s *Store) Repo(repoPath string) (RepoState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs, ok := s.data.Repos[repoKey(repoPath)]
+	if !ok {
+		return RepoState{}, false
+	}
+	cp := *rs
+	cp.Refs = copyRefs(rs.Refs)
+	cp.History = append([]CommitRecord(nil), rs.History...)
+	return cp, true
+}
+
+// SetLastProcessedCommit moves the resume point without adding a history entry
+// (e.g. when HEAD is reset to an already-processed commit).
+func (s *Store) SetLastProcessedCommit(repoPath, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := s.repoLocked(repoPath)
+	rs.LastProcessedCommit = hash
+	rs.UpdatedAt = time.Now().UTC()
+	return s.saveLocked()
+}
+
+// Refs returns a copy of the per-ref resume points for repoPath (nil if none recorded yet).
+func (s *Store) Refs(repoPath string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rs, ok := s.data.Repos[repoKey(repoPath)]; ok {
+		return copyRefs(rs.Refs)
+	}
+	return nil
+}
+
+// SetRefs replaces the per-ref resume points, dropping refs that were deleted.
+func (s *Store) SetRefs(repoPath string, refs map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := s.repoLocked(repoPath)
+	rs.Refs = copyRefs(refs)
+	rs.UpdatedAt = time.Now().UTC()
+	return s.saveLocked()
+}
+
+// RecordCommit stores the outcome for a commit, advances the resume point and
+// flushes the state to disk.
+func (s *Store) RecordCommit(repoPath string, rec CommitRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec.ProcessedAt.IsZero() {
+		rec.ProcessedAt = time.Now().UTC()
+	}
+	rs := s.repoLocked(repoPath)
+	rs.LastProcessedCommit = rec.Hash
+	if rec.Ref != "" {
+		if rs.Refs == nil {
+			rs.Refs = map[string]string{}
+		}
+		rs.Refs[rec.Ref] = rec.Hash
+	}
+	rs.UpdatedAt = rec.ProcessedAt
+	rs.History = append(rs.History, rec)
+	if len(rs.History) > maxHistory {
+		rs.History = rs.History[len(rs.History)-maxHistory:]
+	}
+	return s.saveLocked()
+}
+
+// SetBackupResult updates the most recent record for a commit with the outcome
+// of retrying its backup. Unlike RecordCommit it leaves the resume point alone.
+func (s *Store) SetBackupResult(repoPath, hash string, backupErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := s.repoLocked(repoPath)
+	for i := len(rs.History) - 1; i >= 0; i-- {
+		rec := &rs.History[i]
+		if rec.Hash != hash {
+			continue
+		}
+		rec.BackupAttempted = true
+		rec.BackupOK = backupErr == nil
+		rec.BackupError = ""
+		if backupErr != nil {
+			rec.BackupError = backupErr.Error()
+		}
+		rs.UpdatedAt = time.Now().UTC()
+		return s.saveLocked()
+	}
+	return fmt.Errorf("no record of commit %s for %s", hash, repoPath)
+}
+
+// repoLocked returns the entry for repoPath, creating it if needed. Caller holds s.mu.
+func (s *Store) repoLocked(repoPath string) *RepoState {
+	key := repoKey(repoPath)
+	rs, ok := s.data.Repos[key]
+	if !ok {
+		rs = &RepoState{}
+		s.data.Repos[key] = rs
+	}
+	return rs
+}
+
+// saveLocked writes the state atomically (temp file + rename). Caller holds s.mu.
+func (s *Store) saveLocked() error {
+	content, err := json.MarshalIndent(&s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create state directory %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, ".state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp state file: %w", err)
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to write temp state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to close temp state file: %w", err)
+	}
+	if err := os.Rename(tmpName, s.path); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to replace state file %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// copyRefs returns an independent copy of a ref map.
+func copyRefs(refs map[string]string) map[string]string {
+	if refs == nil {
+		return nil
+	}
+	cp := make(map[string]string, len(refs))
+	for k, v := range refs {
+		cp[k] = v
+	}
+	return cp
+}
+
+// repoKey normalizes a repository path so the same repo always maps to one entry.
+func repoKey(repoPath string) string {
+	if abs, err := filepath.Abs(repoPath); err == nil {
+		return filepath.Clean(abs)
+	}
+	return filepath.Clean(repoPath)
+}
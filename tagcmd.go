@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"git-monitor-app/config"    // Use correct module path
+	"git-monitor-app/gitutil"   // Use correct module path
+	"git-monitor-app/tagging"   // Use correct module path
+	"git-monitor-app/validator" // Use correct module path
+)
+
+// runTag implements the `tag` subcommand: write title, artists, BPM and
+// producer tags derived from the project folder name into MP3 and FLAC
+// exports. The changes are built as a patch against a commit, then applied
+// to the working tree, committed, or saved for review.
+func runTag(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("tag", flag.ExitOnError)
+	repoName := fs.String("repo", "", "Repository to tag (path or directory name; optional with a single repository)")
+	dryRun := fs.Bool("dry-run", false, "Only list the exports whose tags would change")
+	patchFile := fs.String("patch", "", "Write the changes to this patch file instead of applying them")
+	commit := fs.Bool("commit", false, "Commit the retagged exports once applied")
+	message := fs.String("message", "Update export tags from project metadata", "Commit message used with -commit")
+	overrideFinal := fs.String("override-final", "", "Also retag final exports, giving the reason recorded in the commit's override trailer")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tag [-repo name] [-dry-run] [rev]             Retag exports in the working tree (default HEAD)")
+		fmt.Fprintln(fs.Output(), "       tag [-repo name] -commit [-message msg]       Retag and commit")
+		fmt.Fprintln(fs.Output(), "       tag [-repo name] -override-final <reason> ... Include final exports, which are skipped otherwise")
+		fmt.Fprintln(fs.Output(), "       tag [-repo name] -patch <file> [rev]          Write a patch for review instead")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *commit && *patchFile != "" {
+		return fmt.Errorf("-commit and -patch can't be used together")
+	}
+
+	repo, err := selectRepository(cfg, *repoName)
+	if err != nil {
+		return err
+	}
+	rev := "HEAD"
+	if fs.NArg() > 0 {
+		rev = fs.Arg(0)
+	}
+	commitHash, err := gitutil.ResolveCommit(repo.Path, rev)
+	if err != nil {
+		return err
+	}
+
+	plan, err := tagging.Build(repo.Path, commitHash)
+	if err != nil {
+		return err
+	}
+	// Delivered masters are guarded against replacement; retagging one has
+	// to be asked for like any other override
+	finalStatus, err := validator.FinalStatus(cfg.ValidationFor(repo))
+	if err != nil {
+		return err
+	}
+	var finals, changes []tagging.Change
+	for _, c := range plan.Changes {
+		if _, status, ok := validator.ParseExportName(c.Entry.Path); ok && status == finalStatus {
+			finals = append(finals, c)
+		} else {
+			changes = append(changes, c)
+		}
+	}
+	if strings.TrimSpace(*overrideFinal) == "" {
+		for _, c := range finals {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s: final export; use -override-final <reason> to retag it", c.Entry.Path))
+		}
+		plan.Changes, finals = changes, nil
+	}
+	printTagPlan(plan)
+	if len(plan.Changes) == 0 || *dryRun {
+		return nil
+	}
+
+	patch, err := plan.Patch(repo.Path)
+	if err != nil {
+		return err
+	}
+	if *patchFile != "" {
+		if err := os.WriteFile(*patchFile, patch, 0644); err != nil {
+			return fmt.Errorf("failed to write patch: %w", err)
+		}
+		fmt.Printf("Wrote %s; apply it with `git apply --index %s`.\n", *patchFile, *patchFile)
+		return nil
+	}
+
+	// Applying only makes sense on top of the checked-out commit
+	head, err := gitutil.ResolveCommit(repo.Path, "HEAD")
+	if err != nil {
+		return err
+	}
+	if head != commitHash {
+		return fmt.Errorf("%s is not the checked-out commit; use -patch to retag it", rev)
+	}
+	// A tagging commit must only contain the retagged exports
+	if *commit {
+		staged, err := gitutil.GetStagedFiles(repo.Path)
+		if err != nil {
+			return err
+		}
+		if len(staged) > 0 {
+			return fmt.Errorf("%d file(s) already staged; commit or unstage them before using -commit", len(staged))
+		}
+	}
+
+	if err := gitutil.ApplyPatch(repo.Path, patch, *commit); err != nil {
+		return fmt.Errorf("failed to retag exports (are they modified in the working tree?): %w", err)
+	}
+	fmt.Printf("Retagged %d export(s).\n", len(plan.Changes))
+
+	if *commit {
+		// Record the override for the pre-commit hook and for the monitor
+		// validating the commit
+		msg, env := *message, []string(nil)
+		if len(finals) > 0 {
+			msg = validator.WithFinalmasterOverride(msg, strings.TrimSpace(*overrideFinal))
+			env = []string{validator.FinalmasterOverrideEnv + "=1"}
+		}
+		hash, err := gitutil.Commit(repo.Path, msg, env...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Committed tags as %s\n", hash)
+	}
+	return nil
+}
+
+// printTagPlan lists the exports that need new tags and what they'll get,
+// followed by any that can't be tagged.
+func printTagPlan(plan *tagging.Plan) {
+	if len(plan.Changes) == 0 {
+		fmt.Println("All MP3 and FLAC export tags are up to date.")
+	}
+	for _, c := range plan.Changes {
+		t := c.Tags
+		fmt.Printf("%s\n   title=%q artists=%q bpm=%d producer=%q\n", c.Entry.Path, t.Title, strings.Join(t.Artists, ", "), t.BPM, t.Producer)
+	}
+	if plan.UpToDate > 0 {
+		fmt.Printf("\n%d export(s) already tagged.\n", plan.UpToDate)
+	}
+	if len(plan.Skipped) > 0 {
+		fmt.Printf("\nCan't tag (%d):\n", len(plan.Skipped))
+		for _, s := range plan.Skipped {
+			fmt.Printf("  - %s\n", s)
+		}
+	}
+}
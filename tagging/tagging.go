@@ -0,0 +1,154 @@
+package tagging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"git-monitor-app/audio"     // Use correct module path
+	"git-monitor-app/gitutil"   // Use correct module path
+	"git-monitor-app/validator" // Use correct module path
+)
+
+// Change is one export whose tags are out of date. Entry.Hash is the hash
+// of the retagged content, which is only written to the object database once
+// a patch is made (see Plan.Patch).
+type Change struct {
+	Entry    gitutil.TreeEntry
+	Tags     audio.Tags
+	Original string // Hash of the export as committed
+}
+
+// Plan is the outcome of checking every taggable export in a commit.
+type Plan struct {
+	Commit   string
+	Changes  []Change
+	UpToDate int      // Exports whose tags already match
+	Skipped  []string // Exports that couldn't be tagged, with the reason
+}
+
+// TagsFor derives an export's tags from its project folder. Folder names use
+// underscores for spaces, so those are turned back into spaces.
+func TagsFor(p validator.Project) audio.Tags {
+	tags := audio.Tags{
+		Title:    humanize(p.Title),
+		BPM:      p.BPM,
+		Producer: humanize(p.Producer),
+	}
+	for _, c := range p.Collaborators {
+		tags.Artists = append(tags.Artists, humanize(c))
+	}
+	return tags
+}
+
+func humanize(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "_", " "))
+}
+
+// Build checks the MP3 and FLAC exports in a commit's tree against the tags
+// their project folder implies and lists the ones that differ. Nothing is
+// written, to the object database or the working tree.
+func Build(repoPath, commitHash string) (*Plan, error) {
+	entries, err := gitutil.ListTree(repoPath, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Commit: commitHash}
+	var targets []Change
+	var hashes []string
+	for _, e := range entries {
+		folder := validator.ProjectFolder(e.Path)
+		if folder == "" || e.Type != "blob" || !strings.HasPrefix(e.Path, folder+"/exports/") {
+			continue
+		}
+		// Only exports named after their own, well-formed folder
+		base, _, ok := validator.ParseExportName(path.Base(e.Path))
+		if !ok || base != path.Base(folder) {
+			continue
+		}
+		project, err := validator.ParseProjectFolder(path.Base(folder))
+		if err != nil {
+			continue
+		}
+		if ext := strings.ToLower(path.Ext(e.Path)); ext != ".mp3" && ext != ".flac" {
+			continue // WAV has no widely read tag format
+		}
+		targets = append(targets, Change{Entry: e, Tags: TagsFor(project), Original: e.Hash})
+		hashes = append(hashes, e.Hash)
+	}
+	if len(targets) == 0 {
+		return plan, nil
+	}
+
+	// Blobs come back in request order
+	i := 0
+	err = gitutil.ReadBlobs(repoPath, hashes, func(_ string, _ int64, content io.Reader) error {
+		c := targets[i]
+		i++
+		var original, retagged bytes.Buffer
+		if err := audio.WriteTags(io.TeeReader(content, &original), &retagged, c.Tags); err != nil {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s: %v", c.Entry.Path, err))
+			return nil
+		}
+		if bytes.Equal(original.Bytes(), retagged.Bytes()) {
+			plan.UpToDate++
+			return nil
+		}
+		hash, err := gitutil.HashBlob(repoPath, retagged.Bytes())
+		if err != nil {
+			return err
+		}
+		c.Entry.Hash = hash
+		plan.Changes = append(plan.Changes, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Patch renders the plan as a binary git patch against its commit, suitable
+// for `git apply` or for review. The retagged exports are written to the
+// object database first, since the diff is made from them.
+func (p *Plan) Patch(repoPath string) ([]byte, error) {
+	if len(p.Changes) == 0 {
+		return nil, nil
+	}
+	entries := make([]gitutil.TreeEntry, len(p.Changes))
+	originals := make([]string, len(p.Changes))
+	for i, c := range p.Changes {
+		entries[i] = c.Entry
+		originals[i] = c.Original
+	}
+
+	// Retag again rather than keeping every retagged export in memory since Build
+	i := 0
+	err := gitutil.ReadBlobs(repoPath, originals, func(_ string, _ int64, content io.Reader) error {
+		c := p.Changes[i]
+		i++
+		var retagged bytes.Buffer
+		if err := audio.WriteTags(content, &retagged, c.Tags); err != nil {
+			return fmt.Errorf("failed to retag %s: %w", c.Entry.Path, err)
+		}
+		hash, err := gitutil.WriteBlob(repoPath, retagged.Bytes())
+		if err != nil {
+			return err
+		}
+		if hash != c.Entry.Hash {
+			return fmt.Errorf("retagging %s gave %s, not the planned %s", c.Entry.Path, hash, c.Entry.Hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	tree, err := gitutil.ReplaceBlobs(repoPath, p.Commit, entries)
+	if err != nil {
+		return nil, err
+	}
+	return gitutil.DiffBinary(repoPath, p.Commit, tree)
+}
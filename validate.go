@@ -0,0 +1,243 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"git-monitor-app/config"    // Use correct module path
+	"git-monitor-app/gitutil"   // Use correct module path
+	"git-monitor-app/report"    // Use correct module path
+	"git-monitor-app/validator" // Use correct module path
+)
+
+// runValidate implements the `validate` subcommand: run the validator outside
+// the daemon, either over a commit's changes or over a whole tree.
+func runValidate(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	repoName := fs.String("repo", "", "Repository to validate (path or directory name; optional with a single repository)")
+	all := fs.Bool("all", false, "Validate every file in the tree at the revision, not just the files it changed")
+	worktree := fs.Bool("worktree", false, "Validate every file in the working tree, including untracked files")
+	staged := fs.Bool("staged", false, "Validate the files staged for commit (used by the pre-commit hook)")
+	commitRange := fs.String("range", "", "Validate every commit in `A..B`, or every commit reachable from a revision but not yet on a remote (used by the pre-push hook)")
+	format := fs.String("format", report.FormatText, "Report format: text, jsonl (one JSON object per finding) or sarif (SARIF 2.1.0)")
+	output := fs.String("output", "", "Write the report to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: validate [-repo name] [rev]          Validate the files changed by a commit (default HEAD)")
+		fmt.Fprintln(fs.Output(), "       validate [-repo name] -all [rev]     Audit every file in the tree at a revision")
+		fmt.Fprintln(fs.Output(), "       validate [-repo name] -worktree      Audit the working tree, including untracked files")
+		fmt.Fprintln(fs.Output(), "       validate [-repo name] -staged        Check staged changes (pre-commit hook)")
+		fmt.Fprintln(fs.Output(), "       validate [-repo name] -range <A..B>  Check every commit in a range (pre-push hook)")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	repo, err := selectRepository(cfg, *repoName)
+	if err != nil {
+		return err
+	}
+
+	rev := "HEAD"
+	if fs.NArg() > 0 {
+		rev = fs.Arg(0)
+	}
+
+	sink, err := newReportSink(*format, *output)
+	if err != nil {
+		return err
+	}
+	defer sink.close()
+
+	if *commitRange != "" {
+		if err := validateRange(cfg, repo, *commitRange, sink); err != nil {
+			return err
+		}
+		return sink.flush()
+	}
+
+	var files []string
+	var commitHash, scope string
+	switch {
+	case *staged:
+		if files, err = gitutil.GetStagedFiles(repo.Path); err != nil {
+			return err
+		}
+		scope = "staged changes"
+	case *worktree:
+		if files, err = gitutil.ListWorkingTreeFiles(repo.Path); err != nil {
+			return err
+		}
+		scope = "working tree"
+	default:
+		if commitHash, err = gitutil.ResolveCommit(repo.Path, rev); err != nil {
+			return err
+		}
+		if *all {
+			entries, err := gitutil.ListTree(repo.Path, commitHash)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				files = append(files, e.Path)
+			}
+			scope = fmt.Sprintf("full tree at %s (%s)", rev, commitHash)
+		} else {
+			if files, err = gitutil.GetChangedFilesInCommit(repo.Path, commitHash); err != nil {
+				return err
+			}
+			scope = fmt.Sprintf("changes in %s (%s)", rev, commitHash)
+		}
+	}
+
+	var result validator.Result
+	if *worktree {
+		result = validator.ValidateWorktree(repo.Path, files, cfg.ValidationFor(repo))
+	} else {
+		result = validator.Validate(repo.Path, commitHash, files, cfg.ValidationFor(repo))
+	}
+	sink.add(repo.Path, commitHash, scope, len(files), result, true)
+	if err := sink.flush(); err != nil {
+		return err
+	}
+
+	if !result.Valid() {
+		return fmt.Errorf("%d error(s) found", len(result.Errors()))
+	}
+	return nil
+}
+
+// validateRange checks each commit in a range on its own, the same way the
+// monitor would once it lands, and fails if any of them has errors.
+func validateRange(cfg *config.Config, repo config.RepositoryConfig, spec string, sink *reportSink) error {
+	var commits []string
+	if from, to, ok := strings.Cut(spec, ".."); ok {
+		fromHash, err := gitutil.ResolveCommit(repo.Path, from)
+		if err != nil {
+			return err
+		}
+		toHash, err := gitutil.ResolveCommit(repo.Path, to)
+		if err != nil {
+			return err
+		}
+		if commits, err = gitutil.GetCommitRange(repo.Path, fromHash, toHash); err != nil {
+			return err
+		}
+	} else {
+		tip, err := gitutil.ResolveCommit(repo.Path, spec)
+		if err != nil {
+			return err
+		}
+		if commits, err = gitutil.GetUnpushedCommits(repo.Path, tip); err != nil {
+			return err
+		}
+	}
+
+	invalid := 0
+	for _, hash := range commits {
+		files, err := gitutil.GetChangedFilesInCommit(repo.Path, hash)
+		if err != nil {
+			return err
+		}
+		result := validator.Validate(repo.Path, hash, files, cfg.ValidationFor(repo))
+		sink.add(repo.Path, hash, "commit "+hash, len(files), result, false)
+		if !result.Valid() {
+			invalid++
+		}
+	}
+	if sink.format == report.FormatText {
+		fmt.Fprintf(sink.w, "\nChecked %d commit(s) in %s: %d with errors\n", len(commits), spec, invalid)
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d commit(s) failed validation", invalid, len(commits))
+	}
+	return nil
+}
+
+// reportSink sends validation results to stdout or a file, either as the
+// human-readable grouped report or in a machine-readable format.
+type reportSink struct {
+	format  string
+	w       io.Writer
+	file    *os.File // Set when writing to -output
+	reports []report.CommitFindings
+}
+
+func newReportSink(format, outputPath string) (*reportSink, error) {
+	switch format {
+	case report.FormatText, report.FormatJSONL, report.FormatSARIF:
+	default:
+		return nil, fmt.Errorf("unknown -format %q (expected text, jsonl or sarif)", format)
+	}
+	sink := &reportSink{format: format, w: os.Stdout}
+	if outputPath != "" {
+		f, err := os.Create(outputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create report file: %w", err)
+		}
+		sink.file, sink.w = f, f
+	}
+	return sink, nil
+}
+
+// add records one validation run. Text reports are printed immediately;
+// printEmpty controls whether a run without findings is printed at all.
+func (s *reportSink) add(repoPath, commit, scope string, fileCount int, result validator.Result, printEmpty bool) {
+	if s.format == report.FormatText {
+		if printEmpty || len(result.Findings) > 0 {
+			printGroupedReport(s.w, repoPath, scope, fileCount, result)
+		}
+		return
+	}
+	s.reports = append(s.reports, report.CommitFindings{Repo: repoPath, Commit: commit, Findings: result.Findings})
+}
+
+// flush writes machine-readable formats, which need every run up front.
+func (s *reportSink) flush() error {
+	if s.format == report.FormatText {
+		return nil
+	}
+	return report.Write(s.w, s.format, s.reports...)
+}
+
+func (s *reportSink) close() {
+	if s.file != nil {
+		s.file.Close()
+	}
+}
+
+// printGroupedReport prints findings grouped by project folder, with
+// everything outside src/projects/ in its own group at the end.
+func printGroupedReport(w io.Writer, repoPath, scope string, fileCount int, result validator.Result) {
+	const outsideProjects = "(outside project folders)"
+	groups := map[string][]validator.Finding{}
+	for _, f := range result.Findings {
+		key := validator.ProjectFolder(f.Path)
+		if key == "" {
+			key = outsideProjects
+		}
+		groups[key] = append(groups[key], f)
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		if k != outsideProjects {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := groups[outsideProjects]; ok {
+		keys = append(keys, outsideProjects)
+	}
+
+	fmt.Fprintf(w, "\nValidation report for %s: %s, %d file(s)\n", repoPath, scope, fileCount)
+	for _, k := range keys {
+		fmt.Fprintf(w, "\n== %s (%d finding(s))\n", k, len(groups[k]))
+		for _, f := range groups[k] {
+			fmt.Fprintf(w, "  %s\n", f)
+		}
+	}
+	fmt.Fprintf(w, "\nSummary: %d error(s), %d warning(s), %d finding(s) in total across %d group(s)\n",
+		len(result.Errors()), len(result.Warnings()), len(result.Findings), len(keys))
+}
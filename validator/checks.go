@@ -0,0 +1,279 @@
+package validator
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"git-monitor-app/gitutil" // Use correct module path
+)
+
+// pathKind says where in the repository layout a changed file sits.
+type pathKind int
+
+const (
+	kindRootFile      pathKind = iota // README.md, .gitignore, ...
+	kindTopLevelOther                 // Anything outside src/ that isn't a root file
+	kindSrcOther                      // src/<not projects>/... (not checked in detail)
+	kindProjectsLoose                 // File directly inside src/projects/
+	kindProjectFile                   // src/projects/<folder>/<file>
+	kindProjectExport                 // src/projects/<folder>/exports/.../<file>
+	kindProjectOther                  // Anything else inside a project folder
+)
+
+// pathInfo is a changed file broken down into the parts the rules care about.
+type pathInfo struct {
+	Path          string // As reported by git
+	Kind          pathKind
+	Base          string // Last path component
+	ProjectFolder string // Set for every kind under a project folder
+	FolderValid   bool   // Whether ProjectFolder matches the folder-name pattern
+	FolderErr     error  // Why it doesn't, when FolderValid is false
+	InsideProject string // Path relative to the project folder
+}
+
+// classifyPath works out where a file sits in the expected layout:
+//
+//	README.md, .gitignore, config.toml
+//	src/projects/<folder>/<folder>.<project ext>
+//	src/projects/<folder>/exports/<folder>-<status>.<export ext>
+func classifyPath(file string, rules *ruleSet) pathInfo {
+	// Use filepath.ToSlash for consistent path separators
+	filePath := filepath.ToSlash(file)
+	info := pathInfo{Path: file, Base: path.Base(filePath)}
+	parts := strings.Split(filePath, "/")
+
+	switch {
+	case len(parts) == 1:
+		info.Kind = kindRootFile
+	case parts[0] != "src":
+		info.Kind = kindTopLevelOther
+	case parts[1] != "projects":
+		info.Kind = kindSrcOther
+	case len(parts) == 3:
+		info.Kind = kindProjectsLoose
+	default:
+		info.ProjectFolder = parts[2]
+		info.FolderErr = rules.checkProjectFolder(info.ProjectFolder)
+		info.FolderValid = info.FolderErr == nil
+		info.InsideProject = strings.Join(parts[3:], "/")
+		switch {
+		case len(parts) == 4:
+			info.Kind = kindProjectFile
+		case parts[3] == "exports":
+			info.Kind = kindProjectExport
+		default:
+			info.Kind = kindProjectOther
+		}
+	}
+	return info
+}
+
+// fileRule is a Rule that looks at each changed file on its own.
+type fileRule struct {
+	id          string
+	description string
+	severity    Severity
+	check       func(rules *ruleSet, f pathInfo) []string // Returns messages
+}
+
+func (r fileRule) ID() string                { return r.id }
+func (r fileRule) Description() string       { return r.description }
+func (r fileRule) DefaultSeverity() Severity { return r.severity }
+
+func (r fileRule) Check(in *Input) []Finding {
+	var findings []Finding
+	for _, f := range in.Files {
+		for _, msg := range r.check(in.rules, f) {
+			findings = append(findings, Finding{Path: f.Path, Message: msg})
+		}
+	}
+	return findings
+}
+
+// requiredFilesRule checks the whole commit (or index) rather than changed files.
+type requiredFilesRule struct{}
+
+func (requiredFilesRule) ID() string { return "required-files" }
+func (requiredFilesRule) Description() string {
+	return "Required root files must exist in the repository"
+}
+func (requiredFilesRule) DefaultSeverity() Severity { return SeverityError }
+
+func (requiredFilesRule) Check(in *Input) []Finding {
+	// This check runs regardless of validation status of changed files
+	log.Println("Validator: Checking existence of required files in repository...")
+	var findings []Finding
+	for _, reqFile := range in.rules.requiredRootFiles {
+		if in.Worktree {
+			if _, err := os.Stat(filepath.Join(in.RepoPath, reqFile)); err != nil {
+				findings = append(findings, Finding{Path: reqFile, Message: fmt.Sprintf("Required file '%s' not found in the working tree.", reqFile)})
+			}
+		} else if in.CommitHash != "" {
+			if !gitutil.FileExistsInCommit(in.RepoPath, in.CommitHash, reqFile) {
+				findings = append(findings, Finding{Path: reqFile, Message: fmt.Sprintf("Required file '%s' not found in commit %s.", reqFile, in.CommitHash)})
+			}
+		} else if !gitutil.CheckFileExists(in.RepoPath, reqFile) {
+			findings = append(findings, Finding{Path: reqFile, Message: fmt.Sprintf("Required file '%s' not found in repository index.", reqFile)})
+		}
+	}
+	if len(findings) == 0 {
+		log.Println("Validator: All required files found.")
+	}
+	return findings
+}
+
+// allRules lists every built-in rule in the order they run.
+var allRules = []Rule{
+	fileRule{
+		id:          "no-spaces",
+		description: "Paths must not contain spaces",
+		severity:    SeverityError,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			if strings.Contains(f.Path, " ") {
+				return []string{fmt.Sprintf("Path contains spaces: '%s'", f.Path)}
+			}
+			return nil
+		},
+	},
+	fileRule{
+		id:          "root-files",
+		description: "Only the allowed root files and src/ may exist at the top level",
+		severity:    SeverityError,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			switch {
+			case f.Kind == kindRootFile && !rules.allowedRoot[f.Base]:
+				return []string{fmt.Sprintf("Unexpected file in root directory: '%s'", f.Path)}
+			case f.Kind == kindTopLevelOther:
+				return []string{fmt.Sprintf("Unexpected top-level file or directory: '%s'. Only 'src/' and root files %s allowed.", f.Path, quoteList(rules.allowedRootFiles))}
+			}
+			return nil
+		},
+	},
+	fileRule{
+		id:          "project-folder-name",
+		description: "Project folders must match the project folder name pattern",
+		severity:    SeverityError,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			if f.ProjectFolder != "" && !f.FolderValid {
+				return []string{fmt.Sprintf("Invalid project folder name format: '%s' (%v) in path '%s'", f.ProjectFolder, f.FolderErr, f.Path)}
+			}
+			return nil
+		},
+	},
+	fileRule{
+		id:          "project-layout",
+		description: "Project folders may only contain the project file and an exports/ directory",
+		severity:    SeverityError,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			switch {
+			case f.Kind == kindProjectsLoose:
+				return []string{fmt.Sprintf("Files are not allowed directly inside 'src/projects/'. Place them in a named project folder: '%s'", f.Path)}
+			case f.Kind == kindProjectOther && f.FolderValid:
+				return []string{fmt.Sprintf("Unexpected file or directory inside project folder: '%s' in path '%s'. Only project file and 'exports/' dir allowed directly under '%s/'", f.InsideProject, f.Path, f.ProjectFolder)}
+			}
+			return nil
+		},
+	},
+	fileRule{
+		id:          "project-file-name",
+		description: "Project file names must match their folder name",
+		severity:    SeverityError,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			if f.Kind != kindProjectFile || !f.FolderValid {
+				return nil
+			}
+			if strings.TrimSuffix(f.Base, path.Ext(f.Base)) != f.ProjectFolder {
+				return []string{fmt.Sprintf("Project filename base must match folder name. Expected '%s.*', found '%s' in path '%s'", f.ProjectFolder, f.Base, f.Path)}
+			}
+			return nil
+		},
+	},
+	fileRule{
+		id:          "project-file-extension",
+		description: "Project files must use an allowed DAW extension",
+		severity:    SeverityError,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			if f.Kind != kindProjectFile || !f.FolderValid {
+				return nil
+			}
+			if !rules.projectExtRegex.MatchString(strings.ToLower(f.Base)) { // Check extension case-insensitively
+				return []string{fmt.Sprintf("Invalid file extension for project file '%s'. Allowed: %s. Path: '%s'", f.Base, dotList(rules.projectExtensions), f.Path)}
+			}
+			return nil
+		},
+	},
+	fileRule{
+		id:          "export-name",
+		description: "Export file names must be '<project folder>-<status>'",
+		severity:    SeverityError,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			if f.Kind != kindProjectExport || !f.FolderValid {
+				return nil
+			}
+			match := exportNameRegex.FindStringSubmatch(strings.TrimSuffix(f.Base, path.Ext(f.Base)))
+			if len(match) != 3 {
+				return []string{fmt.Sprintf("Export filename '%s' does not match expected format '[project_base]-[status]'. Path: '%s'", f.Base, f.Path)}
+			}
+			if match[1] != f.ProjectFolder {
+				return []string{fmt.Sprintf("Export filename base must match project folder name. Expected '%s-[status].*', found '%s' in path '%s'", f.ProjectFolder, f.Base, f.Path)}
+			}
+			return nil
+		},
+	},
+	fileRule{
+		id:          "export-status",
+		description: "Export status suffixes must be one of the configured statuses",
+		severity:    SeverityError,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			if f.Kind != kindProjectExport || !f.FolderValid {
+				return nil
+			}
+			match := exportNameRegex.FindStringSubmatch(strings.TrimSuffix(f.Base, path.Ext(f.Base)))
+			if len(match) == 3 && !rules.exportStatusRegex.MatchString(match[2]) {
+				return []string{fmt.Sprintf("Invalid status identifier '%s' in export filename '%s'. Allowed: %s. Path: '%s'", match[2], f.Base, strings.Join(rules.exportStatuses, ", "), f.Path)}
+			}
+			return nil
+		},
+	},
+	fileRule{
+		id:          "export-extension",
+		description: "Exports must use an allowed audio extension",
+		severity:    SeverityError,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			if f.Kind != kindProjectExport || !f.FolderValid {
+				return nil
+			}
+			if !rules.exportExtRegex.MatchString(strings.ToLower(f.Base)) { // Case-insensitive check
+				return []string{fmt.Sprintf("Invalid file extension for export file '%s'. Allowed: %s. Path: '%s'", f.Base, dotList(rules.exportExtensions), f.Path)}
+			}
+			return nil
+		},
+	},
+	fileRule{
+		id:          "extension-case",
+		description: "Project and export file extensions should be lowercase",
+		severity:    SeverityWarn,
+		check: func(rules *ruleSet, f pathInfo) []string {
+			if (f.Kind != kindProjectFile && f.Kind != kindProjectExport) || !f.FolderValid {
+				return nil
+			}
+			if ext := path.Ext(f.Base); ext != strings.ToLower(ext) {
+				return []string{fmt.Sprintf("Extension '%s' should be lowercase. Path: '%s'", ext, f.Path)}
+			}
+			return nil
+		},
+	},
+	audioFormatRule{},
+	audioPolicyRule{},
+	bpmRule{},
+	loudnessRule{},
+	truePeakRule{},
+	clippingRule{},
+	requiredFilesRule{},
+	exportRegressionRule{},
+	finalmasterReplacedRule{},
+}
@@ -0,0 +1,260 @@
+package validator
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"git-monitor-app/audio"   // Use correct module path
+	"git-monitor-app/config"  // Use correct module path
+	"git-monitor-app/gitutil" // Use correct module path
+)
+
+// probedExport is the outcome of reading one export's audio header.
+type probedExport struct {
+	file   pathInfo
+	object string // Name the content was read from with git cat-file; "" when read from disk
+	info   audio.Info
+	err    error
+}
+
+// audioExports lazily reads the headers of the changed exports that use one
+// of the formats we can parse. Content comes from the commit, or from the
+// index when validating the index; files not in the index, and every file
+// when validating the working tree, are read from disk.
+func (in *Input) audioExports() []probedExport {
+	if in.probed != nil {
+		return *in.probed
+	}
+	probed := []probedExport{}
+	in.probed = &probed
+
+	var files []pathInfo
+	for _, f := range in.Files {
+		if f.Kind == kindProjectExport && f.FolderValid && probeable(f.Base) {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return probed
+	}
+
+	// Object names for git cat-file, and the file each one is for. Blobs are
+	// read back in request order, so duplicates (e.g. identical files) are fine.
+	var names []string
+	var targets []pathInfo
+	var onDisk []pathInfo
+	if in.Worktree {
+		onDisk = files
+	} else if in.CommitHash != "" {
+		for _, f := range files {
+			names = append(names, in.CommitHash+":"+filepath.ToSlash(f.Path))
+			targets = append(targets, f)
+		}
+	} else {
+		paths := make([]string, len(files))
+		for i, f := range files {
+			paths[i] = f.Path
+		}
+		blobs, err := gitutil.IndexBlobs(in.RepoPath, paths)
+		if err != nil {
+			log.Printf("Validator Error: Failed to look up staged exports: %v", err)
+			return probed
+		}
+		for _, f := range files {
+			if hash, ok := blobs[filepath.ToSlash(f.Path)]; ok {
+				names = append(names, hash)
+				targets = append(targets, f)
+			} else {
+				onDisk = append(onDisk, f)
+			}
+		}
+	}
+
+	if len(names) > 0 {
+		err := gitutil.ReadBlobs(in.RepoPath, names, func(_ string, size int64, content io.Reader) error {
+			i := len(probed)
+			info, err := audio.Probe(content, size)
+			probed = append(probed, probedExport{file: targets[i], object: names[i], info: info, err: err})
+			return nil
+		})
+		if err != nil {
+			log.Printf("Validator Error: Failed to read export contents: %v", err)
+		}
+	}
+	for _, f := range onDisk {
+		probed = append(probed, probeFile(in.RepoPath, f))
+	}
+	return probed
+}
+
+// probeFile reads an export's header from the working tree.
+func probeFile(repoPath string, f pathInfo) probedExport {
+	file, err := os.Open(filepath.Join(repoPath, filepath.FromSlash(f.Path)))
+	if err != nil {
+		return probedExport{file: f, err: err}
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return probedExport{file: f, err: err}
+	}
+	info, err := audio.Probe(file, stat.Size())
+	return probedExport{file: f, info: info, err: err}
+}
+
+// probeable reports whether the export's extension is a format we can parse.
+func probeable(name string) bool {
+	switch strings.ToLower(strings.TrimPrefix(path.Ext(name), ".")) {
+	case audio.FormatWAV, audio.FormatFLAC, audio.FormatMP3:
+		return true
+	}
+	return false
+}
+
+// audioFormatRule checks that an export's content is what its extension says.
+type audioFormatRule struct{}
+
+func (audioFormatRule) ID() string { return "export-audio-format" }
+func (audioFormatRule) Description() string {
+	return "WAV, FLAC and MP3 exports must contain a valid, complete file of that format"
+}
+func (audioFormatRule) DefaultSeverity() Severity { return SeverityError }
+
+func (audioFormatRule) Check(in *Input) []Finding {
+	var findings []Finding
+	for _, p := range in.audioExports() {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(p.file.Base), "."))
+		switch {
+		case p.err != nil:
+			findings = append(findings, Finding{Path: p.file.Path, Message: fmt.Sprintf("Export '%s' is not a valid .%s file: %v", p.file.Path, ext, p.err)})
+		case p.info.Format != ext:
+			findings = append(findings, Finding{Path: p.file.Path, Message: fmt.Sprintf("Export '%s' has a .%s extension but contains %s", p.file.Path, ext, audio.Describe(p.info.Format))})
+		}
+	}
+	return findings
+}
+
+// audioPolicyRule applies the [[validation.audio_policies]] to each export
+// whose header could be read.
+type audioPolicyRule struct{}
+
+func (audioPolicyRule) ID() string { return "export-audio-policy" }
+func (audioPolicyRule) Description() string {
+	return "Exports must meet the configured format, sample rate and bit depth policies"
+}
+func (audioPolicyRule) DefaultSeverity() Severity { return SeverityError }
+
+func (audioPolicyRule) Check(in *Input) []Finding {
+	if len(in.rules.audioPolicies) == 0 {
+		return nil
+	}
+	var findings []Finding
+	for _, p := range in.audioExports() {
+		if p.err != nil {
+			continue // Reported by export-audio-format
+		}
+		_, status, _ := ParseExportName(p.file.Base)
+		for _, policy := range in.rules.audioPolicies {
+			if len(policy.Statuses) > 0 && !containsString(policy.Statuses, status) {
+				continue
+			}
+			if problem := policyViolation(policy, p.info); problem != "" {
+				findings = append(findings, Finding{Path: p.file.Path, Message: fmt.Sprintf("Export '%s' %s. Path: '%s'", p.file.Base, problem, p.file.Path)})
+			}
+		}
+	}
+	return findings
+}
+
+// bpmRule compares the tempo embedded in an export (acid/bext/iXML chunks,
+// ID3 TBPM, FLAC BPM comment) with the BPM in its project folder name.
+type bpmRule struct{}
+
+func (bpmRule) ID() string { return "export-bpm" }
+func (bpmRule) Description() string {
+	return "Tempo metadata embedded in exports should match the project folder's BPM"
+}
+func (bpmRule) DefaultSeverity() Severity { return SeverityWarn }
+
+func (bpmRule) Check(in *Input) []Finding {
+	var findings []Finding
+	for _, p := range in.audioExports() {
+		if p.err != nil || p.info.BPM == 0 {
+			continue // Nothing embedded to compare
+		}
+		project, err := ParseProjectFolder(p.file.ProjectFolder)
+		if err != nil {
+			continue // Custom folder pattern without the standard BPM segment
+		}
+		// Folder BPMs are whole numbers; DAWs often store e.g. 139.998
+		if math.Abs(p.info.BPM-float64(project.BPM)) >= 0.5 {
+			findings = append(findings, Finding{Path: p.file.Path, Message: fmt.Sprintf("Export '%s' has %s BPM in its %s, but the project folder says %d BPM. Path: '%s'", p.file.Base, strconv.FormatFloat(math.Round(p.info.BPM*100)/100, 'f', -1, 64), p.info.BPMSource, project.BPM, p.file.Path)})
+		}
+	}
+	return findings
+}
+
+// policyViolation describes the first way info breaks policy, or returns "".
+func policyViolation(policy config.AudioPolicy, info audio.Info) string {
+	scope := "exports"
+	if len(policy.Statuses) > 0 {
+		scope = "'" + strings.Join(policy.Statuses, "'/'") + "' exports"
+	}
+	if len(policy.Formats) > 0 && !containsFold(policy.Formats, info.Format) {
+		return fmt.Sprintf("is %s, but %s must be %s", strings.ToUpper(info.Format), scope, strings.ToUpper(strings.Join(policy.Formats, " or ")))
+	}
+	if len(policy.SampleRates) > 0 && !containsInt(policy.SampleRates, info.SampleRate) {
+		return fmt.Sprintf("is %d Hz, but %s must be %s Hz", info.SampleRate, scope, joinInts(policy.SampleRates))
+	}
+	if len(policy.BitDepths) > 0 && !containsInt(policy.BitDepths, info.BitDepth) {
+		depth := fmt.Sprintf("%d-bit", info.BitDepth)
+		if info.BitDepth == 0 {
+			depth = "lossy"
+		}
+		return fmt.Sprintf("is %s, but %s must be %s-bit", depth, scope, joinInts(policy.BitDepths))
+	}
+	return ""
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, n int) bool {
+	for _, v := range values {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// joinInts renders 44100, 48000 as "44100 or 48000".
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, " or ")
+}
@@ -0,0 +1,180 @@
+package validator
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+// Rename is one proposed `git mv`.
+type Rename struct {
+	From  string
+	To    string
+	Rules []string // IDs of the rules the rename fixes
+}
+
+// RenamePlan is the set of renames that fix a validation result's naming
+// findings, plus whatever is left for a human to sort out.
+type RenamePlan struct {
+	Renames    []Rename
+	Unfixable  []Finding // Findings no rename resolves
+	Collisions []string  // Why the plan cannot be applied as-is
+}
+
+// fixers rewrite a path to resolve one rule's finding. They are only used
+// for files that rule actually flags, so a disabled or ignored rule never
+// causes a rename. Returning the path unchanged means "can't fix this".
+var fixers = map[string]func(f pathInfo) string{
+	"no-spaces": func(f pathInfo) string {
+		return strings.ReplaceAll(f.Path, " ", "_")
+	},
+	"project-layout": func(f pathInfo) string {
+		// Only a mis-cased exports/ directory is fixable; anything else needs a human
+		parts := strings.Split(filepath.ToSlash(f.Path), "/")
+		if f.Kind == kindProjectOther && len(parts) > 4 && strings.EqualFold(parts[3], "exports") {
+			parts[3] = "exports"
+			return strings.Join(parts, "/")
+		}
+		return f.Path
+	},
+	"extension-case": func(f pathInfo) string {
+		ext := path.Ext(f.Path)
+		return strings.TrimSuffix(f.Path, ext) + strings.ToLower(ext)
+	},
+	"project-file-name": func(f pathInfo) string {
+		return path.Join(path.Dir(filepath.ToSlash(f.Path)), f.ProjectFolder+path.Ext(f.Base))
+	},
+	"export-name": func(f pathInfo) string {
+		match := exportNameRegex.FindStringSubmatch(strings.TrimSuffix(f.Base, path.Ext(f.Base)))
+		if len(match) != 3 {
+			return f.Path // No status suffix to keep
+		}
+		return path.Join(path.Dir(filepath.ToSlash(f.Path)), f.ProjectFolder+"-"+match[2]+path.Ext(f.Base))
+	},
+}
+
+// PlanRenames works out renames for the files flagged in result by rules
+// that have a fixer. files is every file in the tree being fixed, used to
+// detect renames that would overwrite an existing file or each other.
+func PlanRenames(files []string, result Result, cfg config.ValidationConfig) (*RenamePlan, error) {
+	rules, err := newRuleSet(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RenamePlan{}
+	var candidates []string
+	seen := map[string]bool{}
+	for _, f := range result.Findings {
+		if fixers[f.RuleID] != nil && !seen[f.Path] {
+			seen[f.Path] = true
+			candidates = append(candidates, f.Path)
+		}
+	}
+	sort.Strings(candidates)
+
+	targets := map[string]string{} // Original path -> new path
+	for _, from := range candidates {
+		to := from
+		// One fix can expose another (e.g. removing spaces makes the folder
+		// name valid, which enables the file name checks), so keep going
+		// until nothing changes. The bound guards against fixers that disagree.
+		for i := 0; i < 2*len(fixers); i++ {
+			next := to
+			for _, id := range rules.fixableRules(to) {
+				if next = fixers[id](classifyPath(to, rules)); next != to {
+					break
+				}
+			}
+			if next == to {
+				break
+			}
+			to = next
+		}
+		if to != from {
+			targets[from] = to
+		}
+	}
+
+	// A finding is fixed when its rule no longer flags the renamed path;
+	// that also covers rules without a fixer, like an invalid folder name
+	// that only needed its spaces removed.
+	resolved := map[string][]string{}
+	for _, f := range result.Findings {
+		to, ok := targets[f.Path]
+		if ok && !rules.flags(f.RuleID, to) {
+			resolved[f.Path] = append(resolved[f.Path], f.RuleID)
+			continue
+		}
+		plan.Unfixable = append(plan.Unfixable, f)
+	}
+	for _, from := range candidates {
+		if to, ok := targets[from]; ok {
+			plan.Renames = append(plan.Renames, Rename{From: from, To: to, Rules: resolved[from]})
+		}
+	}
+
+	plan.Collisions = findCollisions(plan.Renames, files)
+	return plan, nil
+}
+
+// fixableRules returns the enabled rules with a fixer that flag p, in rule order.
+func (rs *ruleSet) fixableRules(p string) []string {
+	var ids []string
+	for _, rule := range allRules {
+		if fixers[rule.ID()] != nil && rs.flags(rule.ID(), p) {
+			ids = append(ids, rule.ID())
+		}
+	}
+	return ids
+}
+
+// flags reports whether the per-file rule id, if enabled, flags p.
+// Whole-tree rules can't be judged from a path alone and always return true.
+func (rs *ruleSet) flags(id, p string) bool {
+	for _, rule := range allRules {
+		if rule.ID() != id {
+			continue
+		}
+		fr, ok := rule.(fileRule)
+		if !ok {
+			return true
+		}
+		settings := rs.settingsFor(rule)
+		if !settings.enabled || settings.ignores(p) {
+			return false
+		}
+		return len(fr.check(rs, classifyPath(p, rs))) > 0
+	}
+	return true
+}
+
+// findCollisions reports renames that target the same path, or a file that
+// already exists and isn't itself being renamed away.
+func findCollisions(renames []Rename, files []string) []string {
+	existing := map[string]bool{}
+	for _, f := range files {
+		existing[filepath.ToSlash(f)] = true
+	}
+	for _, r := range renames {
+		delete(existing, r.From)
+	}
+
+	var collisions []string
+	targets := map[string]string{}
+	for _, r := range renames {
+		if other, ok := targets[r.To]; ok {
+			collisions = append(collisions, fmt.Sprintf("'%s' and '%s' would both be renamed to '%s'", other, r.From, r.To))
+			continue
+		}
+		targets[r.To] = r.From
+		if existing[r.To] {
+			collisions = append(collisions, fmt.Sprintf("renaming '%s' would overwrite existing file '%s'", r.From, r.To))
+		}
+	}
+	return collisions
+}
@@ -0,0 +1,211 @@
+package validator
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"git-monitor-app/gitutil" // Use correct module path
+)
+
+// finalmasterOverrideTrailer, in a commit message, allows that commit to
+// replace or remove a final export. When validating the index (pre-commit)
+// there is no message yet, so FinalmasterOverrideEnv is checked instead.
+const (
+	finalmasterOverrideTrailer = "Finalmaster-Override"
+	FinalmasterOverrideEnv     = "FINALMASTER_OVERRIDE"
+)
+
+var finalmasterOverrideRegex = regexp.MustCompile(`(?im)^` + finalmasterOverrideTrailer + `:\s*\S`)
+
+// optionalRule is implemented by rules that stay off unless enabled in
+// [validation.rules], usually because they are slower or stricter.
+type optionalRule interface {
+	Optional() bool
+}
+
+// exportChange is an added, modified or deleted export named after its
+// project folder with a known status.
+type exportChange struct {
+	gitutil.FileChange
+	Folder       string
+	ExportStatus string // e.g. "mixed"; Status is the git change status
+	Rank         int    // Position of ExportStatus in the lifecycle
+}
+
+// exportHistory compares the exports under src/projects/ in the commit (or
+// index) against its first parent (or HEAD). It is computed once per
+// Validate call and shared by the history rules.
+type exportHistory struct {
+	base    string // Empty when there is nothing to compare against
+	changes []exportChange
+	err     error
+}
+
+// history lazily loads the export changes for this Input.
+func (in *Input) history() *exportHistory {
+	if in.exports != nil {
+		return in.exports
+	}
+	h := &exportHistory{}
+	in.exports = h
+
+	if in.CommitHash == "" {
+		if hash, err := gitutil.ResolveCommit(in.RepoPath, "HEAD"); err == nil {
+			h.base = hash
+		}
+	} else {
+		parents, err := gitutil.GetCommitParents(in.RepoPath, in.CommitHash)
+		if err != nil {
+			h.err = err
+			return h
+		}
+		if len(parents) > 0 {
+			h.base = parents[0]
+		}
+	}
+	if h.base == "" {
+		return h // First commit: no history to progress from
+	}
+
+	changes, err := gitutil.DiffNameStatus(in.RepoPath, h.base, in.CommitHash, "src/projects/")
+	if err != nil {
+		h.err = err
+		return h
+	}
+	for _, c := range changes {
+		f := classifyPath(c.Path, in.rules)
+		if f.Kind != kindProjectExport || !f.FolderValid {
+			continue
+		}
+		folder, status, ok := ParseExportName(f.Base)
+		rank, known := in.rules.statusRank[status]
+		if !ok || !known || folder != f.ProjectFolder {
+			continue // Badly named exports are reported by the naming rules
+		}
+		h.changes = append(h.changes, exportChange{FileChange: c, Folder: folder, ExportStatus: status, Rank: rank})
+	}
+	return h
+}
+
+// exportRegressionRule warns when a new export has an earlier status than
+// one the project already reached.
+type exportRegressionRule struct{}
+
+func (exportRegressionRule) ID() string { return "export-status-regression" }
+func (exportRegressionRule) Description() string {
+	return "New exports should not go back to an earlier status than the project already reached"
+}
+func (exportRegressionRule) DefaultSeverity() Severity { return SeverityWarn }
+func (exportRegressionRule) Optional() bool            { return true }
+
+func (exportRegressionRule) Check(in *Input) []Finding {
+	h := in.history()
+	if h.err != nil {
+		log.Printf("Validator Error: Failed to read export history: %v", h.err)
+		return nil
+	}
+
+	var findings []Finding
+	reached := map[string]exportChange{} // Furthest export per project folder, from history
+	for _, c := range h.changes {
+		if c.Status != "A" {
+			continue
+		}
+		best, ok := reached[c.Folder]
+		if !ok {
+			var err error
+			if best, err = furthestExport(in, h.base, c.Folder); err != nil {
+				log.Printf("Validator Error: Failed to read history of %s: %v", c.Folder, err)
+				continue
+			}
+			reached[c.Folder] = best
+		}
+		if best.Path != "" && c.Rank < best.Rank {
+			findings = append(findings, Finding{Path: c.Path, Message: fmt.Sprintf("Export status '%s' regresses: project already reached '%s' ('%s'). Path: '%s'", c.ExportStatus, best.ExportStatus, best.Path, c.Path)})
+		}
+	}
+	return findings
+}
+
+// furthestExport finds the export with the latest status that has ever
+// existed in a project's exports/ folder up to rev. Path is empty if none has.
+func furthestExport(in *Input, rev, folder string) (exportChange, error) {
+	files, err := gitutil.ListPathHistory(in.RepoPath, rev, "src/projects/"+folder+"/exports/")
+	if err != nil {
+		return exportChange{}, err
+	}
+	best := exportChange{Rank: -1}
+	for _, file := range files {
+		base, status, ok := ParseExportName(file)
+		rank, known := in.rules.statusRank[status]
+		if ok && known && base == folder && rank > best.Rank {
+			best = exportChange{FileChange: gitutil.FileChange{Path: file}, Folder: folder, ExportStatus: status, Rank: rank}
+		}
+	}
+	return best, nil
+}
+
+// finalmasterReplacedRule errors when a final export that already exists is
+// changed or removed, unless the change is explicitly marked as intended.
+type finalmasterReplacedRule struct{}
+
+func (finalmasterReplacedRule) ID() string { return "finalmaster-replaced" }
+func (finalmasterReplacedRule) Description() string {
+	return "Existing final exports may only be replaced with an explicit override"
+}
+func (finalmasterReplacedRule) DefaultSeverity() Severity { return SeverityError }
+func (finalmasterReplacedRule) Optional() bool            { return true }
+
+func (finalmasterReplacedRule) Check(in *Input) []Finding {
+	h := in.history()
+	if h.err != nil {
+		log.Printf("Validator Error: Failed to read export history: %v", h.err)
+		return nil
+	}
+
+	var replaced []exportChange
+	for _, c := range h.changes {
+		if c.ExportStatus == in.rules.finalStatus && c.Status != "A" {
+			replaced = append(replaced, c)
+		}
+	}
+	if len(replaced) == 0 || finalmasterOverridden(in) {
+		return nil
+	}
+
+	var findings []Finding
+	for _, c := range replaced {
+		action := "modified"
+		if c.Status == "D" {
+			action = "removed"
+		}
+		findings = append(findings, Finding{Path: c.Path, Message: fmt.Sprintf("Final export '%s' was %s. Add a '%s: <reason>' trailer to the commit message (or set %s when committing) to allow it.", c.Path, action, finalmasterOverrideTrailer, FinalmasterOverrideEnv)})
+	}
+	return findings
+}
+
+// finalmasterOverridden reports whether the commit (or, for the index, the
+// environment) explicitly allows replacing final exports.
+func finalmasterOverridden(in *Input) bool {
+	if in.CommitHash == "" {
+		return strings.TrimSpace(os.Getenv(FinalmasterOverrideEnv)) != ""
+	}
+	message, err := gitutil.GetCommitMessage(in.RepoPath, in.CommitHash)
+	if err != nil {
+		log.Printf("Validator Error: %v", err)
+		return false
+	}
+	return finalmasterOverrideRegex.MatchString(message)
+}
+
+// WithFinalmasterOverride returns message with a trailer allowing it to
+// replace final exports for the given reason, unless it already has one.
+func WithFinalmasterOverride(message, reason string) string {
+	if finalmasterOverrideRegex.MatchString(message) {
+		return message
+	}
+	return strings.TrimRight(message, "\n") + "\n\n" + finalmasterOverrideTrailer + ": " + reason + "\n"
+}
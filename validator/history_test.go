@@ -0,0 +1,201 @@
+package validator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+// testRepo is a scratch git repository.
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	r := &testRepo{t: t, dir: t.TempDir()}
+	r.git("init", "-q")
+	r.git("config", "user.name", "Test")
+	r.git("config", "user.email", "test@example.com")
+	r.git("config", "commit.gpgsign", "false")
+	return r
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", r.dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// stage writes files (deleting those whose content is empty) and adds them
+// to the index.
+func (r *testRepo) stage(files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		full := filepath.Join(r.dir, filepath.FromSlash(name))
+		if content == "" {
+			r.git("rm", "-q", name)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+		r.git("add", name)
+	}
+}
+
+// commit stages files and commits them, returning the new commit's hash.
+func (r *testRepo) commit(message string, files map[string]string) string {
+	r.t.Helper()
+	r.stage(files)
+	r.git("commit", "-q", "-m", message)
+	return r.git("rev-parse", "HEAD")
+}
+
+// exportPath returns the path of testFolder's export with the given status.
+func exportPath(status string) string {
+	return "src/projects/" + testFolder + "/exports/" + testFolder + "-" + status + ".wav"
+}
+
+// withRule returns cfg with the named optional rule enabled.
+func withRule(cfg config.ValidationConfig, id string) config.ValidationConfig {
+	on := true
+	cfg.Rules = map[string]config.RuleConfig{id: {Enabled: &on}}
+	return cfg
+}
+
+// flagged returns the paths the rule reported, sorted.
+func flagged(result Result, ruleID string) []string {
+	paths := []string{}
+	for _, f := range result.Findings {
+		if f.RuleID == ruleID {
+			paths = append(paths, f.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestFinalmasterReplaced(t *testing.T) {
+	extended := []string{"rough", "mastered", "finalmaster", "stems", "clean"}
+	cases := []struct {
+		name    string
+		cfg     config.ValidationConfig
+		change  map[string]string // Path -> new content, "" to remove it
+		message string
+		want    []string
+	}{
+		{"final export modified", config.ValidationConfig{},
+			map[string]string{exportPath("finalmaster"): "v2"}, "Remaster", []string{exportPath("finalmaster")}},
+		{"final export removed", config.ValidationConfig{},
+			map[string]string{exportPath("finalmaster"): ""}, "Remove master", []string{exportPath("finalmaster")}},
+		{"override trailer", config.ValidationConfig{},
+			map[string]string{exportPath("finalmaster"): "v2"}, "Remaster\n\nFinalmaster-Override: label asked for a louder master", []string{}},
+		{"other exports modified", config.ValidationConfig{},
+			map[string]string{exportPath("mastered"): "v2", exportPath("rough"): "v2"}, "Rework", []string{}},
+		{"final export added", config.ValidationConfig{},
+			map[string]string{"src/projects/new-a-Am-90bpm-prodby.me/exports/new-a-Am-90bpm-prodby.me-finalmaster.wav": "v1"}, "Deliver", []string{}},
+		{"statuses after the final one", config.ValidationConfig{ExportStatuses: extended},
+			map[string]string{exportPath("clean"): "v2", exportPath("finalmaster"): "v2"}, "Update", []string{exportPath("finalmaster")}},
+		{"configured final status", config.ValidationConfig{ExportStatuses: extended, FinalStatus: "mastered"},
+			map[string]string{exportPath("mastered"): "v2", exportPath("finalmaster"): "v2"}, "Update", []string{exportPath("mastered")}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			base := map[string]string{"README.md": "x", ".gitignore": "x"}
+			for _, status := range []string{"rough", "mastered", "finalmaster", "clean"} {
+				base[exportPath(status)] = "v1"
+			}
+			repo.commit("Initial", base)
+			hash := repo.commit(c.message, c.change)
+
+			var files []string
+			for name := range c.change {
+				files = append(files, name)
+			}
+			result := Validate(repo.dir, hash, files, withRule(c.cfg, "finalmaster-replaced"))
+			if got := flagged(result, "finalmaster-replaced"); strings.Join(got, " ") != strings.Join(c.want, " ") {
+				t.Fatalf("flagged %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFinalmasterReplacedInIndex(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit("Initial", map[string]string{"README.md": "x", ".gitignore": "x", exportPath("finalmaster"): "v1"})
+	repo.stage(map[string]string{exportPath("finalmaster"): "v2"})
+	cfg := withRule(config.ValidationConfig{}, "finalmaster-replaced")
+
+	t.Setenv(FinalmasterOverrideEnv, "")
+	result := Validate(repo.dir, "", []string{exportPath("finalmaster")}, cfg)
+	if got := flagged(result, "finalmaster-replaced"); len(got) != 1 {
+		t.Fatalf("flagged %v in the index, want the staged final export", got)
+	}
+	t.Setenv(FinalmasterOverrideEnv, "1")
+	result = Validate(repo.dir, "", []string{exportPath("finalmaster")}, cfg)
+	if got := flagged(result, "finalmaster-replaced"); len(got) != 0 {
+		t.Fatalf("flagged %v with %s set", got, FinalmasterOverrideEnv)
+	}
+}
+
+func TestFinalmasterReplacedIsOptional(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit("Initial", map[string]string{"README.md": "x", ".gitignore": "x", exportPath("finalmaster"): "v1"})
+	hash := repo.commit("Remaster", map[string]string{exportPath("finalmaster"): "v2"})
+	result := Validate(repo.dir, hash, []string{exportPath("finalmaster")}, config.ValidationConfig{})
+	if got := flagged(result, "finalmaster-replaced"); len(got) != 0 {
+		t.Fatalf("flagged %v without the rule enabled", got)
+	}
+}
+
+func TestWithFinalmasterOverride(t *testing.T) {
+	cases := []struct {
+		message string
+		want    string
+	}{
+		{"Retag", "Retag\n\nFinalmaster-Override: metadata fix\n"},
+		{"Retag\n", "Retag\n\nFinalmaster-Override: metadata fix\n"},
+		{"Retag\n\nFinalmaster-Override: already here\n", "Retag\n\nFinalmaster-Override: already here\n"},
+	}
+	for _, c := range cases {
+		if got := WithFinalmasterOverride(c.message, "metadata fix"); got != c.want {
+			t.Errorf("%q: got %q, want %q", c.message, got, c.want)
+		}
+	}
+}
+
+func TestFinalStatus(t *testing.T) {
+	cases := []struct {
+		cfg     config.ValidationConfig
+		want    string
+		wantErr bool
+	}{
+		{config.ValidationConfig{}, "finalmaster", false},
+		{config.ValidationConfig{ExportStatuses: []string{"rough", "finalmaster", "clean"}}, "finalmaster", false},
+		{config.ValidationConfig{FinalStatus: "mastered"}, "mastered", false},
+		{config.ValidationConfig{ExportStatuses: []string{"rough", "master"}, FinalStatus: "master"}, "master", false},
+		{config.ValidationConfig{ExportStatuses: []string{"rough", "master"}}, "", true},
+		{config.ValidationConfig{FinalStatus: "done"}, "", true},
+	}
+	for _, c := range cases {
+		got, err := FinalStatus(c.cfg)
+		if got != c.want || (err != nil) != c.wantErr {
+			t.Errorf("%+v: got %q, %v; want %q (error: %v)", c.cfg, got, err, c.want, c.wantErr)
+		}
+	}
+}
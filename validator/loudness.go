@@ -0,0 +1,172 @@
+package validator
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+
+	"git-monitor-app/audio"   // Use correct module path
+	"git-monitor-app/config"  // Use correct module path
+	"git-monitor-app/gitutil" // Use correct module path
+)
+
+// defaultToleranceLU applies when a target sets integrated_lufs but no tolerance.
+const defaultToleranceLU = 1.0
+
+// measuredExport is the outcome of decoding one export and metering it.
+type measuredExport struct {
+	file     pathInfo
+	status   string
+	target   config.LoudnessTarget
+	loudness audio.Loudness
+	err      error
+}
+
+// measuredExports lazily decodes the WAV and FLAC exports whose status has a
+// loudness target. Only exports whose header was already read successfully
+// are decoded, from the same place the header came from.
+func (in *Input) measuredExports() []measuredExport {
+	if in.measured != nil {
+		return *in.measured
+	}
+	measured := []measuredExport{}
+	in.measured = &measured
+
+	var names []string
+	var fromGit, fromDisk []measuredExport
+	for _, p := range in.audioExports() {
+		if p.err != nil || (p.info.Format != audio.FormatWAV && p.info.Format != audio.FormatFLAC) {
+			continue
+		}
+		_, status, _ := ParseExportName(p.file.Base)
+		target, ok := in.rules.loudness[status]
+		if !ok {
+			continue
+		}
+		m := measuredExport{file: p.file, status: status, target: target}
+		if p.object == "" {
+			fromDisk = append(fromDisk, m)
+		} else {
+			names = append(names, p.object)
+			fromGit = append(fromGit, m)
+		}
+	}
+
+	if len(names) > 0 {
+		i := 0
+		err := gitutil.ReadBlobs(in.RepoPath, names, func(_ string, size int64, content io.Reader) error {
+			m := fromGit[i]
+			i++
+			_, m.loudness, m.err = audio.Analyze(content, size)
+			measured = append(measured, m)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Validator Error: Failed to read export contents for loudness analysis: %v", err)
+		}
+	}
+	for _, m := range fromDisk {
+		m.loudness, m.err = analyzeFile(in.RepoPath, m.file)
+		measured = append(measured, m)
+	}
+	return measured
+}
+
+// analyzeFile decodes and meters an export from the working tree.
+func analyzeFile(repoPath string, f pathInfo) (audio.Loudness, error) {
+	file, err := os.Open(filepath.Join(repoPath, filepath.FromSlash(f.Path)))
+	if err != nil {
+		return audio.Loudness{}, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return audio.Loudness{}, err
+	}
+	_, loudness, err := audio.Analyze(file, stat.Size())
+	return loudness, err
+}
+
+// loudnessRule checks integrated loudness against the status's target. It also
+// reports exports that couldn't be decoded, since none of the loudness rules
+// could say anything about them.
+type loudnessRule struct{}
+
+func (loudnessRule) ID() string { return "export-loudness" }
+func (loudnessRule) Description() string {
+	return "Mastered WAV and FLAC exports must decode cleanly and hit the configured integrated loudness (LUFS)"
+}
+func (loudnessRule) DefaultSeverity() Severity { return SeverityError }
+
+func (loudnessRule) Check(in *Input) []Finding {
+	var findings []Finding
+	for _, m := range in.measuredExports() {
+		if m.err != nil {
+			findings = append(findings, Finding{Path: m.file.Path, Message: fmt.Sprintf("Export '%s' could not be decoded for loudness analysis: %v. Path: '%s'", m.file.Base, m.err, m.file.Path)})
+			continue
+		}
+		if m.target.IntegratedLUFS == nil {
+			continue
+		}
+		want := *m.target.IntegratedLUFS
+		tolerance := m.target.ToleranceLU
+		if tolerance == 0 {
+			tolerance = defaultToleranceLU
+		}
+		got := m.loudness.IntegratedLUFS
+		switch {
+		case math.IsInf(got, -1):
+			findings = append(findings, Finding{Path: m.file.Path, Message: fmt.Sprintf("Export '%s' is silent, but '%s' exports must be %.1f LUFS. Path: '%s'", m.file.Base, m.status, want, m.file.Path)})
+		case math.Abs(got-want) > tolerance:
+			findings = append(findings, Finding{Path: m.file.Path, Message: fmt.Sprintf("Export '%s' is %.1f LUFS, but '%s' exports must be %.1f LUFS (±%.1f LU). Path: '%s'", m.file.Base, got, m.status, want, tolerance, m.file.Path)})
+		}
+	}
+	return findings
+}
+
+// truePeakRule checks the oversampled peak level against the status's ceiling.
+type truePeakRule struct{}
+
+func (truePeakRule) ID() string { return "export-true-peak" }
+func (truePeakRule) Description() string {
+	return "Mastered WAV and FLAC exports must stay under the configured true-peak ceiling (dBTP)"
+}
+func (truePeakRule) DefaultSeverity() Severity { return SeverityError }
+
+func (truePeakRule) Check(in *Input) []Finding {
+	var findings []Finding
+	for _, m := range in.measuredExports() {
+		if m.err != nil || m.target.MaxTruePeakDBTP == nil {
+			continue
+		}
+		if limit := *m.target.MaxTruePeakDBTP; m.loudness.TruePeakDBTP > limit {
+			findings = append(findings, Finding{Path: m.file.Path, Message: fmt.Sprintf("Export '%s' peaks at %.2f dBTP, but '%s' exports must stay at or below %.1f dBTP. Path: '%s'", m.file.Base, m.loudness.TruePeakDBTP, m.status, limit, m.file.Path)})
+		}
+	}
+	return findings
+}
+
+// clippingRule counts samples at or beyond full scale.
+type clippingRule struct{}
+
+func (clippingRule) ID() string { return "export-clipping" }
+func (clippingRule) Description() string {
+	return "Mastered WAV and FLAC exports must not have more clipped samples than configured"
+}
+func (clippingRule) DefaultSeverity() Severity { return SeverityError }
+
+func (clippingRule) Check(in *Input) []Finding {
+	var findings []Finding
+	for _, m := range in.measuredExports() {
+		if m.err != nil || m.target.MaxClippedSamples == nil {
+			continue
+		}
+		if limit := *m.target.MaxClippedSamples; m.loudness.ClippedSamples > limit {
+			findings = append(findings, Finding{Path: m.file.Path, Message: fmt.Sprintf("Export '%s' has %d clipped samples, but '%s' exports may have at most %d. Path: '%s'", m.file.Base, m.loudness.ClippedSamples, m.status, limit, m.file.Path)})
+		}
+	}
+	return findings
+}
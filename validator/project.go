@@ -0,0 +1,137 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+// Project is a project folder name broken into its parts:
+//
+//	<title>-<collaborator>[,<collaborator>...]-<key>-<bpm>bpm-prodby.<producer>
+type Project struct {
+	Folder        string   `json:"folder"` // The full folder name
+	Title         string   `json:"title"`
+	Collaborators []string `json:"collaborators"`
+	Key           string   `json:"key"` // Third segment, conventionally the musical key
+	BPM           int      `json:"bpm"`
+	Producer      string   `json:"producer"` // Name after "prodby."
+}
+
+// defaultProjectFolderRegex is defaultProjectFolderPattern, compiled once.
+// Its capture groups line up with the Project fields.
+var defaultProjectFolderRegex = regexp.MustCompile(defaultProjectFolderPattern)
+
+// ParseProjectFolder parses a project folder name using the built-in naming
+// convention. The error explains which segment is malformed.
+func ParseProjectFolder(name string) (Project, error) {
+	match := defaultProjectFolderRegex.FindStringSubmatch(name)
+	if match == nil {
+		return Project{}, diagnoseProjectFolder(name)
+	}
+	bpm, err := strconv.Atoi(match[4])
+	if err != nil { // Can't happen: the pattern only allows digits
+		return Project{}, fmt.Errorf("invalid BPM %q: %w", match[4], err)
+	}
+	project := Project{Folder: name, Title: match[1], Key: match[3], BPM: bpm, Producer: match[5]}
+	for _, c := range strings.Split(match[2], ",") {
+		if c != "" {
+			project.Collaborators = append(project.Collaborators, c)
+		}
+	}
+	return project, nil
+}
+
+// ProjectForPath parses the project folder a file sits in. ok is false for
+// files outside src/projects/ and for folders that don't parse.
+func ProjectForPath(file string) (project Project, ok bool) {
+	folder := ProjectFolder(file)
+	if folder == "" {
+		return Project{}, false
+	}
+	project, err := ParseProjectFolder(path.Base(folder))
+	return project, err == nil
+}
+
+// diagnoseProjectFolder explains why name doesn't match the default pattern,
+// walking the segments from the right the same way the pattern resolves them.
+func diagnoseProjectFolder(name string) error {
+	const format = "expected '<title>-<collaborators>-<key>-<bpm>bpm-prodby.<producer>'"
+	if name == "" {
+		return errors.New("name is empty")
+	}
+	for i, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.,-", r)) {
+			return fmt.Errorf("invalid character %q at position %d; only letters, digits, '_', '.', ',' and '-' are allowed", r, i+1)
+		}
+	}
+
+	const producerMarker = "-prodby."
+	i := strings.LastIndex(name, producerMarker)
+	if i < 0 {
+		return fmt.Errorf("missing the '-prodby.<producer>' suffix; %s", format)
+	}
+	if name[i+len(producerMarker):] == "" {
+		return errors.New("producer name after 'prodby.' is empty")
+	}
+
+	segments := strings.Split(name[:i], "-")
+	tempo := segments[len(segments)-1]
+	digits, ok := strings.CutSuffix(tempo, "bpm")
+	if !ok {
+		return fmt.Errorf("tempo segment %q before '-prodby.' must look like '140bpm'", tempo)
+	}
+	if len(digits) < 2 || len(digits) > 3 || strings.Trim(digits, "0123456789") != "" {
+		return fmt.Errorf("BPM %q in tempo segment %q must be 2 or 3 digits", digits, tempo)
+	}
+
+	before := segments[:len(segments)-1]
+	if len(before) < 3 {
+		return fmt.Errorf("found %d segment(s) before the tempo (%q); %s", len(before), strings.Join(before, "-"), format)
+	}
+	for j, seg := range before {
+		if seg == "" {
+			return fmt.Errorf("segment %d before the tempo is empty (leading or doubled '-')", j+1)
+		}
+	}
+	return fmt.Errorf("does not match the naming convention; %s", format)
+}
+
+// ExportStatuses returns the configured export statuses (or the defaults),
+// in lifecycle order from earliest to most finished.
+func ExportStatuses(cfg config.ValidationConfig) []string {
+	return orDefault(cfg.ExportStatuses, defaultExportStatuses)
+}
+
+// FinalStatus returns the configured status of delivered masters (or the
+// default), which must be one of ExportStatuses.
+func FinalStatus(cfg config.ValidationConfig) (string, error) {
+	final := cfg.FinalStatus
+	if final == "" {
+		final = config.DefaultFinalStatus
+	}
+	for _, status := range ExportStatuses(cfg) {
+		if status == final {
+			return final, nil
+		}
+	}
+	return "", fmt.Errorf("final_status %q is not one of the export statuses (%s)", final, strings.Join(ExportStatuses(cfg), ", "))
+}
+
+// ParseExportName splits an export file name into its project folder and
+// status, e.g. "<folder>-mixed.wav" gives "<folder>" and "mixed". The status
+// is not checked against the configured vocabulary.
+func ParseExportName(name string) (folder, status string, ok bool) {
+	base := path.Base(name)
+	match := exportNameRegex.FindStringSubmatch(strings.TrimSuffix(base, path.Ext(base)))
+	if len(match) != 3 {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
@@ -0,0 +1,87 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProjectFolder(t *testing.T) {
+	cases := []struct {
+		name string
+		want Project
+	}{
+		{"beat-trap,drill-Cm-140bpm-prodby.me", Project{Title: "beat", Collaborators: []string{"trap", "drill"}, Key: "Cm", BPM: 140, Producer: "me"}},
+		{"night_drive-ana-Fsharp_minor-90bpm-prodby.dj.k", Project{Title: "night_drive", Collaborators: []string{"ana"}, Key: "Fsharp_minor", BPM: 90, Producer: "dj.k"}},
+		{"song-a,b,-Am-100bpm-prodby.x", Project{Title: "song", Collaborators: []string{"a", "b"}, Key: "Am", BPM: 100, Producer: "x"}},
+		{"my-song-ana-Am-100bpm-prodby.x", Project{Title: "my-song", Collaborators: []string{"ana"}, Key: "Am", BPM: 100, Producer: "x"}},
+	}
+	for _, c := range cases {
+		got, err := ParseProjectFolder(c.name)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		c.want.Folder = c.name
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseProjectFolderErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		wantErr string
+	}{
+		{"", "name is empty"},
+		{"beat trap-a-Cm-140bpm-prodby.me", "invalid character ' ' at position 5"},
+		{"bêat-a-Cm-140bpm-prodby.me", "invalid character 'ê' at position 2"},
+		{"beat-trap-Cm-140bpm", "missing the '-prodby.<producer>' suffix"},
+		{"beat-trap-Cm-140bpm-prodby.", "producer name after 'prodby.' is empty"},
+		{"beat-trap-Cm-fast-prodby.me", `tempo segment "fast"`},
+		{"beat-trap-Cm-1400bpm-prodby.me", `BPM "1400"`},
+		{"beat-trap-Cm-9bpm-prodby.me", `BPM "9"`},
+		{"beat-Cm-140bpm-prodby.me", "found 2 segment(s) before the tempo"},
+		{"beat--Cm-140bpm-prodby.me", "segment 2 before the tempo is empty"},
+	}
+	for _, c := range cases {
+		_, err := ParseProjectFolder(c.name)
+		if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("%q: got %v, want an error containing %q", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestParseExportName(t *testing.T) {
+	cases := []struct {
+		name   string
+		folder string
+		status string
+		ok     bool
+	}{
+		{"beat-trap-Cm-140bpm-prodby.me-mixed.wav", "beat-trap-Cm-140bpm-prodby.me", "mixed", true},
+		{"src/projects/x/exports/beat-trap-Cm-140bpm-prodby.me-finalmaster.FLAC", "beat-trap-Cm-140bpm-prodby.me", "finalmaster", true},
+		{"exports/a-b-bogus.mp3", "a-b", "bogus", true}, // Statuses aren't checked here
+		{"a-.wav", "", "", false},
+		{"mixed.wav", "", "", false},
+	}
+	for _, c := range cases {
+		folder, status, ok := ParseExportName(c.name)
+		if folder != c.folder || status != c.status || ok != c.ok {
+			t.Errorf("%s: got (%q, %q, %v), want (%q, %q, %v)", c.name, folder, status, ok, c.folder, c.status, c.ok)
+		}
+	}
+}
+
+func TestProjectForPath(t *testing.T) {
+	project, ok := ProjectForPath("src/projects/beat-trap-Cm-140bpm-prodby.me/exports/beat-trap-Cm-140bpm-prodby.me-mixed.wav")
+	if !ok || project.Title != "beat" || project.BPM != 140 {
+		t.Fatalf("got %+v, %v", project, ok)
+	}
+	for _, file := range []string{"README.md", "src/projects/loose.flp", "src/projects/not a project/x.flp"} {
+		if _, ok := ProjectForPath(file); ok {
+			t.Errorf("%s: got a project", file)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+// Built-in defaults, used for any setting the [validation] config leaves empty.
+var (
+	defaultProjectExtensions    = []string{"flp", "rpp", "song", "project", "cpr", "ptx", "logicx", "als"}
+	defaultExportExtensions     = []string{"mp3", "wav", "flac"}
+	defaultExportStatuses       = []string{"progress", "unmixed", "rough", "mixed", "finalmix", "roughmaster", "mastered", "finalmaster"}
+	defaultProjectFolderPattern = `^([a-zA-Z0-9_.,-]+)-([a-zA-Z0-9_.,-]+(?:,[a-zA-Z0-9_.,-]+)*)-([a-zA-Z0-9_.,-]+)-([0-9]{2,3})bpm-prodby\.([a-zA-Z0-9_.,-]+)$`
+	defaultAllowedRootFiles     = []string{"README.md", ".gitignore", "config.toml"}
+	defaultRequiredRootFiles    = []string{"README.md", ".gitignore"}
+
+	// exportNameRegex splits an export basename into "[project_base]-[status]"
+	exportNameRegex = regexp.MustCompile(`^(.*)-([^-]+)$`)
+)
+
+// ruleSet is a ValidationConfig with defaults applied and patterns compiled.
+type ruleSet struct {
+	projectExtensions  []string // Lowercase, without leading dot
+	exportExtensions   []string
+	exportStatuses     []string       // In lifecycle order
+	statusRank         map[string]int // Position of each status in exportStatuses
+	finalStatus        string         // Status of delivered masters
+	projectExtRegex    *regexp.Regexp
+	exportExtRegex     *regexp.Regexp
+	exportStatusRegex  *regexp.Regexp
+	projectFolderRegex *regexp.Regexp // Only set for a custom project_folder_pattern
+	allowedRootFiles   []string
+	allowedRoot        map[string]bool
+	requiredRootFiles  []string
+	audioPolicies      []config.AudioPolicy
+	loudness           map[string]config.LoudnessTarget // Keyed by export status
+	ruleConfigs        map[string]config.RuleConfig
+}
+
+// newRuleSet fills in defaults and compiles the configured rules.
+func newRuleSet(cfg config.ValidationConfig) (*ruleSet, error) {
+	rs := &ruleSet{
+		projectExtensions: normalizeExtensions(orDefault(cfg.ProjectExtensions, defaultProjectExtensions)),
+		exportExtensions:  normalizeExtensions(orDefault(cfg.ExportExtensions, defaultExportExtensions)),
+		exportStatuses:    orDefault(cfg.ExportStatuses, defaultExportStatuses),
+		allowedRootFiles:  orDefault(cfg.AllowedRootFiles, defaultAllowedRootFiles),
+		requiredRootFiles: orDefault(cfg.RequiredRootFiles, defaultRequiredRootFiles),
+		allowedRoot:       map[string]bool{},
+		statusRank:        map[string]int{},
+		audioPolicies:     cfg.AudioPolicies,
+		loudness:          cfg.Loudness, // Opt-in: decoding every master is slow, and no one target suits every release
+		ruleConfigs:       cfg.Rules,
+	}
+	for _, name := range rs.allowedRootFiles {
+		rs.allowedRoot[name] = true
+	}
+	for i, status := range rs.exportStatuses {
+		rs.statusRank[status] = i
+	}
+	var err error
+	if rs.finalStatus, err = FinalStatus(cfg); err != nil {
+		return nil, err
+	}
+
+	rs.projectExtRegex = regexp.MustCompile(`\.(` + alternation(rs.projectExtensions) + `)$`)
+	rs.exportExtRegex = regexp.MustCompile(`\.(` + alternation(rs.exportExtensions) + `)$`)
+	rs.exportStatusRegex = regexp.MustCompile(`^(` + alternation(rs.exportStatuses) + `)$`)
+
+	// The default convention goes through ParseProjectFolder for its precise
+	// error messages; a custom pattern can only say "doesn't match".
+	if cfg.ProjectFolderPattern != "" {
+		rs.projectFolderRegex, err = regexp.Compile(cfg.ProjectFolderPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid project_folder_pattern: %w", err)
+		}
+	}
+
+	for id, rc := range cfg.Rules {
+		if !isKnownRule(id) {
+			return nil, fmt.Errorf("unknown rule %q in [validation.rules]", id)
+		}
+		if _, ok := parseSeverity(rc.Severity); rc.Severity != "" && !ok {
+			return nil, fmt.Errorf("rule %s: invalid severity %q", id, rc.Severity)
+		}
+	}
+	return rs, nil
+}
+
+// checkProjectFolder reports why a project folder name is invalid, or nil.
+func (rs *ruleSet) checkProjectFolder(name string) error {
+	if rs.projectFolderRegex == nil {
+		_, err := ParseProjectFolder(name)
+		return err
+	}
+	if !rs.projectFolderRegex.MatchString(name) {
+		return fmt.Errorf("does not match project_folder_pattern %s", rs.projectFolderRegex)
+	}
+	return nil
+}
+
+// settingsFor resolves a rule's config against its defaults.
+func (rs *ruleSet) settingsFor(rule Rule) ruleSettings {
+	settings := ruleSettings{enabled: true, severity: rule.DefaultSeverity()}
+	if opt, ok := rule.(optionalRule); ok && opt.Optional() {
+		settings.enabled = false
+	}
+	rc, ok := rs.ruleConfigs[rule.ID()]
+	if !ok {
+		return settings
+	}
+	if rc.Enabled != nil {
+		settings.enabled = *rc.Enabled
+	}
+	if sev, ok := parseSeverity(rc.Severity); ok {
+		settings.severity = sev
+	}
+	settings.ignorePaths = rc.IgnorePaths
+	return settings
+}
+
+// isKnownRule reports whether id names a built-in rule.
+func isKnownRule(id string) bool {
+	for _, rule := range allRules {
+		if rule.ID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeExtensions lowercases extensions and strips any leading dot.
+func normalizeExtensions(exts []string) []string {
+	out := make([]string, len(exts))
+	for i, ext := range exts {
+		out[i] = strings.ToLower(strings.TrimPrefix(ext, "."))
+	}
+	return out
+}
+
+// alternation joins literal values into a regexp alternation.
+func alternation(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// dotList renders extensions as ".a, .b, .c" for error messages.
+func dotList(exts []string) string {
+	return "." + strings.Join(exts, ", .")
+}
+
+// orDefault returns configured unless it is nil, in which case it returns def.
+func orDefault(configured, def []string) []string {
+	if configured != nil {
+		return configured
+	}
+	return def
+}
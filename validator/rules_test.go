@@ -0,0 +1,167 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"git-monitor-app/config" // Use correct module path
+)
+
+const testFolder = "beat-trap,drill-Cm-140bpm-prodby.me"
+
+func TestNewRuleSet(t *testing.T) {
+	rs, err := newRuleSet(config.ValidationConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(rs.exportStatuses) != fmt.Sprint(defaultExportStatuses) || rs.finalStatus != "finalmaster" {
+		t.Fatalf("got statuses %v with final %q, want the defaults", rs.exportStatuses, rs.finalStatus)
+	}
+	if rs.statusRank["progress"] != 0 || rs.statusRank["finalmaster"] != len(defaultExportStatuses)-1 {
+		t.Fatalf("got ranks %v", rs.statusRank)
+	}
+
+	rs, err = newRuleSet(config.ValidationConfig{
+		ProjectExtensions: []string{".ALS", "flp"},
+		ExportStatuses:    []string{"rough", "finalmaster", "clean"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(rs.projectExtensions) != "[als flp]" {
+		t.Fatalf("got extensions %v, want them lowercased without dots", rs.projectExtensions)
+	}
+	if rs.finalStatus != "finalmaster" {
+		t.Fatalf("got final status %q, want finalmaster rather than the last status", rs.finalStatus)
+	}
+	if !rs.exportStatusRegex.MatchString("clean") || rs.exportStatusRegex.MatchString("mastered") {
+		t.Fatal("the status pattern doesn't follow the configured statuses")
+	}
+
+	errorCases := []struct {
+		name    string
+		cfg     config.ValidationConfig
+		wantErr string
+	}{
+		{"unknown rule", config.ValidationConfig{Rules: map[string]config.RuleConfig{"no-tabs": {}}}, `unknown rule "no-tabs"`},
+		{"bad severity", config.ValidationConfig{Rules: map[string]config.RuleConfig{"no-spaces": {Severity: "fatal"}}}, "invalid severity"},
+		{"bad folder pattern", config.ValidationConfig{ProjectFolderPattern: "("}, "invalid project_folder_pattern"},
+		{"final status not among the defaults", config.ValidationConfig{FinalStatus: "done"}, `final_status "done"`},
+	}
+	for _, c := range errorCases {
+		if _, err := newRuleSet(c.cfg); err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("%s: got %v, want an error containing %q", c.name, err, c.wantErr)
+		}
+	}
+}
+
+// findingKeys renders findings as "severity rule path" for comparing.
+func findingKeys(result Result) []string {
+	var keys []string
+	for _, f := range result.Findings {
+		keys = append(keys, fmt.Sprintf("%s %s %s", f.Severity, f.RuleID, f.Path))
+	}
+	return keys
+}
+
+func TestRunRules(t *testing.T) {
+	off := false
+	project := "src/projects/" + testFolder + "/" + testFolder + ".flp"
+	export := "src/projects/" + testFolder + "/exports/" + testFolder + "-mixed.WAV"
+	spaced := "src/projects/" + testFolder + "/exports/" + testFolder + " -mixed.wav"
+	files := []string{project, export, spaced, "notes.txt", "src/projects/loose.flp"}
+
+	// Findings come out by path, then in rule order
+	cases := []struct {
+		name  string
+		rules map[string]config.RuleConfig
+		want  []string
+	}{
+		{"defaults", nil, []string{
+			"error root-files notes.txt",
+			"error no-spaces " + spaced,
+			"error export-name " + spaced,
+			"warn extension-case " + export,
+			"error project-layout src/projects/loose.flp",
+		}},
+		{"rule disabled", map[string]config.RuleConfig{"no-spaces": {Enabled: &off}}, []string{
+			"error root-files notes.txt",
+			"error export-name " + spaced,
+			"warn extension-case " + export,
+			"error project-layout src/projects/loose.flp",
+		}},
+		{"severities changed", map[string]config.RuleConfig{
+			"root-files":     {Severity: config.SeverityInfo},
+			"extension-case": {Severity: config.SeverityError},
+		}, []string{
+			"info root-files notes.txt",
+			"error no-spaces " + spaced,
+			"error export-name " + spaced,
+			"error extension-case " + export,
+			"error project-layout src/projects/loose.flp",
+		}},
+		{"paths ignored", map[string]config.RuleConfig{
+			"no-spaces":      {IgnorePaths: []string{"src/projects/*/exports/"}},
+			"project-layout": {IgnorePaths: []string{"src/projects/loose.flp"}},
+		}, []string{
+			"error root-files notes.txt",
+			"error export-name " + spaced,
+			"warn extension-case " + export,
+		}},
+	}
+	for _, c := range cases {
+		result := ValidateNames(files, config.ValidationConfig{Rules: c.rules})
+		if got := findingKeys(result); strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Errorf("%s: got findings\n  %s\nwant\n  %s", c.name, strings.Join(got, "\n  "), strings.Join(c.want, "\n  "))
+		}
+	}
+}
+
+func TestRunRulesOptionalRulesStartDisabled(t *testing.T) {
+	rs, err := newRuleSet(config.ValidationConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.settingsFor(finalmasterReplacedRule{}).enabled || rs.settingsFor(exportRegressionRule{}).enabled {
+		t.Fatal("an optional rule is enabled without being configured")
+	}
+	on := true
+	rs.ruleConfigs = map[string]config.RuleConfig{"finalmaster-replaced": {Enabled: &on}}
+	if !rs.settingsFor(finalmasterReplacedRule{}).enabled {
+		t.Fatal("an optional rule stayed off once enabled")
+	}
+}
+
+func TestValidateNamesReportsConfigErrors(t *testing.T) {
+	result := ValidateNames([]string{"README.md"}, config.ValidationConfig{FinalStatus: "done"})
+	if result.Valid() || len(result.Findings) != 1 || result.Findings[0].RuleID != "validation-config" {
+		t.Fatalf("got %v, want a single validation-config error", result.Findings)
+	}
+}
+
+func TestIgnores(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"src/legacy", "src/legacy", true},
+		{"src/legacy", "src/legacy/a/b.wav", true},
+		{"src/legacy/", "src/legacy/a.wav", true},
+		{"src/legacy", "src/legacy-old/a.wav", false},
+		{"src/legacy", "src/other/legacy/a.wav", false},
+		{"src/projects/*/exports", "src/projects/x/exports/x-mixed.wav", true},
+		{"src/projects/*/exports", "src/projects/x/y/exports/a.wav", false},
+		{"*.txt", "notes.txt", true},
+		{"*.txt", "src/notes.txt", false},
+		{"src/*/*.tmp", "src/projects/a.tmp", true},
+		{"[", "src/a", false}, // Malformed patterns match nothing
+	}
+	for _, c := range cases {
+		s := ruleSettings{ignorePaths: []string{c.pattern}}
+		if got := s.ignores(c.path); got != c.want {
+			t.Errorf("ignore_paths %q on %q: got %v, want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
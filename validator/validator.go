@@ -2,173 +2,249 @@ package validator
 
 import (
 	"fmt"
+	"log"
+	"path"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 
-	"git-monitor-app/gitutil" // Use correct module path
+	"git-monitor-app/config" // Use correct module path
 )
 
-// Precompile regexps for efficiency
-var (
-	projectExtRegex    = regexp.MustCompile(`\.(flp|rpp|song|project|cpr|ptx|logicx|als)$`)
-	exportExtRegex     = regexp.MustCompile(`\.(mp3|wav|flac)$`)
-	exportStatusRegex  = regexp.MustCompile(`^(progress|unmixed|rough|mixed|finalmix|roughmaster|mastered|finalmaster)$`)
-	projectFolderRegex = regexp.MustCompile(`^([a-zA-Z0-9_.,-]+)-([a-zA-Z0-9_.,-]+(?:,[a-zA-Z0-9_.,-]+)*)-([a-zA-Z0-9_.,-]+)-([0-9]{2,3})bpm-prodby\.([a-zA-Z0-9_.,-]+)$`)
-	allowedRootFiles   = map[string]bool{"README.md": true, ".gitignore": true, "config.toml": true}
-	requiredRootFiles  = []string{"README.md", ".gitignore"}
+// Severity ranks how serious a finding is. Only SeverityError blocks a backup.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarn
+	SeverityError
 )
 
-// Validate checks the list of changed files against the predefined rules.
-func Validate(repoPath string, changedFiles []string) (bool, []string) {
-	var errors []string
-	isValid := true
+// String returns the config spelling of the severity ("error", "warn", "info").
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return config.SeverityError
+	case SeverityWarn:
+		return config.SeverityWarn
+	default:
+		return config.SeverityInfo
+	}
+}
 
-	addError := func(format string, args ...interface{}) {
-		errors = append(errors, fmt.Sprintf(format, args...))
-		isValid = false
+// parseSeverity converts a (pre-validated) config severity string.
+func parseSeverity(s string) (Severity, bool) {
+	switch s {
+	case config.SeverityError:
+		return SeverityError, true
+	case config.SeverityWarn:
+		return SeverityWarn, true
+	case config.SeverityInfo:
+		return SeverityInfo, true
+	}
+	return SeverityInfo, false
+}
+
+// Finding is a single problem reported by a rule.
+type Finding struct {
+	RuleID   string
+	Severity Severity
+	Path     string // Repository-relative path, empty for repository-wide findings
+	Message  string
+}
+
+// String renders the finding for logs, e.g. "error [no-spaces] Path contains spaces: 'a b'".
+func (f Finding) String() string {
+	return fmt.Sprintf("%s [%s] %s", f.Severity, f.RuleID, f.Message)
+}
+
+// Result holds every finding from one validation run.
+type Result struct {
+	Findings []Finding
+}
+
+// Valid reports whether there are no error-severity findings.
+func (r Result) Valid() bool {
+	return len(r.Errors()) == 0
+}
+
+// Errors returns the findings that block a backup.
+func (r Result) Errors() []Finding {
+	return r.withSeverity(SeverityError)
+}
+
+// Warnings returns the findings reported as warnings.
+func (r Result) Warnings() []Finding {
+	return r.withSeverity(SeverityWarn)
+}
+
+func (r Result) withSeverity(sev Severity) []Finding {
+	var out []Finding
+	for _, f := range r.Findings {
+		if f.Severity == sev {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Input is what each rule gets to look at.
+type Input struct {
+	RepoPath   string
+	CommitHash string // Empty means "the index"
+	Worktree   bool   // Read files from the working tree instead of the index; CommitHash is empty
+	Files      []pathInfo
+	rules      *ruleSet
+	exports    *exportHistory    // Loaded on first use by the history rules
+	probed     *[]probedExport   // Loaded on first use by the audio rules
+	measured   *[]measuredExport // Loaded on first use by the loudness rules
+}
+
+// Rule is one named validation check.
+type Rule interface {
+	ID() string
+	Description() string
+	DefaultSeverity() Severity
+	// Check returns findings with RuleID and Severity left for the engine to fill in.
+	Check(in *Input) []Finding
+}
+
+// Rules returns every built-in rule, in the order they run.
+func Rules() []Rule {
+	return allRules
+}
+
+// Validate checks the list of changed files against the configured rules.
+// Required files are looked up in commitHash's tree, or in the index when
+// commitHash is empty. Settings left empty in cfg fall back to the built-in defaults.
+func Validate(repoPath, commitHash string, changedFiles []string, cfg config.ValidationConfig) Result {
+	return validate(&Input{RepoPath: repoPath, CommitHash: commitHash}, changedFiles, cfg)
+}
+
+// ValidateWorktree is Validate for files as they are in the working tree,
+// whether or not their changes are staged.
+func ValidateWorktree(repoPath string, files []string, cfg config.ValidationConfig) Result {
+	return validate(&Input{RepoPath: repoPath, Worktree: true}, files, cfg)
+}
+
+// validate runs every rule against changedFiles, read from where in says.
+func validate(in *Input, changedFiles []string, cfg config.ValidationConfig) Result {
+	rules, err := newRuleSet(cfg)
+	if err != nil {
+		return Result{Findings: []Finding{{
+			RuleID:   "validation-config",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("Validation config error: %v", err),
+		}}}
 	}
 
 	if len(changedFiles) == 0 {
-		fmt.Println("Validator: No changed files to validate.")
+		log.Println("Validator: No changed files to validate.")
 		// Still check required files even if no changes staged in this commit
 	} else {
-		fmt.Printf("Validator: Checking %d changed file(s)...\n", len(changedFiles))
-		for _, file := range changedFiles {
-			fmt.Printf("Validator: Checking file: %s\n", file)
-			hasFileError := false // Track if *this specific file* has an error
-
-			// --- General Rule 1: No spaces ---
-			if strings.Contains(file, " ") {
-				addError("Path contains spaces: '%s'", file)
-				hasFileError = true
-			}
+		log.Printf("Validator: Checking %d changed file(s)...", len(changedFiles))
+	}
 
-			// Use filepath.ToSlash for consistent path separators
-			filePath := filepath.ToSlash(file)
-			dir := filepath.ToSlash(filepath.Dir(filePath))
-			base := filepath.Base(filePath)
-
-			// --- Structure & Naming Rules ---
-
-			// Rule: Root files
-			if dir == "." {
-				if !allowedRootFiles[base] {
-					addError("Unexpected file in root directory: '%s'", file)
-					hasFileError = true
-				}
-			} else if strings.HasPrefix(filePath, "src/") {
-				parts := strings.Split(filePath, "/")
-				if parts[0] != "src" {
-					addError("'src' directory component must be lowercase: '%s' in path '%s'", parts[0], file)
-					hasFileError = true
-				}
-
-				// Rule: src/projects directory
-				if len(parts) > 1 && parts[1] == "projects" {
-					if len(parts) > 2 { // We have a project folder level or deeper
-						projectFolder := parts[2]
-						if !projectFolderRegex.MatchString(projectFolder) {
-							addError("Invalid project folder name format: '%s' in path '%s'", projectFolder, file)
-							hasFileError = true
-						} else {
-							// Path relative to project folder
-							pathInsideProject := strings.Join(parts[3:], "/")
-
-							if pathInsideProject == "" {
-								// This case should ideally not happen for files from `git show --name-only`
-								// but might if a directory itself was listed?
-								fmt.Printf("Validator: Note - Project directory '%s' itself listed.\n", projectFolder)
-							} else if !strings.Contains(pathInsideProject, "/") { // File directly in project folder
-								baseName := parts[len(parts)-1]
-								ext := filepath.Ext(baseName)
-								baseNameNoExt := strings.TrimSuffix(baseName, ext)
-
-								if baseNameNoExt != projectFolder {
-									addError("Project filename base must match folder name. Expected '%s.*', found '%s' in path '%s'", projectFolder, baseName, file)
-									hasFileError = true
-								}
-								if !projectExtRegex.MatchString(strings.ToLower(baseName)) { // Check extension case-insensitively maybe? Using ToLower here.
-									addError("Invalid file extension for project file '%s'. Allowed: .flp, .rpp, .song, .project, .cpr, .ptx, .logicx, .als. Path: '%s'", baseName, file)
-									hasFileError = true
-								}
-							} else if strings.HasPrefix(pathInsideProject, "exports/") { // Inside exports/
-								if parts[3] != "exports" {
-									addError("'exports' directory component must be lowercase: '%s' in path '%s'", parts[3], file)
-									hasFileError = true
-								}
-
-								if len(parts) > 4 { // File inside exports/
-									exportBaseName := parts[len(parts)-1]
-									exportExt := filepath.Ext(exportBaseName)
-									exportBaseNameNoExt := strings.TrimSuffix(exportBaseName, exportExt)
-
-									// Extract base part and status (zzz)
-									match := regexp.MustCompile(`^(.*)-([^-]+)$`).FindStringSubmatch(exportBaseNameNoExt)
-									if len(match) != 3 {
-										addError("Export filename '%s' does not match expected format '[project_base]-[status]'. Path: '%s'", exportBaseName, file)
-										hasFileError = true
-									} else {
-										exportBase := match[1]
-										exportStatus := match[2]
-
-										if exportBase != projectFolder {
-											addError("Export filename base must match project folder name. Expected '%s-[status].*', found '%s' in path '%s'", projectFolder, exportBaseName, file)
-											hasFileError = true
-										}
-										if !exportStatusRegex.MatchString(exportStatus) {
-											addError("Invalid status identifier '%s' in export filename '%s'. Allowed: progress, unmixed, rough, mixed, finalmix, roughmaster, mastered, finalmaster. Path: '%s'", exportStatus, exportBaseName, file)
-											hasFileError = true
-										}
-									}
-									// Check export file extension
-									if !exportExtRegex.MatchString(strings.ToLower(exportBaseName)) { // Case-insensitive check
-										addError("Invalid file extension for export file '%s'. Allowed: .mp3, .wav, .flac. Path: '%s'", exportBaseName, file)
-										hasFileError = true
-									}
-								}
-								// else: it's just the exports/ directory itself being added/modified - ignore file checks
-							} else {
-								// Rule: Other files/dirs inside project folder not allowed
-								addError("Unexpected file or directory inside project folder: '%s' in path '%s'. Only project file and 'exports/' dir allowed directly under '%s/'", pathInsideProject, file, projectFolder)
-								hasFileError = true
-							}
-						}
-					} else {
-						// File directly under src/projects/ - Not allowed
-						addError("Files are not allowed directly inside 'src/projects/'. Place them in a named project folder: '%s'", file)
-						hasFileError = true
-					}
-				} else if len(parts) > 1 && parts[1] != "projects" {
-					// Rule: src/* (folders other than projects) - Not monitored inside
-					fmt.Printf("Validator: Skipping detailed checks for non-project path inside src/: '%s'\n", file)
-				}
-				// else: file is src/something - already checked parts[0] == "src"
-
-			} else if dir != "." { // Not root, not src/*
-				addError("Unexpected top-level file or directory: '%s'. Only 'src/', 'README.md', 'config.toml', '.gitignore' allowed.", file)
-				hasFileError = true
-			}
-			if hasFileError {
-				fmt.Printf("Validator: Error found for file: %s\n", file)
+	in.rules = rules
+	for _, file := range changedFiles {
+		in.Files = append(in.Files, classifyPath(file, rules))
+	}
+	return runRules(in, allRules)
+}
+
+// ValidateNames checks files against only the rules that judge each path on
+// its own, which is all PlanRenames needs. Nothing is read from the
+// repository, so exports aren't decoded and required files aren't checked.
+func ValidateNames(files []string, cfg config.ValidationConfig) Result {
+	rules, err := newRuleSet(cfg)
+	if err != nil {
+		return Result{Findings: []Finding{{
+			RuleID:   "validation-config",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("Validation config error: %v", err),
+		}}}
+	}
+	in := &Input{rules: rules}
+	for _, file := range files {
+		in.Files = append(in.Files, classifyPath(file, rules))
+	}
+	var nameRules []Rule
+	for _, rule := range allRules {
+		if _, ok := rule.(fileRule); ok {
+			nameRules = append(nameRules, rule)
+		}
+	}
+	return runRules(in, nameRules)
+}
+
+// runRules runs the enabled rules among ruleList against in.
+func runRules(in *Input, ruleList []Rule) Result {
+	rules := in.rules
+	var result Result
+	for _, rule := range ruleList {
+		settings := rules.settingsFor(rule)
+		if !settings.enabled {
+			continue
+		}
+		count := 0
+		for _, f := range rule.Check(in) {
+			if f.Path != "" && settings.ignores(f.Path) {
+				continue
 			}
+			f.RuleID = rule.ID()
+			f.Severity = settings.severity
+			result.Findings = append(result.Findings, f)
+			count++
+		}
+		if count > 0 {
+			log.Printf("Validator: Rule %s reported %d %s finding(s).", rule.ID(), count, settings.severity)
+		}
+	}
+
+	// Keep output stable: by path, then rule order
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		return result.Findings[i].Path < result.Findings[j].Path
+	})
+	return result
+}
 
-		} // End file loop
-	} // End check if changedFiles not empty
-
-	// --- Check for required files existence in the repository index ---
-	// This check runs regardless of validation status of changed files
-	fmt.Println("Validator: Checking existence of required files in repository...")
-	reqFilesFound := true
-	for _, reqFile := range requiredRootFiles {
-		if !gitutil.CheckFileExists(repoPath, reqFile) {
-			addError("Required file '%s' not found in repository index.", reqFile)
-			reqFilesFound = false
+// ruleSettings is a RuleConfig resolved against a rule's defaults.
+type ruleSettings struct {
+	enabled     bool
+	severity    Severity
+	ignorePaths []string
+}
+
+// ignores reports whether p is covered by one of the rule's ignore_paths.
+// A pattern matches the path itself or anything below it, and may use
+// path.Match globs.
+func (s ruleSettings) ignores(p string) bool {
+	for _, pattern := range s.ignorePaths {
+		pattern = strings.TrimSuffix(pattern, "/")
+		for candidate := p; candidate != "." && candidate != "/"; candidate = path.Dir(candidate) {
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return true
+			}
 		}
 	}
-	if reqFilesFound {
-		fmt.Println("Validator: All required files found.")
+	return false
+}
+
+// quoteList renders names as 'a', 'b', 'c' for error messages.
+func quoteList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = "'" + n + "'"
 	}
+	return strings.Join(quoted, ", ")
+}
 
-	return isValid, errors
+// ProjectFolder returns "src/projects/<folder>" for paths inside a project
+// folder, or "" for anything else.
+func ProjectFolder(file string) string {
+	parts := strings.Split(filepath.ToSlash(file), "/")
+	if len(parts) > 3 && parts[0] == "src" && parts[1] == "projects" {
+		return strings.Join(parts[:3], "/")
+	}
+	return ""
 }